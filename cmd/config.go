@@ -0,0 +1,322 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/davidborzek/docker-exporter/internal/clock"
+	"github.com/davidborzek/docker-exporter/internal/collector"
+	"github.com/davidborzek/docker-exporter/internal/config"
+	"github.com/davidborzek/docker-exporter/internal/docker"
+	"github.com/docker/docker/client"
+	"github.com/urfave/cli/v3"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// loadConfig reads the config file, if any, and applies the command line
+// flags on top of it: a flag set on the command line or via its environment
+// variable overrides the file, a flag's default only fills settings the file
+// leaves empty.
+func loadConfig(cmd *cli.Command) (*config.Config, error) {
+	cfg := &config.Config{}
+	if path := cmd.String("config-file"); path != "" {
+		var err error
+		if cfg, err = config.Load(path); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := applyFlags(cmd, cfg); err != nil {
+		return nil, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+func applyFlags(cmd *cli.Command, cfg *config.Config) error {
+	overrideString(cmd, "host", &cfg.Server.Host)
+	overrideString(cmd, "port", &cfg.Server.Port)
+	overrideString(cmd, "auth-token", &cfg.Server.AuthToken)
+	overrideString(cmd, "auth-token-file", &cfg.Server.TokenFile)
+
+	if cmd.String("jwt-jwks-file") != "" || cmd.String("jwt-jwks-url") != "" ||
+		cmd.String("jwt-issuer") != "" || len(cmd.StringSlice("jwt-audience")) > 0 ||
+		len(cmd.StringSlice("jwt-algorithm")) > 0 {
+		if cfg.Server.JWT == nil {
+			cfg.Server.JWT = &config.ServerJWTConfig{}
+		}
+	}
+	if jwt := cfg.Server.JWT; jwt != nil {
+		// A JWKS file and URL exclude each other, so one given on the command
+		// line replaces the other from the file.
+		if cmd.IsSet("jwt-jwks-file") && !cmd.IsSet("jwt-jwks-url") {
+			jwt.JWKSURL = ""
+		}
+		if cmd.IsSet("jwt-jwks-url") && !cmd.IsSet("jwt-jwks-file") {
+			jwt.JWKSFile = ""
+		}
+		overrideString(cmd, "jwt-jwks-file", &jwt.JWKSFile)
+		overrideString(cmd, "jwt-jwks-url", &jwt.JWKSURL)
+		overrideString(cmd, "jwt-issuer", &jwt.Issuer)
+		overrideStrings(cmd, "jwt-audience", &jwt.Audiences)
+		overrideStrings(cmd, "jwt-algorithm", &jwt.Algorithms)
+	}
+
+	if cmd.String("tls-cert") != "" || cmd.String("tls-key") != "" ||
+		cmd.String("tls-client-ca") != "" || cmd.IsSet("tls-min-version") ||
+		len(cmd.StringSlice("tls-cipher-suite")) > 0 {
+		if cfg.Server.TLS == nil {
+			cfg.Server.TLS = &config.ServerTLSConfig{}
+		}
+	}
+	if tls := cfg.Server.TLS; tls != nil {
+		overrideString(cmd, "tls-cert", &tls.CertFile)
+		overrideString(cmd, "tls-key", &tls.KeyFile)
+		overrideString(cmd, "tls-client-ca", &tls.ClientCAFile)
+		overrideString(cmd, "tls-min-version", &tls.MinVersion)
+		overrideStrings(cmd, "tls-cipher-suite", &tls.CipherSuites)
+	}
+
+	overrideStrings(cmd, "allow-cidr", &cfg.Server.Access.Allow)
+	overrideStrings(cmd, "deny-cidr", &cfg.Server.Access.Deny)
+	overrideStrings(cmd, "trusted-proxy", &cfg.Server.Access.TrustedProxies)
+	if cmd.IsSet("rate-limit") {
+		cfg.Server.Limits.RateLimit = cmd.Float("rate-limit")
+	}
+	if cmd.IsSet("rate-limit-burst") || cfg.Server.Limits.RateBurst == 0 {
+		cfg.Server.Limits.RateBurst = cmd.Int("rate-limit-burst")
+	}
+	if cmd.IsSet("max-concurrent-scrapes") {
+		cfg.Server.Limits.MaxConcurrentScrapes = cmd.Int("max-concurrent-scrapes")
+	}
+
+	overrideStrings(cmd, "listen", &cfg.Server.Listen)
+	overrideString(cmd, "unix-socket-mode", &cfg.Server.UnixSocketMode)
+	overrideString(cmd, "route-prefix", &cfg.Server.RoutePrefix)
+	overrideString(cmd, "metrics-path", &cfg.Server.MetricsPath)
+	if cmd.IsSet("enable-lifecycle") {
+		cfg.Server.EnableLifecycle = cmd.Bool("enable-lifecycle")
+	}
+	overrideTimeout(cmd, "read-timeout", &cfg.Server.Timeouts.Read)
+	overrideTimeout(cmd, "write-timeout", &cfg.Server.Timeouts.Write)
+	overrideTimeout(cmd, "idle-timeout", &cfg.Server.Timeouts.Idle)
+	overrideTimeout(cmd, "shutdown-grace-period", &cfg.Server.Timeouts.ShutdownGracePeriod)
+
+	// Constant labels from flags are added to the ones of the file.
+	for _, label := range cmd.StringSlice("const-label") {
+		name, value, ok := strings.Cut(label, "=")
+		if !ok {
+			return fmt.Errorf("invalid const label %q: expected <name>=<value>", label)
+		}
+
+		if cfg.ConstLabels == nil {
+			cfg.ConstLabels = make(map[string]string)
+		}
+		cfg.ConstLabels[name] = value
+	}
+
+	if cmd.IsSet("no-go-metrics") {
+		cfg.ExporterMetrics.NoGoMetrics = cmd.Bool("no-go-metrics")
+	}
+	if cmd.IsSet("no-process-metrics") {
+		cfg.ExporterMetrics.NoProcessMetrics = cmd.Bool("no-process-metrics")
+	}
+	if cmd.IsSet("separate-exporter-metrics") {
+		cfg.ExporterMetrics.Separate = cmd.Bool("separate-exporter-metrics")
+	}
+
+	// A host and a context exclude each other, so one given on the command
+	// line replaces the other from the file.
+	if cmd.IsSet("docker-host") && !cmd.IsSet("docker-context") {
+		cfg.Docker.Context = ""
+	}
+	if cmd.IsSet("docker-context") && !cmd.IsSet("docker-host") {
+		cfg.Docker.Host = ""
+	}
+	overrideString(cmd, "docker-host", &cfg.Docker.Host)
+	overrideString(cmd, "docker-context", &cfg.Docker.Context)
+	overrideString(cmd, "docker-api-version", &cfg.Docker.APIVersion)
+	overrideString(cmd, "docker-ssh-key", &cfg.Docker.SSH.KeyFile)
+	overrideString(cmd, "docker-ssh-known-hosts", &cfg.Docker.SSH.KnownHostsFile)
+
+	if cmd.Bool("docker-tls") || cmd.IsSet("docker-tls-verify") ||
+		cmd.String("docker-tls-ca") != "" ||
+		cmd.String("docker-tls-cert") != "" ||
+		cmd.String("docker-tls-key") != "" {
+		if cfg.Docker.TLS == nil {
+			cfg.Docker.TLS = &config.TLSConfig{}
+		}
+
+		overrideString(cmd, "docker-tls-ca", &cfg.Docker.TLS.CAFile)
+		overrideString(cmd, "docker-tls-cert", &cfg.Docker.TLS.CertFile)
+		overrideString(cmd, "docker-tls-key", &cfg.Docker.TLS.KeyFile)
+		if cmd.IsSet("docker-tls-verify") {
+			cfg.Docker.TLS.InsecureSkipVerify = !cmd.Bool("docker-tls-verify")
+		}
+	}
+
+	overrideString(cmd, "runtime", &cfg.Runtime)
+	overrideString(cmd, "containerd-address", &cfg.Containerd.Address)
+	overrideStrings(cmd, "containerd-namespace", &cfg.Containerd.Namespaces)
+
+	overrideString(cmd, "engine", &cfg.Engine)
+	overrideString(cmd, "ignore-label", &cfg.IgnoreLabel)
+	overrideStrings(cmd, "container-label", &cfg.ContainerLabels)
+	overrideStrings(cmd, "identity-label", &cfg.IdentityLabels)
+	if cmd.IsSet("no-deprecated-metrics") {
+		cfg.NoDeprecatedMetrics = cmd.Bool("no-deprecated-metrics")
+	}
+	overrideDuration(cmd, "scrape-timeout", &cfg.ScrapeTimeout)
+
+	for _, name := range collector.CollectorNames {
+		if flag := "collector." + name; cmd.IsSet(flag) {
+			if cfg.Collectors == nil {
+				cfg.Collectors = make(map[string]bool)
+			}
+			cfg.Collectors[name] = cmd.Bool(flag)
+		}
+	}
+
+	overrideStrings(cmd, "include-name", &cfg.Filters.Names)
+	overrideStrings(cmd, "exclude-name", &cfg.Filters.ExcludeNames)
+	overrideStrings(cmd, "include-image", &cfg.Filters.Images)
+	overrideStrings(cmd, "exclude-image", &cfg.Filters.ExcludeImages)
+	overrideStrings(cmd, "include-compose-project", &cfg.Filters.ComposeProjects)
+	overrideStrings(cmd, "exclude-compose-project", &cfg.Filters.ExcludeComposeProjects)
+	overrideString(cmd, "label-selector", &cfg.Filters.LabelSelector)
+
+	return nil
+}
+
+func overrideString(cmd *cli.Command, name string, value *string) {
+	if cmd.IsSet(name) || *value == "" {
+		*value = cmd.String(name)
+	}
+}
+
+func overrideStrings(cmd *cli.Command, name string, value *[]string) {
+	if cmd.IsSet(name) || len(*value) == 0 {
+		*value = cmd.StringSlice(name)
+	}
+}
+
+func overrideDuration(cmd *cli.Command, name string, value *time.Duration) {
+	if cmd.IsSet(name) || *value == 0 {
+		*value = cmd.Duration(name)
+	}
+}
+
+// overrideTimeout sets a timeout of the config file from its flag, if the flag
+// is set or the config file leaves the timeout unset. Unlike overrideDuration,
+// a zero timeout of the config file is kept, as it disables the timeout.
+func overrideTimeout(cmd *cli.Command, name string, value **time.Duration) {
+	if cmd.IsSet(name) || *value == nil {
+		d := cmd.Duration(name)
+		*value = &d
+	}
+}
+
+// logLevel returns the log level of the flag or, unless the flag is set, of
+// the config file.
+func logLevel(cmd *cli.Command, cfg *config.Config) log.Level {
+	if cmd.IsSet("log-level") || cfg.LogLevel == "" {
+		return parseLogLevel(cmd.String("log-level"))
+	}
+
+	return parseLogLevel(cfg.LogLevel)
+}
+
+// dockerConfig builds the Docker connection settings, falling back to the
+// selected Docker CLI context when no host is given.
+func dockerConfig(cfg *config.Config) (docker.Config, error) {
+	dockerCfg := docker.Config{
+		Host:       cfg.Docker.Host,
+		APIVersion: cfg.Docker.APIVersion,
+		SSH: docker.SSHConfig{
+			KeyFile:        cfg.Docker.SSH.KeyFile,
+			KnownHostsFile: cfg.Docker.SSH.KnownHostsFile,
+		},
+	}
+
+	name := cfg.Docker.Context
+	if name != "" && dockerCfg.Host != "" {
+		return dockerCfg, errors.New("conflicting options: either specify --docker-host or --docker-context, not both")
+	}
+
+	if dockerCfg.Host == "" {
+		configDir := docker.ConfigDir()
+
+		if name == "" {
+			var err error
+			if name, err = docker.CurrentContext(configDir); err != nil {
+				return dockerCfg, err
+			}
+		}
+
+		if name != docker.DefaultContext {
+			ctxConfig, err := docker.LoadContext(configDir, name)
+			if err != nil {
+				return dockerCfg, err
+			}
+
+			log.WithField("context", name).
+				Info("using docker context")
+
+			dockerCfg.Host = ctxConfig.Host
+			dockerCfg.TLS = ctxConfig.TLS
+		} else if os.Getenv(client.EnvOverrideHost) == "" && cfg.Engine != collector.EngineDocker {
+			if host := docker.PodmanHost(); host != "" {
+				log.WithField("host", host).
+					Info("docker socket not found - using podman socket")
+				dockerCfg.Host = host
+			}
+		}
+	}
+
+	if tls := cfg.Docker.TLS; tls != nil {
+		dockerCfg.TLS = &docker.TLSConfig{
+			CAFile:             tls.CAFile,
+			CertFile:           tls.CertFile,
+			KeyFile:            tls.KeyFile,
+			InsecureSkipVerify: tls.InsecureSkipVerify,
+		}
+	}
+
+	return dockerCfg, nil
+}
+
+// newCollector creates the collector for the configured container runtime.
+func newCollector(cfg *config.Config, clk clock.Clock) (*collector.DockerCollector, error) {
+	opts, err := cfg.Options()
+	if err != nil {
+		return nil, err
+	}
+	opts.Timeout = cfg.ScrapeTimeout
+
+	if cfg.Runtime == collector.RuntimeContainerd {
+		rt, err := collector.NewContainerdRuntime(cfg.Containerd.Address, cfg.Containerd.Namespaces)
+		if err != nil {
+			return nil, err
+		}
+
+		log.WithField("address", cfg.Containerd.Address).
+			Info("using containerd runtime")
+
+		return collector.NewWithRuntime(rt, clk, opts), nil
+	}
+
+	dockerCfg, err := dockerConfig(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("invalid docker connection settings: %w", err)
+	}
+
+	return collector.NewDockerCollector(clk, dockerCfg, opts)
+}
@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/urfave/cli/v3"
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/term"
+)
+
+// hashPasswordCommand prints the bcrypt hash of a password for the basic auth
+// users of the config file.
+var hashPasswordCommand = &cli.Command{
+	Name:  "hash-password",
+	Usage: "Print the bcrypt hash of a password for server.basic_auth_users. The password is read from the terminal or from the first line of stdin.",
+	Flags: []cli.Flag{
+		&cli.IntFlag{
+			Name:  "cost",
+			Usage: "The bcrypt cost.",
+			Value: bcrypt.DefaultCost,
+		},
+	},
+	Action: hashPassword,
+}
+
+func hashPassword(_ context.Context, cmd *cli.Command) error {
+	password, err := readPassword()
+	if err != nil {
+		return err
+	}
+
+	hash, err := bcrypt.GenerateFromPassword(password, cmd.Int("cost"))
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(cmd.Root().Writer, string(hash))
+
+	return nil
+}
+
+// readPassword reads the password without echoing it when stdin is a
+// terminal.
+func readPassword() ([]byte, error) {
+	var password []byte
+	if fd := int(os.Stdin.Fd()); term.IsTerminal(fd) {
+		fmt.Fprint(os.Stderr, "Password: ")
+		raw, err := term.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return nil, err
+		}
+		password = raw
+	} else {
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			return nil, fmt.Errorf("failed to read password: %w", err)
+		}
+		password = []byte(strings.TrimRight(line, "\r\n"))
+	}
+
+	if len(password) == 0 {
+		return nil, errors.New("password must not be empty")
+	}
+
+	return password, nil
+}
@@ -3,15 +3,18 @@ package cmd
 import (
 	"context"
 	"fmt"
-	"net"
-	"net/http"
 	"os"
+	"os/signal"
 	"strings"
+	"syscall"
 
 	"github.com/davidborzek/docker-exporter/internal/clock"
 	"github.com/davidborzek/docker-exporter/internal/collector"
+	"github.com/davidborzek/docker-exporter/internal/config"
 	"github.com/davidborzek/docker-exporter/internal/handler"
+	"github.com/davidborzek/docker-exporter/internal/server"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
 	"github.com/urfave/cli/v3"
 
 	log "github.com/sirupsen/logrus"
@@ -30,11 +33,169 @@ var (
 			Usage:   "The host of docker exporter server",
 			Sources: cli.EnvVars("DOCKER_EXPORTER_HOST"),
 		},
+		&cli.StringSliceFlag{
+			Name:    "listen",
+			Usage:   "Address to listen on instead of --host and --port: host:port, unix:<path> for a unix domain socket, or systemd for the sockets passed by systemd socket activation. Repeatable.",
+			Sources: cli.EnvVars("DOCKER_EXPORTER_LISTEN"),
+		},
+		&cli.StringFlag{
+			Name:    "unix-socket-mode",
+			Usage:   "Octal file mode of unix domain sockets listened on.",
+			Value:   "0660",
+			Sources: cli.EnvVars("DOCKER_EXPORTER_UNIX_SOCKET_MODE"),
+		},
+		&cli.StringFlag{
+			Name:    "route-prefix",
+			Usage:   "Path prefix of every endpoint, e.g. /docker-exporter, for reverse proxies routing by path.",
+			Sources: cli.EnvVars("DOCKER_EXPORTER_ROUTE_PREFIX"),
+		},
+		&cli.StringFlag{
+			Name:    "metrics-path",
+			Usage:   "Path of the container metrics below --route-prefix.",
+			Value:   handler.DefaultMetricsPath,
+			Sources: cli.EnvVars("DOCKER_EXPORTER_METRICS_PATH"),
+		},
+		&cli.BoolFlag{
+			Name:    "enable-lifecycle",
+			Usage:   "Serve the /-/reload endpoint, which reloads the configuration on POST requests.",
+			Sources: cli.EnvVars("DOCKER_EXPORTER_ENABLE_LIFECYCLE"),
+		},
 		&cli.StringFlag{
 			Name:    "auth-token",
 			Usage:   "Optional auth token for the docker exporter server. If no token is set authentication is disabled.",
 			Sources: cli.EnvVars("DOCKER_EXPORTER_AUTH_TOKEN"),
 		},
+		&cli.StringFlag{
+			Name:    "auth-token-file",
+			Usage:   "Optional YAML file of named bearer tokens accepted next to --auth-token. The file is read again when it changes.",
+			Sources: cli.EnvVars("DOCKER_EXPORTER_AUTH_TOKEN_FILE"),
+		},
+		&cli.StringFlag{
+			Name:    "jwt-jwks-file",
+			Usage:   "JSON Web Key Set file verifying JWTs accepted as bearer tokens. Reloaded when the file changes.",
+			Sources: cli.EnvVars("DOCKER_EXPORTER_JWT_JWKS_FILE"),
+		},
+		&cli.StringFlag{
+			Name:    "jwt-jwks-url",
+			Usage:   "URL of a JSON Web Key Set verifying JWTs accepted as bearer tokens, e.g. of an OIDC provider. Fetched again every 5 minutes.",
+			Sources: cli.EnvVars("DOCKER_EXPORTER_JWT_JWKS_URL"),
+		},
+		&cli.StringFlag{
+			Name:    "jwt-issuer",
+			Usage:   "Issuer (iss claim) required for JWTs.",
+			Sources: cli.EnvVars("DOCKER_EXPORTER_JWT_ISSUER"),
+		},
+		&cli.StringSliceFlag{
+			Name:    "jwt-audience",
+			Usage:   "Audience accepted in the aud claim of JWTs. Repeatable; a JWT must name one of them.",
+			Sources: cli.EnvVars("DOCKER_EXPORTER_JWT_AUDIENCES"),
+		},
+		&cli.StringSliceFlag{
+			Name:    "jwt-algorithm",
+			Usage:   "Signature algorithm accepted for JWTs, e.g. RS256 or ES256. Repeatable. Defaults to all supported asymmetric algorithms.",
+			Sources: cli.EnvVars("DOCKER_EXPORTER_JWT_ALGORITHMS"),
+		},
+		&cli.StringFlag{
+			Name:    "tls-cert",
+			Usage:   "Certificate served by the exporter. Enables HTTPS together with --tls-key. Reloaded when the file changes.",
+			Sources: cli.EnvVars("DOCKER_EXPORTER_TLS_CERT"),
+		},
+		&cli.StringFlag{
+			Name:    "tls-key",
+			Usage:   "Private key of the certificate served by the exporter.",
+			Sources: cli.EnvVars("DOCKER_EXPORTER_TLS_KEY"),
+		},
+		&cli.StringFlag{
+			Name:    "tls-client-ca",
+			Usage:   "CA certificates verifying client certificates. Enables mutual TLS: clients without a valid certificate are rejected.",
+			Sources: cli.EnvVars("DOCKER_EXPORTER_TLS_CLIENT_CA"),
+		},
+		&cli.StringFlag{
+			Name:    "tls-min-version",
+			Usage:   "Minimum TLS version accepted by the exporter: 1.0, 1.1, 1.2 or 1.3.",
+			Value:   server.DefaultMinTLSVersion,
+			Sources: cli.EnvVars("DOCKER_EXPORTER_TLS_MIN_VERSION"),
+		},
+		&cli.StringSliceFlag{
+			Name:    "tls-cipher-suite",
+			Usage:   "Cipher suite accepted for TLS 1.2 and lower, e.g. TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256. Repeatable. Defaults to Go's secure cipher suites.",
+			Sources: cli.EnvVars("DOCKER_EXPORTER_TLS_CIPHER_SUITES"),
+		},
+		&cli.StringSliceFlag{
+			Name:    "allow-cidr",
+			Usage:   "Network, e.g. 10.0.0.0/8, allowed to reach the exporter. Repeatable. Other clients are rejected on every endpoint but /health and /ready.",
+			Sources: cli.EnvVars("DOCKER_EXPORTER_ALLOW_CIDRS"),
+		},
+		&cli.StringSliceFlag{
+			Name:    "deny-cidr",
+			Usage:   "Network rejected on every endpoint but /health and /ready, even when allowed. Repeatable.",
+			Sources: cli.EnvVars("DOCKER_EXPORTER_DENY_CIDRS"),
+		},
+		&cli.StringSliceFlag{
+			Name:    "trusted-proxy",
+			Usage:   "Network of reverse proxies whose X-Forwarded-For header names the client. Repeatable.",
+			Sources: cli.EnvVars("DOCKER_EXPORTER_TRUSTED_PROXIES"),
+		},
+		&cli.FloatFlag{
+			Name:    "rate-limit",
+			Usage:   "Scrapes of /metrics per second allowed per client on average. 0 disables the limit.",
+			Sources: cli.EnvVars("DOCKER_EXPORTER_RATE_LIMIT"),
+		},
+		&cli.IntFlag{
+			Name:    "rate-limit-burst",
+			Usage:   "Scrapes of /metrics a client may make at once with --rate-limit.",
+			Value:   1,
+			Sources: cli.EnvVars("DOCKER_EXPORTER_RATE_LIMIT_BURST"),
+		},
+		&cli.IntFlag{
+			Name:    "max-concurrent-scrapes",
+			Usage:   "Scrapes of /metrics served at the same time. 0 disables the limit.",
+			Sources: cli.EnvVars("DOCKER_EXPORTER_MAX_CONCURRENT_SCRAPES"),
+		},
+		&cli.DurationFlag{
+			Name:    "read-timeout",
+			Usage:   "Time to read a request. 0 disables the timeout.",
+			Value:   server.DefaultReadTimeout,
+			Sources: cli.EnvVars("DOCKER_EXPORTER_READ_TIMEOUT"),
+		},
+		&cli.DurationFlag{
+			Name:    "write-timeout",
+			Usage:   "Time to serve a request, which must cover the slowest scrape. 0 disables the timeout.",
+			Value:   server.DefaultWriteTimeout,
+			Sources: cli.EnvVars("DOCKER_EXPORTER_WRITE_TIMEOUT"),
+		},
+		&cli.DurationFlag{
+			Name:    "idle-timeout",
+			Usage:   "Time a keep-alive connection waits for its next request. 0 disables the timeout.",
+			Value:   server.DefaultIdleTimeout,
+			Sources: cli.EnvVars("DOCKER_EXPORTER_IDLE_TIMEOUT"),
+		},
+		&cli.DurationFlag{
+			Name:    "shutdown-grace-period",
+			Usage:   "Time in-flight requests may take to finish on SIGTERM or SIGINT before they are cut. 0 waits for them however long they take.",
+			Value:   server.DefaultShutdownGracePeriod,
+			Sources: cli.EnvVars("DOCKER_EXPORTER_SHUTDOWN_GRACE_PERIOD"),
+		},
+		&cli.StringSliceFlag{
+			Name:    "const-label",
+			Usage:   "Label in the form name=value added to every exported metric, e.g. datacenter=fra1. Repeatable, or comma-separated via the environment variable.",
+			Sources: cli.EnvVars("DOCKER_EXPORTER_CONST_LABELS"),
+		},
+		&cli.BoolFlag{
+			Name:    "no-go-metrics",
+			Usage:   "Stop exporting the go_* runtime metrics of the exporter.",
+			Sources: cli.EnvVars("DOCKER_EXPORTER_NO_GO_METRICS"),
+		},
+		&cli.BoolFlag{
+			Name:    "no-process-metrics",
+			Usage:   "Stop exporting the process_* metrics of the exporter.",
+			Sources: cli.EnvVars("DOCKER_EXPORTER_NO_PROCESS_METRICS"),
+		},
+		&cli.BoolFlag{
+			Name:    "separate-exporter-metrics",
+			Usage:   "Serve the exporter's own metrics (docker_exporter_*, go_* and process_*) on /metrics/exporter instead of /metrics.",
+			Sources: cli.EnvVars("DOCKER_EXPORTER_SEPARATE_EXPORTER_METRICS"),
+		},
 		&cli.StringFlag{
 			Name:    "log-level",
 			Usage:   "Log level",
@@ -44,7 +205,7 @@ var (
 		&cli.StringFlag{
 			Name:    "ignore-label",
 			Usage:   "Label to ignore containers",
-			Value:   "docker-exporter.ignore",
+			Value:   collector.DefaultIgnoreLabel,
 			Sources: cli.EnvVars("DOCKER_EXPORTER_IGNORE_LABEL"),
 		},
 		&cli.StringSliceFlag{
@@ -52,9 +213,155 @@ var (
 			Usage:   "Docker label to expose as a `docker_container_labels` metric. Repeatable, or comma-separated via the environment variable.",
 			Sources: cli.EnvVars("DOCKER_EXPORTER_CONTAINER_LABELS"),
 		},
+		&cli.StringSliceFlag{
+			Name:    "identity-label",
+			Usage:   "Identity label attached to every container metric: container_id, container_short_id, compose_project, compose_service, compose_container_number, swarm_service or swarm_task. Repeatable, or comma-separated via the environment variable.",
+			Sources: cli.EnvVars("DOCKER_EXPORTER_IDENTITY_LABELS"),
+		},
+		&cli.StringSliceFlag{
+			Name:    "include-name",
+			Usage:   "Only collect containers whose name matches this regular expression. Repeatable.",
+			Sources: cli.EnvVars("DOCKER_EXPORTER_INCLUDE_NAMES"),
+		},
+		&cli.StringSliceFlag{
+			Name:    "exclude-name",
+			Usage:   "Do not collect containers whose name matches this regular expression. Repeatable.",
+			Sources: cli.EnvVars("DOCKER_EXPORTER_EXCLUDE_NAMES"),
+		},
+		&cli.StringSliceFlag{
+			Name:    "include-image",
+			Usage:   "Only collect containers whose image reference matches this glob, e.g. ghcr.io/acme/*. Repeatable.",
+			Sources: cli.EnvVars("DOCKER_EXPORTER_INCLUDE_IMAGES"),
+		},
+		&cli.StringSliceFlag{
+			Name:    "exclude-image",
+			Usage:   "Do not collect containers whose image reference matches this glob. Repeatable.",
+			Sources: cli.EnvVars("DOCKER_EXPORTER_EXCLUDE_IMAGES"),
+		},
+		&cli.StringSliceFlag{
+			Name:    "include-compose-project",
+			Usage:   "Only collect containers of this Docker Compose project. Repeatable.",
+			Sources: cli.EnvVars("DOCKER_EXPORTER_INCLUDE_COMPOSE_PROJECTS"),
+		},
+		&cli.StringSliceFlag{
+			Name:    "exclude-compose-project",
+			Usage:   "Do not collect containers of this Docker Compose project. Repeatable.",
+			Sources: cli.EnvVars("DOCKER_EXPORTER_EXCLUDE_COMPOSE_PROJECTS"),
+		},
+		&cli.StringFlag{
+			Name:    "label-selector",
+			Usage:   "Only collect containers whose labels match the selector, e.g. env=prod,tier!=batch.",
+			Sources: cli.EnvVars("DOCKER_EXPORTER_LABEL_SELECTOR"),
+		},
+		&cli.StringFlag{
+			Name:    "docker-host",
+			Usage:   "Docker daemon to connect to, e.g. unix:///var/run/docker.sock, tcp://host:2376 or ssh://user@host. Defaults to DOCKER_HOST.",
+			Sources: cli.EnvVars("DOCKER_EXPORTER_DOCKER_HOST"),
+		},
+		&cli.StringFlag{
+			Name:    "docker-context",
+			Usage:   "Docker CLI context to connect to. Defaults to DOCKER_CONTEXT or the current context of the Docker CLI config.",
+			Sources: cli.EnvVars("DOCKER_EXPORTER_DOCKER_CONTEXT"),
+		},
+		&cli.StringFlag{
+			Name:    "docker-api-version",
+			Usage:   "Pin the Docker API version (e.g. 1.43) instead of negotiating it with the daemon.",
+			Sources: cli.EnvVars("DOCKER_EXPORTER_DOCKER_API_VERSION"),
+		},
+		&cli.BoolFlag{
+			Name:    "docker-tls",
+			Usage:   "Use TLS to connect to the Docker daemon. Implied by any of the other docker-tls-* flags.",
+			Sources: cli.EnvVars("DOCKER_EXPORTER_DOCKER_TLS"),
+		},
+		&cli.StringFlag{
+			Name:    "docker-tls-ca",
+			Usage:   "CA certificate used to verify the Docker daemon.",
+			Sources: cli.EnvVars("DOCKER_EXPORTER_DOCKER_TLS_CA"),
+		},
+		&cli.StringFlag{
+			Name:    "docker-tls-cert",
+			Usage:   "Client certificate presented to the Docker daemon.",
+			Sources: cli.EnvVars("DOCKER_EXPORTER_DOCKER_TLS_CERT"),
+		},
+		&cli.StringFlag{
+			Name:    "docker-tls-key",
+			Usage:   "Private key of the client certificate.",
+			Sources: cli.EnvVars("DOCKER_EXPORTER_DOCKER_TLS_KEY"),
+		},
+		&cli.BoolFlag{
+			Name:    "docker-tls-verify",
+			Usage:   "Verify the Docker daemon's certificate.",
+			Value:   true,
+			Sources: cli.EnvVars("DOCKER_EXPORTER_DOCKER_TLS_VERIFY"),
+		},
+		&cli.StringFlag{
+			Name:    "docker-ssh-key",
+			Usage:   "Private key used for ssh:// Docker hosts. Defaults to the agent on SSH_AUTH_SOCK.",
+			Sources: cli.EnvVars("DOCKER_EXPORTER_DOCKER_SSH_KEY"),
+		},
+		&cli.StringFlag{
+			Name:    "docker-ssh-known-hosts",
+			Usage:   "known_hosts file used to verify ssh:// Docker hosts. Defaults to ~/.ssh/known_hosts.",
+			Sources: cli.EnvVars("DOCKER_EXPORTER_DOCKER_SSH_KNOWN_HOSTS"),
+		},
+		&cli.StringFlag{
+			Name:    "runtime",
+			Usage:   "Container runtime to collect from: docker or containerd.",
+			Value:   collector.RuntimeDocker,
+			Sources: cli.EnvVars("DOCKER_EXPORTER_RUNTIME"),
+		},
+		&cli.StringFlag{
+			Name:    "containerd-address",
+			Usage:   "Socket of the containerd daemon, used with --runtime containerd.",
+			Value:   collector.DefaultContainerdAddress,
+			Sources: cli.EnvVars("DOCKER_EXPORTER_CONTAINERD_ADDRESS"),
+		},
+		&cli.StringSliceFlag{
+			Name:    "containerd-namespace",
+			Usage:   "containerd namespace to collect. Repeatable, or comma-separated via the environment variable. Defaults to all namespaces.",
+			Sources: cli.EnvVars("DOCKER_EXPORTER_CONTAINERD_NAMESPACES"),
+		},
+		&cli.StringFlag{
+			Name:    "engine",
+			Usage:   "Container engine behind the Docker API: auto, docker or podman. auto detects Podman and enables its compatibility mode.",
+			Value:   collector.EngineAuto,
+			Sources: cli.EnvVars("DOCKER_EXPORTER_ENGINE"),
+		},
+		&cli.BoolFlag{
+			Name:    "no-deprecated-metrics",
+			Usage:   "Stop exporting the deprecated metrics that duplicate the current metric families.",
+			Sources: cli.EnvVars("DOCKER_EXPORTER_NO_DEPRECATED_METRICS"),
+		},
+		&cli.DurationFlag{
+			Name:    "scrape-timeout",
+			Usage:   "Time a scrape of the containers may take, including every request to the container runtime.",
+			Value:   collector.DefaultScrapeTimeout,
+			Sources: cli.EnvVars("DOCKER_EXPORTER_SCRAPE_TIMEOUT"),
+		},
+		&cli.StringFlag{
+			Name:    "config-file",
+			Usage:   "Optional path to a YAML config file covering every flag, relabeling rules and the modules available to the /probe endpoint. Flags override the file. It is reloaded on SIGHUP or /-/reload; server, const_labels and exporter_metrics changes need a restart.",
+			Sources: cli.EnvVars("DOCKER_EXPORTER_CONFIG_FILE"),
+		},
 	}
 )
 
+// collectorFlags returns a --collector.<name> flag for every metric family
+// that can be disabled.
+func collectorFlags() []cli.Flag {
+	collectorFlags := make([]cli.Flag, 0, len(collector.CollectorNames))
+	for _, name := range collector.CollectorNames {
+		collectorFlags = append(collectorFlags, &cli.BoolFlag{
+			Name:    "collector." + name,
+			Usage:   fmt.Sprintf("Collect the %s metrics of running containers.", name),
+			Value:   true,
+			Sources: cli.EnvVars("DOCKER_EXPORTER_COLLECTOR_" + strings.ToUpper(name)),
+		})
+	}
+
+	return collectorFlags
+}
+
 func parseLogLevel(level string) log.Level {
 	switch strings.ToLower(level) {
 	case "debug":
@@ -75,39 +382,207 @@ func parseLogLevel(level string) log.Level {
 	return log.InfoLevel
 }
 
-func start(_ context.Context, cmd *cli.Command) error {
+func start(ctx context.Context, cmd *cli.Command) error {
 	log.SetFormatter(&log.TextFormatter{
 		FullTimestamp: true,
 	})
 
-	log.SetLevel(
-		parseLogLevel(cmd.String("log-level")),
-	)
+	cfg, err := loadConfig(cmd)
+	if err != nil {
+		log.WithError(err).
+			Fatal("invalid configuration")
+	}
+
+	log.SetLevel(logLevel(cmd, cfg))
 
 	log.WithField("pid", os.Getpid()).
 		Info("docker prometheus exporter started")
 
-	token := cmd.String("auth-token")
-	if len(token) > 0 {
+	// The first SIGTERM or SIGINT shuts the exporter down gracefully, a
+	// second one terminates it at once.
+	ctx, stop := signal.NotifyContext(ctx, syscall.SIGTERM, os.Interrupt)
+	defer stop()
+	context.AfterFunc(ctx, stop)
+
+	if len(cfg.Server.AuthToken) > 0 || cfg.Server.TokenFile != "" ||
+		len(cfg.Server.BasicAuthUsers) > 0 || cfg.Server.JWT != nil {
 		log.Info("authentication is enabled")
 	}
 
-	dc, err := collector.NewDockerCollector(clock.NewClock(), cmd.String("ignore-label"), cmd.StringSlice("container-label"))
+	clk := clock.NewClock()
+
+	dc, err := newCollector(cfg, clk)
 	if err != nil {
 		log.WithError(err).
 			Fatal("failed to create docker collector")
 	}
 
-	prometheus.MustRegister(dc)
+	modules, err := cfg.ProbeModules()
+	if err != nil {
+		log.WithError(err).
+			Fatal("failed to load config file")
+	}
+
+	r := &reloader{
+		cmd:       cmd,
+		started:   cfg,
+		clock:     clk,
+		collector: collector.NewReloadable(dc),
+		prober:    collector.NewProber(clk, modules),
+	}
+
+	regs := newRegistries(cfg, r.collector)
+
+	go r.reloadOnSignal(ctx)
+
+	access, err := handler.NewAccessList(cfg.Server.Access.Handler())
+	if err != nil {
+		log.WithError(err).
+			Fatal("invalid access list")
+	}
+
+	opts := []handler.Option{
+		handler.WithClock(clk),
+		handler.WithCollector(regs.containers),
+		handler.WithScoper(r.collector),
+		handler.WithConstLabels(cfg.ConstLabels),
+		handler.WithRegisterer(regs.exporterRegisterer),
+		handler.WithBasicAuthUsers(cfg.Server.BasicAuthUsers),
+		handler.WithAccessList(access),
+		handler.WithLimits(cfg.Server.Limits.Handler()),
+		handler.WithRoutePrefix(cfg.Server.RoutePrefix),
+		handler.WithMetricsPath(cfg.Server.MetricsPath),
+		handler.WithVersion(cmd.Root().Version),
+		handler.WithReadinessChecks(
+			handler.Check{Name: "runtime", Check: r.collector.Ping},
+			handler.Check{Name: "engine", Check: r.collector.Warm},
+		),
+	}
+	if regs.exporter != nil {
+		opts = append(opts, handler.WithExporterMetrics(regs.exporter))
+	}
+	// Anyone reaching /-/reload can reload the configuration when
+	// authentication is off, so it is only served on request.
+	if cfg.Server.EnableLifecycle {
+		opts = append(opts, handler.WithReloader(r.Reload))
+	}
+	// Probes open connections to the targets of their clients, so /probe is
+	// only served when modules restricting them are configured.
+	if len(modules) > 0 {
+		opts = append(opts, handler.WithProber(r.prober))
+	}
+	if cfg.Server.TokenFile != "" {
+		tokens, err := handler.LoadTokenFile(cfg.Server.TokenFile)
+		if err != nil {
+			log.WithError(err).
+				Fatal("failed to load token file")
+		}
+		opts = append(opts, handler.WithTokenFile(tokens))
+	}
+
+	if cfg.Server.JWT != nil {
+		validator, err := handler.NewJWTValidator(cfg.Server.JWT.Handler())
+		if err != nil {
+			log.WithError(err).
+				Fatal("invalid JWT configuration")
+		}
+		opts = append(opts, handler.WithJWTValidator(validator))
+	}
+
+	h := handler.New(regs.metrics, cfg.Server.AuthToken, opts...)
+
+	mode, err := server.ParseUnixSocketMode(cfg.Server.UnixSocketMode)
+	if err != nil {
+		log.WithError(err).
+			Fatal("invalid unix socket mode")
+	}
+
+	listeners, err := server.Listen(cfg.Server.ListenAddresses(), mode)
+	if err != nil {
+		log.WithError(err).
+			Fatal("failed to listen")
+	}
+
+	timeouts := cfg.Server.Timeouts.Server()
+	srv := server.New(h, timeouts)
+
+	if cfg.Server.TLS != nil {
+		if srv.TLSConfig, err = server.NewTLSConfig(cfg.Server.TLS.Server()); err != nil {
+			log.WithError(err).
+				Fatal("invalid TLS configuration")
+		}
+	}
 
-	h := handler.New(token)
+	for _, ln := range listeners {
+		entry := log.WithField("addr", ln.Addr().String()).
+			WithField("network", ln.Addr().Network())
 
-	addr := net.JoinHostPort(
-		cmd.String("host"), cmd.String("port"))
-	log.WithField("addr", addr).
-		Infof("starting the http server")
+		if cfg.Server.TLS == nil {
+			entry.Info("starting the http server")
+			continue
+		}
+
+		entry.WithField("mtls", cfg.Server.TLS.ClientCAFile != "").
+			Info("starting the https server")
+	}
 
-	return http.ListenAndServe(addr, h)
+	err = server.Run(ctx, srv, listeners, timeouts.ShutdownGracePeriod)
+
+	if closeErr := r.collector.Close(); closeErr != nil {
+		log.WithError(closeErr).
+			Warn("failed to close the collector")
+	}
+
+	if err != nil {
+		return err
+	}
+
+	log.Info("docker prometheus exporter stopped")
+
+	return nil
+}
+
+// registries are the registries served by the handler.
+type registries struct {
+	// metrics is served on /metrics.
+	metrics *prometheus.Registry
+	// exporter is served on /metrics/exporter. It is nil unless the exporter
+	// metrics are served separately.
+	exporter *prometheus.Registry
+	// exporterRegisterer registers exporter metrics with the registry
+	// serving them.
+	exporterRegisterer prometheus.Registerer
+	// containers returns the collector of the container metrics served on
+	// /metrics next to those of metrics, collected for each scrape with its
+	// context.
+	containers func(ctx context.Context) prometheus.Collector
+}
+
+// newRegistries registers the collectors selected by cfg. Every metric is
+// registered through a registerer adding the constant labels.
+func newRegistries(cfg *config.Config, c *collector.Reloadable) registries {
+	regs := registries{metrics: prometheus.NewRegistry()}
+	registerer := prometheus.WrapRegistererWith(cfg.ConstLabels, regs.metrics)
+
+	regs.exporterRegisterer = registerer
+	if cfg.ExporterMetrics.Separate {
+		regs.exporter = prometheus.NewRegistry()
+		regs.exporterRegisterer = prometheus.WrapRegistererWith(cfg.ConstLabels, regs.exporter)
+
+		regs.containers = c.ContainerMetrics
+		regs.exporterRegisterer.MustRegister(c.ExporterMetrics())
+	} else {
+		regs.containers = c.WithContext
+	}
+
+	if !cfg.ExporterMetrics.NoGoMetrics {
+		regs.exporterRegisterer.MustRegister(collectors.NewGoCollector())
+	}
+	if !cfg.ExporterMetrics.NoProcessMetrics {
+		regs.exporterRegisterer.MustRegister(collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
+	}
+
+	return regs
 }
 
 func Main(version string, args []string) {
@@ -115,11 +590,15 @@ func Main(version string, args []string) {
 		Name:    "Docker Prometheus exporter",
 		Usage:   "Export Docker metrics to prometheus format",
 		Action:  start,
-		Flags:   flags,
+		Flags:   append(flags, collectorFlags()...),
 		Version: version,
+		Commands: []*cli.Command{
+			hashPasswordCommand,
+		},
 	}
 
 	if err := cmd.Run(context.Background(), args); err != nil {
-		fmt.Println(err.Error())
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(1)
 	}
 }
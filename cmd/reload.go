@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/davidborzek/docker-exporter/internal/clock"
+	"github.com/davidborzek/docker-exporter/internal/collector"
+	"github.com/davidborzek/docker-exporter/internal/config"
+	"github.com/urfave/cli/v3"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// reloader re-reads the configuration and replaces the collectors built from
+// it. Scrapes running during a reload finish with the previous settings.
+type reloader struct {
+	cmd *cli.Command
+	// started is the configuration the exporter was started with, whose
+	// server, const_labels and exporter_metrics settings stay in effect.
+	started   *config.Config
+	clock     clock.Clock
+	collector *collector.Reloadable
+	prober    *collector.Prober
+
+	mu sync.Mutex
+}
+
+// Reload applies the current configuration. On error the previous
+// configuration stays in effect.
+func (r *reloader) Reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	cfg, err := loadConfig(r.cmd)
+	if err != nil {
+		return err
+	}
+
+	modules, err := cfg.ProbeModules()
+	if err != nil {
+		return err
+	}
+
+	dc, err := newCollector(cfg, r.clock)
+	if err != nil {
+		return err
+	}
+
+	log.SetLevel(logLevel(r.cmd, cfg))
+	r.collector.Swap(dc)
+	r.prober.SetModules(modules)
+
+	if changed := cfg.RestartChanges(r.started); len(changed) > 0 {
+		log.WithField("settings", strings.Join(changed, ", ")).
+			Warn("configuration reloaded - changes of these settings require a restart")
+		return nil
+	}
+
+	log.Info("configuration reloaded")
+
+	return nil
+}
+
+// reloadOnSignal reloads the configuration on every SIGHUP until ctx is done.
+func (r *reloader) reloadOnSignal(ctx context.Context) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			if err := r.Reload(); err != nil {
+				log.WithError(err).
+					Error("failed to reload configuration")
+			}
+		}
+	}
+}
@@ -2,286 +2,478 @@ package collector
 
 import (
 	"context"
-	"encoding/json"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/davidborzek/docker-exporter/internal/clock"
+	"github.com/davidborzek/docker-exporter/internal/docker"
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
-	"github.com/docker/docker/client"
 	"github.com/prometheus/client_golang/prometheus"
 	log "github.com/sirupsen/logrus"
+	"golang.org/x/sync/singleflight"
 )
 
+// DefaultIgnoreLabel is the container label used to exclude containers when
+// no other label is configured.
+const DefaultIgnoreLabel = "docker-exporter.ignore"
+
+// DefaultScrapeTimeout bounds a scrape when no other timeout is configured.
+// It is below the default write timeout of the server, so a slow container
+// runtime fails the scrape instead of the response.
+const DefaultScrapeTimeout = 30 * time.Second
+
+// Options configures what a DockerCollector collects.
+type Options struct {
+	// IgnoreLabel is the container label which, when set to true, excludes a
+	// container from collection.
+	IgnoreLabel string
+	// ContainerLabels are the Docker label keys exposed on the
+	// docker_container_labels metric for every container.
+	ContainerLabels []string
+	// IdentityLabels are the identity labels, e.g. IdentityComposeService,
+	// attached to every per-container metric next to the container name.
+	IdentityLabels []string
+	// Collectors toggles individual metric families.
+	Collectors Collectors
+	// Engine is the container engine behind the Docker API: EngineAuto
+	// (the default), EngineDocker or EnginePodman.
+	Engine string
+	// NoDeprecatedMetrics stops emitting the deprecated duplicates of the
+	// current metric families.
+	NoDeprecatedMetrics bool
+	// Relabeler reshapes the labels of every container, or drops containers,
+	// before their metrics are emitted. Nil keeps the labels unchanged.
+	Relabeler *Relabeler
+	// Filter selects the collected containers. Nil collects all containers
+	// not excluded by IgnoreLabel.
+	Filter *Filter
+	// Timeout bounds a scrape, including every request to the container
+	// runtime. Zero uses DefaultScrapeTimeout.
+	Timeout time.Duration
+}
+
 type DockerCollector struct {
-	ignoreLabel        string
-	client             *client.Client
+	ignoreLabel string
+	runtime     Runtime
+	// client is the Docker client of the docker runtime, used for the
+	// Podman specific requests. It is nil for other runtimes.
+	client docker.API
+	// api instruments the requests to the Docker API. It is nil for other
+	// runtimes.
+	api                *apiMetrics
 	clock              clock.Clock
 	containerLabelKeys []string
+	identityLabels     []string
+	relabeler          *Relabeler
+	collectors         Collectors
+	filter             *Filter
+	deprecatedMetrics  bool
+	timeout            time.Duration
+	// apiScheme is the URL scheme used for requests outside of the Docker
+	// client, i.e. Podman's libpod API.
+	apiScheme string
+
+	engineName string
+	detect     singleflight.Group
+	engineMu   sync.Mutex
+	detected   *engine
+	// detectFailed is when detecting the engine last failed, and fallback
+	// the engine used until it is detected again.
+	detectFailed time.Time
+	fallback     engine
+
+	exporter exporterMetrics
 }
 
-func NewDockerCollector(clk clock.Clock, ignoreLabel string, containerLabels []string) (*DockerCollector, error) {
-	client, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+func NewDockerCollector(clk clock.Clock, dockerConfig docker.Config, opts Options) (*DockerCollector, error) {
+	client, err := docker.NewClient(dockerConfig)
 	if err != nil {
 		return nil, err
 	}
 
-	return NewWithClient(client, clk, ignoreLabel, containerLabels), nil
+	dc := NewWithClient(client, clk, opts)
+	dc.apiScheme = dockerConfig.Scheme()
+
+	return dc, nil
+}
+
+func NewWithClient(client docker.API, clk clock.Clock, opts Options) *DockerCollector {
+	api := newAPIMetrics(clk)
+	c := NewWithRuntime(&dockerRuntime{client: client, metrics: api}, clk, opts)
+	c.client = client
+	c.api = api
+
+	return c
 }
 
-func NewWithClient(client *client.Client, clk clock.Clock, ignoreLabel string, containerLabels []string) *DockerCollector {
-	keys := make([]string, 0, len(containerLabels))
-	for _, k := range containerLabels {
+// NewWithRuntime creates a collector reading the containers of rt.
+func NewWithRuntime(rt Runtime, clk clock.Clock, opts Options) *DockerCollector {
+	keys := make([]string, 0, len(opts.ContainerLabels))
+	for _, k := range opts.ContainerLabels {
 		if k = strings.TrimSpace(k); k != "" {
 			keys = append(keys, k)
 		}
 	}
 
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = DefaultScrapeTimeout
+	}
+
 	return &DockerCollector{
-		client:             client,
+		runtime:            rt,
 		clock:              clk,
-		ignoreLabel:        ignoreLabel,
+		ignoreLabel:        opts.IgnoreLabel,
 		containerLabelKeys: keys,
+		identityLabels:     identityLabels(opts.IdentityLabels),
+		relabeler:          opts.Relabeler,
+		collectors:         opts.Collectors,
+		filter:             opts.Filter,
+		deprecatedMetrics:  !opts.NoDeprecatedMetrics,
+		apiScheme:          "http",
+		engineName:         opts.Engine,
+		timeout:            timeout,
 	}
 }
 
+// Close releases the connection to the container runtime.
+func (c *DockerCollector) Close() error {
+	return c.runtime.Close()
+}
+
 func (c *DockerCollector) Describe(_ chan<- *prometheus.Desc) {}
 
 func (c *DockerCollector) Collect(ch chan<- prometheus.Metric) {
+	c.collect(context.Background(), ch)
+}
+
+// collect scrapes all containers under ctx and collects the Docker API
+// metrics.
+func (c *DockerCollector) collect(ctx context.Context, ch chan<- prometheus.Metric) {
+	c.scrape(ctx, ch, ch, nil)
+	c.api.collect(ch)
+}
+
+// WithContext returns a collector of the same metrics as Collect, whose
+// requests to the container runtime are canceled with ctx, e.g. once the
+// client of a scrape goes away.
+func (c *DockerCollector) WithContext(ctx context.Context) prometheus.Collector {
+	return collectorFunc(func(ch chan<- prometheus.Metric) {
+		c.collect(ctx, ch)
+	})
+}
+
+// Scoped returns a collector of the metrics of the containers matching scope,
+// whose requests to the container runtime are canceled with ctx. The exporter
+// metrics describing its scrapes are discarded: they are not specific to the
+// scope, and are served with the unscoped metrics.
+func (c *DockerCollector) Scoped(ctx context.Context, scope *Filter) prometheus.Collector {
+	return collectorFunc(func(ch chan<- prometheus.Metric) {
+		self := make(chan prometheus.Metric)
+		done := make(chan struct{})
+
+		go func() {
+			for range self {
+			}
+			close(done)
+		}()
+
+		c.scrape(ctx, ch, self, scope)
+		close(self)
+		<-done
+	})
+}
+
+// scrape collects the metrics of the containers matching scope into ch and
+// the exporter metrics describing the scrape into self. A nil scope matches
+// all containers. The requests to the container runtime are canceled with ctx
+// or once the scrape takes longer than the timeout of the collector.
+func (c *DockerCollector) scrape(ctx context.Context, ch, self chan<- prometheus.Metric, scope *Filter) {
 	now := c.clock.Now()
 
-	ctx := context.Background()
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
 
-	containers, err := c.client.ContainerList(
-		ctx,
-		container.ListOptions{
-			All: true,
-		},
-	)
+	containers, err := c.runtime.ContainerList(ctx, c.filter.listFilters())
 
 	if err != nil {
 		log.WithError(err).
 			Error("failed to fetch container list")
-		c.collectScrapeError(ch)
+		c.collectScrapeError(self)
 
 	} else {
+		podman := c.podmanScrape(ctx, self)
+
 		var wg sync.WaitGroup
 
 		for _, container := range containers {
 			wg.Add(1)
-			go c.collectContainerMetrics(ctx, container, ch, &wg)
+			go c.collectContainerMetrics(ctx, container, podman, scope, ch, self, &wg)
 		}
 
 		wg.Wait()
 	}
 
 	scrapeSeconds := c.clock.Since(now).Seconds()
-	ch <- prometheus.MustNewConstMetric(scrapeDurationSeconds,
+	self <- prometheus.MustNewConstMetric(scrapeDurationSeconds,
 		prometheus.GaugeValue,
 		scrapeSeconds,
 	)
 
-	// Deprecated.
-	ch <- prometheus.MustNewConstMetric(scrapeDuration,
-		prometheus.GaugeValue,
-		scrapeSeconds,
-	)
+	if c.deprecatedMetrics {
+		self <- prometheus.MustNewConstMetric(scrapeDuration,
+			prometheus.GaugeValue,
+			scrapeSeconds,
+		)
+	}
 }
 
-func (c *DockerCollector) collectContainerMetrics(ctx context.Context, container types.Container, ch chan<- prometheus.Metric, wg *sync.WaitGroup) {
+func (c *DockerCollector) collectContainerMetrics(ctx context.Context, container types.Container, podman *podmanScrape, scope *Filter, ch, self chan<- prometheus.Metric, wg *sync.WaitGroup) {
 	defer wg.Done()
 
-	if c.isContainerIgnored(container) {
+	if c.isContainerIgnored(container) || !c.filter.Match(container) || !scope.Match(container) {
+		return
+	}
+
+	id, ok := c.containerIdentity(container)
+	if !ok {
 		return
 	}
 
-	name := containerName(container)
-	inspect, err := c.client.ContainerInspect(ctx, container.ID)
+	start := c.clock.Now()
+	defer func() {
+		self <- id.metric(containerCollectionDurationSeconds,
+			prometheus.GaugeValue,
+			c.clock.Now().Sub(start).Seconds(),
+		)
+	}()
+
+	inspect, err := c.runtime.ContainerInspect(ctx, container.ID)
 	if err != nil {
 		log.WithError(err).WithField("id", container.ID).
 			Error("error inspecting container")
-		c.collectScrapeError(ch)
+		c.collectScrapeError(self)
 		return
 	}
 
-	ch <- prometheus.MustNewConstMetric(containerInfo,
-		prometheus.GaugeValue,
-		1,
-		name,
-		inspect.Config.Image,
-		inspect.Image,
-	)
+	if podman != nil {
+		podman.normalizeInspect(&inspect)
+
+		ch <- id.metric(containerInfoPodman,
+			prometheus.GaugeValue,
+			1,
+			inspect.Config.Image,
+			inspect.Image,
+			EnginePodman,
+			podman.pods[container.ID],
+		)
+	} else {
+		ch <- id.metric(containerInfo,
+			prometheus.GaugeValue,
+			1,
+			inspect.Config.Image,
+			inspect.Image,
+			c.runtime.Name(),
+		)
+	}
 
-	c.collectContainerLabels(ch, name, container)
+	c.collectContainerLabels(ch, id, container)
 
-	ch <- prometheus.MustNewConstMetric(
-		containerStateMetric, prometheus.GaugeValue, 1, name, container.State,
+	ch <- id.metric(
+		containerStateMetric, prometheus.GaugeValue, 1, container.State,
 	)
 
 	// Lifecycle/status (from inspect, available for every state — emitted before
 	// the running-only block so stopped/restarting containers are still covered).
-	ch <- prometheus.MustNewConstMetric(
-		containerExitCode, prometheus.GaugeValue, float64(inspect.State.ExitCode), name,
+	ch <- id.metric(
+		containerExitCode, prometheus.GaugeValue, float64(inspect.State.ExitCode),
 	)
 
-	ch <- prometheus.MustNewConstMetric(
-		containerRestartsTotal, prometheus.CounterValue, float64(inspect.RestartCount), name,
+	ch <- id.metric(
+		containerRestartsTotal, prometheus.CounterValue, float64(inspect.RestartCount),
 	)
 
 	health := types.NoHealthcheck
 	if inspect.State.Health != nil {
 		health = inspect.State.Health.Status
 	}
-	ch <- prometheus.MustNewConstMetric(
-		containerHealth, prometheus.GaugeValue, 1, name, health,
+	ch <- id.metric(
+		containerHealth, prometheus.GaugeValue, 1, health,
 	)
 
 	if container.State != "running" {
 		return
 	}
 
-	uptime := c.calculateUptime(inspect)
-	ch <- prometheus.MustNewConstMetric(containerUptimeSeconds,
-		prometheus.GaugeValue,
-		uptime,
-		name,
-	)
+	// Runtimes that do not record when a container was started leave
+	// StartedAt empty; no uptime is reported for them.
+	if inspect.State.StartedAt != "" {
+		uptime := c.calculateUptime(inspect)
+		ch <- id.metric(containerUptimeSeconds,
+			prometheus.GaugeValue,
+			uptime,
+		)
+
+		if c.deprecatedMetrics {
+			ch <- id.metric(containerUptime,
+				prometheus.GaugeValue,
+				uptime,
+			)
+		}
+	}
 
-	// Deprecated.
-	ch <- prometheus.MustNewConstMetric(containerUptime,
-		prometheus.GaugeValue,
-		uptime,
-		name,
-	)
+	if !c.statsEnabled() {
+		return
+	}
 
-	stats, err := c.containerStats(ctx, container.ID)
+	stats, err := c.runtime.ContainerStats(ctx, container.ID)
 	if err != nil {
 		log.WithError(err).WithField("id", container.ID).
 			Error("error getting stats for container")
-		c.collectScrapeError(ch)
+		c.collectScrapeError(self)
 		return
 	}
 
-	c.cpuMetrics(ch, name, stats)
-	c.memoryMetrics(ch, name, stats)
-	c.networkMetrics(ch, name, stats)
-	c.blockIOMetrics(ch, name, stats)
-	c.pidsMetrics(ch, name, stats)
+	if podman != nil {
+		podman.normalizeStats(stats)
+	}
+
+	if c.collectors.Enabled(CollectorCPU) {
+		c.cpuMetrics(ch, id, stats)
+	}
+	if c.collectors.Enabled(CollectorMemory) {
+		c.memoryMetrics(ch, id, stats)
+	}
+	if c.collectors.Enabled(CollectorNetwork) {
+		c.networkMetrics(ch, id, stats)
+	}
+	if c.collectors.Enabled(CollectorBlockIO) {
+		c.blockIOMetrics(ch, id, stats)
+	}
+	if c.collectors.Enabled(CollectorPIDs) {
+		c.pidsMetrics(ch, id, stats)
+	}
 }
 
-func (c *DockerCollector) cpuMetrics(ch chan<- prometheus.Metric, name string, stats *container.StatsResponse) {
+func (c *DockerCollector) cpuMetrics(ch chan<- prometheus.Metric, id containerIdentity, stats *container.StatsResponse) {
 	onlineCPUs := getOnlineCPUs(stats)
 
-	ch <- prometheus.MustNewConstMetric(cpuUsageSecondsTotal,
+	ch <- id.metric(cpuUsageSecondsTotal,
 		prometheus.CounterValue,
 		float64(stats.CPUStats.CPUUsage.TotalUsage)/1e9,
-		name,
 	)
 
-	ch <- prometheus.MustNewConstMetric(cpuOnlineCPUs,
-		prometheus.GaugeValue,
-		onlineCPUs,
-		name,
-	)
+	// containerd does not report the online CPUs.
+	if onlineCPUs > 0 {
+		ch <- id.metric(cpuOnlineCPUs,
+			prometheus.GaugeValue,
+			onlineCPUs,
+		)
+	}
+
+	if !c.deprecatedMetrics {
+		return
+	}
 
-	// Deprecated.
 	cpuDelta := float64(stats.CPUStats.CPUUsage.TotalUsage) - float64(stats.PreCPUStats.CPUUsage.TotalUsage)
 	systemDelta := float64(stats.CPUStats.SystemUsage) - float64(stats.PreCPUStats.SystemUsage)
 	cpuPercent := 0.0
 	if systemDelta > 0.0 && cpuDelta > 0.0 {
 		cpuPercent = (cpuDelta / systemDelta) * onlineCPUs * 100.0
 	}
-	ch <- prometheus.MustNewConstMetric(cpuUsagePercentage,
+	ch <- id.metric(cpuUsagePercentage,
 		prometheus.GaugeValue,
 		cpuPercent,
-		name,
 	)
 }
 
-func (c *DockerCollector) memoryMetrics(ch chan<- prometheus.Metric, name string, stats *container.StatsResponse) {
+func (c *DockerCollector) memoryMetrics(ch chan<- prometheus.Metric, id containerIdentity, stats *container.StatsResponse) {
 	mem := calculateMemUsageUnixNoCache(stats.MemoryStats)
 	memLimit := float64(stats.MemoryStats.Limit)
 
-	memRatio := 0.0
-	if memLimit > 0 {
-		memRatio = mem / memLimit
-	}
-
-	ch <- prometheus.MustNewConstMetric(memoryLimitBytes,
+	ch <- id.metric(memoryUsageBytes,
 		prometheus.GaugeValue,
-		memLimit,
-		name,
+		mem,
 	)
 
-	ch <- prometheus.MustNewConstMetric(memoryUsageBytes,
+	// containerd does not report the limit of containers without one.
+	if memLimit == 0 {
+		return
+	}
+
+	memRatio := mem / memLimit
+
+	ch <- id.metric(memoryLimitBytes,
 		prometheus.GaugeValue,
-		mem,
-		name,
+		memLimit,
 	)
 
-	ch <- prometheus.MustNewConstMetric(memoryUsageRatio,
+	ch <- id.metric(memoryUsageRatio,
 		prometheus.GaugeValue,
 		memRatio,
-		name,
 	)
 
-	// Deprecated.
-	ch <- prometheus.MustNewConstMetric(memoryTotalBytes,
+	if !c.deprecatedMetrics {
+		return
+	}
+
+	ch <- id.metric(memoryTotalBytes,
 		prometheus.GaugeValue,
 		memLimit,
-		name,
 	)
 
-	ch <- prometheus.MustNewConstMetric(memoryUsagePercentage,
+	ch <- id.metric(memoryUsagePercentage,
 		prometheus.GaugeValue,
 		memRatio*100.0,
-		name,
 	)
 }
 
-func (c *DockerCollector) networkMetrics(ch chan<- prometheus.Metric, name string, stats *container.StatsResponse) {
+func (c *DockerCollector) networkMetrics(ch chan<- prometheus.Metric, id containerIdentity, stats *container.StatsResponse) {
 	for networkName, network := range stats.Networks {
-		ch <- prometheus.MustNewConstMetric(networkReceiveBytesTotal,
-			prometheus.CounterValue, float64(network.RxBytes), name, networkName)
-		ch <- prometheus.MustNewConstMetric(networkReceivePacketsTotal,
-			prometheus.CounterValue, float64(network.RxPackets), name, networkName)
-		ch <- prometheus.MustNewConstMetric(networkReceivePacketsDroppedTotal,
-			prometheus.CounterValue, float64(network.RxDropped), name, networkName)
-		ch <- prometheus.MustNewConstMetric(networkReceiveErrorsTotal,
-			prometheus.CounterValue, float64(network.RxErrors), name, networkName)
-		ch <- prometheus.MustNewConstMetric(networkTransmitBytesTotal,
-			prometheus.CounterValue, float64(network.TxBytes), name, networkName)
-		ch <- prometheus.MustNewConstMetric(networkTransmitPacketsTotal,
-			prometheus.CounterValue, float64(network.TxPackets), name, networkName)
-		ch <- prometheus.MustNewConstMetric(networkTransmitPacketsDroppedTotal,
-			prometheus.CounterValue, float64(network.TxDropped), name, networkName)
-		ch <- prometheus.MustNewConstMetric(networkTransmitErrorsTotal,
-			prometheus.CounterValue, float64(network.TxErrors), name, networkName)
-
-		// Deprecated.
-		ch <- prometheus.MustNewConstMetric(networkRxBytes,
-			prometheus.GaugeValue, float64(network.RxBytes), name, networkName)
-		ch <- prometheus.MustNewConstMetric(networkRxPackets,
-			prometheus.GaugeValue, float64(network.RxPackets), name, networkName)
-		ch <- prometheus.MustNewConstMetric(networkRxDroppedPackets,
-			prometheus.GaugeValue, float64(network.RxDropped), name, networkName)
-		ch <- prometheus.MustNewConstMetric(networkRxErrors,
-			prometheus.GaugeValue, float64(network.RxErrors), name, networkName)
-		ch <- prometheus.MustNewConstMetric(networkTxBytes,
-			prometheus.GaugeValue, float64(network.TxBytes), name, networkName)
-		ch <- prometheus.MustNewConstMetric(networkTxPackets,
-			prometheus.GaugeValue, float64(network.TxPackets), name, networkName)
-		ch <- prometheus.MustNewConstMetric(networkTxDroppedPackets,
-			prometheus.GaugeValue, float64(network.TxDropped), name, networkName)
-		ch <- prometheus.MustNewConstMetric(networkTxErrors,
-			prometheus.GaugeValue, float64(network.TxErrors), name, networkName)
+		ch <- id.metric(networkReceiveBytesTotal,
+			prometheus.CounterValue, float64(network.RxBytes), networkName)
+		ch <- id.metric(networkReceivePacketsTotal,
+			prometheus.CounterValue, float64(network.RxPackets), networkName)
+		ch <- id.metric(networkReceivePacketsDroppedTotal,
+			prometheus.CounterValue, float64(network.RxDropped), networkName)
+		ch <- id.metric(networkReceiveErrorsTotal,
+			prometheus.CounterValue, float64(network.RxErrors), networkName)
+		ch <- id.metric(networkTransmitBytesTotal,
+			prometheus.CounterValue, float64(network.TxBytes), networkName)
+		ch <- id.metric(networkTransmitPacketsTotal,
+			prometheus.CounterValue, float64(network.TxPackets), networkName)
+		ch <- id.metric(networkTransmitPacketsDroppedTotal,
+			prometheus.CounterValue, float64(network.TxDropped), networkName)
+		ch <- id.metric(networkTransmitErrorsTotal,
+			prometheus.CounterValue, float64(network.TxErrors), networkName)
+
+		if !c.deprecatedMetrics {
+			continue
+		}
+
+		ch <- id.metric(networkRxBytes,
+			prometheus.GaugeValue, float64(network.RxBytes), networkName)
+		ch <- id.metric(networkRxPackets,
+			prometheus.GaugeValue, float64(network.RxPackets), networkName)
+		ch <- id.metric(networkRxDroppedPackets,
+			prometheus.GaugeValue, float64(network.RxDropped), networkName)
+		ch <- id.metric(networkRxErrors,
+			prometheus.GaugeValue, float64(network.RxErrors), networkName)
+		ch <- id.metric(networkTxBytes,
+			prometheus.GaugeValue, float64(network.TxBytes), networkName)
+		ch <- id.metric(networkTxPackets,
+			prometheus.GaugeValue, float64(network.TxPackets), networkName)
+		ch <- id.metric(networkTxDroppedPackets,
+			prometheus.GaugeValue, float64(network.TxDropped), networkName)
+		ch <- id.metric(networkTxErrors,
+			prometheus.GaugeValue, float64(network.TxErrors), networkName)
 	}
 }
 
-func (c *DockerCollector) blockIOMetrics(ch chan<- prometheus.Metric, name string, stats *container.StatsResponse) {
+func (c *DockerCollector) blockIOMetrics(ch chan<- prometheus.Metric, id containerIdentity, stats *container.StatsResponse) {
 	var blkRead, blkWrite uint64
 	for _, bioEntry := range stats.BlkioStats.IoServiceBytesRecursive {
 		if len(bioEntry.Op) == 0 {
@@ -295,45 +487,28 @@ func (c *DockerCollector) blockIOMetrics(ch chan<- prometheus.Metric, name strin
 		}
 	}
 
-	ch <- prometheus.MustNewConstMetric(fsReadsBytesTotal,
-		prometheus.CounterValue, float64(blkRead), name)
-	ch <- prometheus.MustNewConstMetric(fsWritesBytesTotal,
-		prometheus.CounterValue, float64(blkWrite), name)
+	ch <- id.metric(fsReadsBytesTotal,
+		prometheus.CounterValue, float64(blkRead))
+	ch <- id.metric(fsWritesBytesTotal,
+		prometheus.CounterValue, float64(blkWrite))
+
+	if !c.deprecatedMetrics {
+		return
+	}
 
-	// Deprecated.
-	ch <- prometheus.MustNewConstMetric(blockIOReadBytes,
-		prometheus.GaugeValue, float64(blkRead), name)
-	ch <- prometheus.MustNewConstMetric(blockIOWriteBytes,
-		prometheus.GaugeValue, float64(blkWrite), name)
+	ch <- id.metric(blockIOReadBytes,
+		prometheus.GaugeValue, float64(blkRead))
+	ch <- id.metric(blockIOWriteBytes,
+		prometheus.GaugeValue, float64(blkWrite))
 }
 
-func (c *DockerCollector) pidsMetrics(ch chan<- prometheus.Metric, name string, stats *container.StatsResponse) {
-	ch <- prometheus.MustNewConstMetric(pidsCurrent,
+func (c *DockerCollector) pidsMetrics(ch chan<- prometheus.Metric, id containerIdentity, stats *container.StatsResponse) {
+	ch <- id.metric(pidsCurrent,
 		prometheus.GaugeValue,
 		float64(stats.PidsStats.Current),
-		name,
 	)
 }
 
-// containerStats gets the stats of a single containers.
-func (c *DockerCollector) containerStats(ctx context.Context, containerID string) (*container.StatsResponse, error) {
-	r, err := c.client.ContainerStats(ctx, containerID, false)
-	if err != nil {
-		return nil, err
-	}
-
-	defer func() { _ = r.Body.Close() }()
-
-	var stats container.StatsResponse
-	decoder := json.NewDecoder(r.Body)
-
-	if err := decoder.Decode(&stats); err != nil {
-		return nil, err
-	}
-
-	return &stats, err
-}
-
 func (c *DockerCollector) calculateUptime(container types.ContainerJSON) float64 {
 	startTime, err := c.clock.Parse(time.RFC3339Nano, container.State.StartedAt)
 	if err != nil {
@@ -359,8 +534,21 @@ func (c *DockerCollector) isContainerIgnored(container types.Container) bool {
 
 func (c *DockerCollector) collectScrapeError(ch chan<- prometheus.Metric) {
 	ch <- prometheus.MustNewConstMetric(scrapeErrorsTotal, prometheus.CounterValue, 1)
-	// Deprecated.
-	ch <- prometheus.MustNewConstMetric(scrapeErrors, prometheus.CounterValue, 1)
+	if c.deprecatedMetrics {
+		ch <- prometheus.MustNewConstMetric(scrapeErrors, prometheus.CounterValue, 1)
+	}
+}
+
+// statsEnabled reports whether any family derived from the container stats
+// is collected, so the stats request can be skipped otherwise.
+func (c *DockerCollector) statsEnabled() bool {
+	for _, name := range CollectorNames {
+		if c.collectors.Enabled(name) {
+			return true
+		}
+	}
+
+	return false
 }
 
 func calculateMemUsageUnixNoCache(mem container.MemoryStats) float64 {
@@ -1,9 +1,11 @@
 package collector_test
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
-	"net/http"
-	"net/http/httptest"
+	"errors"
+	"io"
 	"strings"
 	"testing"
 	"time"
@@ -12,7 +14,6 @@ import (
 	"github.com/davidborzek/docker-exporter/internal/mock"
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
-	"github.com/docker/docker/client"
 	"github.com/prometheus/client_golang/prometheus/testutil"
 	"go.uber.org/mock/gomock"
 )
@@ -23,23 +24,14 @@ func TestCollectMetrics(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
-	srv := httptest.NewServer(http.HandlerFunc(mockDockerApi))
-	defer srv.Close()
-
-	cli, err := client.NewClientWithOpts(
-		client.WithHost(srv.URL),
-		client.WithHTTPClient(&http.Client{}),
-	)
-
-	if err != nil {
-		panic(err)
-	}
+	api := newMockAPI(ctrl)
+	expectFixtures(api)
 
 	mockClock := mock.NewMockClock(ctrl)
 	mockClock.EXPECT().
 		Now().
 		Return(time.Now()).
-		Times(1)
+		AnyTimes()
 
 	mockClock.EXPECT().
 		Parse(gomock.Any(), gomock.Any()).
@@ -60,7 +52,7 @@ func TestCollectMetrics(t *testing.T) {
 		Return(2 * time.Second).
 		Times(1)
 
-	dc := collector.NewWithClient(cli, mockClock, ignoreLabel, nil)
+	dc := collector.NewWithClient(api, mockClock, collector.Options{IgnoreLabel: ignoreLabel})
 
 	const expected = `
 	# HELP docker_container_cpu_online_cpus Number of online CPUs
@@ -77,7 +69,7 @@ func TestCollectMetrics(t *testing.T) {
 	docker_container_fs_writes_bytes_total{name="testName"} 7777
 	# HELP docker_container_info Infos about the container
 	# TYPE docker_container_info gauge
-	docker_container_info{image="sha256:d3751d33f9cd5049c4af2b462735457e4d3baf130bcbb87f389e349fbaeb20b9",image_name="myImage",name="testName"} 1
+	docker_container_info{image="sha256:d3751d33f9cd5049c4af2b462735457e4d3baf130bcbb87f389e349fbaeb20b9",image_name="myImage",name="testName",runtime="docker"} 1
 	# HELP docker_container_memory_limit_bytes Memory limit in bytes
 	# TYPE docker_container_memory_limit_bytes gauge
 	docker_container_memory_limit_bytes{name="testName"} 8e+09
@@ -167,20 +159,11 @@ func TestCollectDeprecatedMetrics(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
-	srv := httptest.NewServer(http.HandlerFunc(mockDockerApi))
-	defer srv.Close()
-
-	cli, err := client.NewClientWithOpts(
-		client.WithHost(srv.URL),
-		client.WithHTTPClient(&http.Client{}),
-	)
-
-	if err != nil {
-		panic(err)
-	}
+	api := newMockAPI(ctrl)
+	expectFixtures(api)
 
 	mockClock := mock.NewMockClock(ctrl)
-	mockClock.EXPECT().Now().Return(time.Now()).Times(1)
+	mockClock.EXPECT().Now().Return(time.Now()).AnyTimes()
 	mockClock.EXPECT().
 		Parse(gomock.Any(), gomock.Any()).
 		DoAndReturn(func(s1, s2 string) (time.Time, error) {
@@ -190,7 +173,7 @@ func TestCollectDeprecatedMetrics(t *testing.T) {
 	mockClock.EXPECT().Since(gomock.Any()).Return(1 * time.Second).Times(1)
 	mockClock.EXPECT().Since(gomock.Any()).Return(2 * time.Second).Times(1)
 
-	dc := collector.NewWithClient(cli, mockClock, ignoreLabel, nil)
+	dc := collector.NewWithClient(api, mockClock, collector.Options{IgnoreLabel: ignoreLabel})
 
 	// The deprecated metrics are still emitted for backward compatibility.
 	const expected = `
@@ -226,34 +209,118 @@ func TestCollectDeprecatedMetrics(t *testing.T) {
 	}
 }
 
-func TestCollectMetricsShouldCollectErrorWhenContainerListFails(t *testing.T) {
+func TestCollectMetricsWithoutDeprecatedMetrics(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
-	srv := httptest.NewServer(http.HandlerFunc(mockErrorDockerApi))
-	defer srv.Close()
+	api := newMockAPI(ctrl)
+	expectFixtures(api)
 
-	cli, err := client.NewClientWithOpts(
-		client.WithHost(srv.URL),
-		client.WithHTTPClient(&http.Client{}),
+	mockClock := mock.NewMockClock(ctrl)
+	mockClock.EXPECT().Now().Return(time.Now()).AnyTimes()
+	mockClock.EXPECT().
+		Parse(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(s1, s2 string) (time.Time, error) {
+			return time.Parse(s1, s2)
+		}).
+		Times(1)
+	mockClock.EXPECT().Since(gomock.Any()).Return(1 * time.Second).Times(2)
+
+	dc := collector.NewWithClient(api, mockClock, collector.Options{
+		IgnoreLabel:         ignoreLabel,
+		NoDeprecatedMetrics: true,
+	})
+
+	count := testutil.CollectAndCount(dc,
+		"docker_container_block_io_read_bytes",
+		"docker_container_block_io_write_bytes",
+		"docker_container_cpu_usage_percentage",
+		"docker_container_memory_total_bytes",
+		"docker_container_memory_usage_percentage",
+		"docker_container_network_rx_bytes",
+		"docker_container_network_tx_bytes",
+		"docker_container_uptime",
+		"docker_exporter_scrape_duration",
+		"docker_container_uptime_seconds",
 	)
 
+	// Only the current uptime metric remains.
+	if count != 1 {
+		t.Errorf("expected only docker_container_uptime_seconds, got %d metrics", count)
+	}
+}
+
+func TestCollectMetricsShouldSkipStatsWhenStatsCollectorsAreDisabled(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// No ContainerStats call is expected.
+	api := newMockAPI(ctrl)
+	api.EXPECT().
+		ContainerList(gomock.Any(), container.ListOptions{All: true}).
+		Return(buildContainerListResponse(), nil)
+	api.EXPECT().
+		ContainerInspect(gomock.Any(), "testID").
+		Return(buildInspectResponse(), nil)
+
+	mockClock := mock.NewMockClock(ctrl)
+	mockClock.EXPECT().Now().Return(time.Now()).AnyTimes()
+	mockClock.EXPECT().
+		Parse(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(s1, s2 string) (time.Time, error) {
+			return time.Parse(s1, s2)
+		}).
+		Times(1)
+	mockClock.EXPECT().Since(gomock.Any()).Return(1 * time.Second).Times(2)
+
+	collectors := collector.Collectors{}
+	for _, name := range collector.CollectorNames {
+		collectors[name] = false
+	}
+
+	dc := collector.NewWithClient(api, mockClock, collector.Options{
+		IgnoreLabel: ignoreLabel,
+		Collectors:  collectors,
+	})
+
+	const expected = `
+	# HELP docker_container_uptime_seconds Uptime of the container in seconds
+	# TYPE docker_container_uptime_seconds gauge
+	docker_container_uptime_seconds{name="testName"} 1
+	`
+
+	err := testutil.CollectAndCompare(dc, strings.NewReader(expected),
+		"docker_container_uptime_seconds",
+		"docker_container_cpu_usage_seconds_total",
+		"docker_container_memory_usage_bytes",
+		"docker_exporter_scrape_errors_total",
+	)
 	if err != nil {
-		panic(err)
+		t.Errorf("unexpected collecting result:\n%s", err)
 	}
+}
+
+func TestCollectMetricsShouldCollectErrorWhenContainerListFails(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	api := newMockAPI(ctrl)
+	api.EXPECT().
+		ContainerList(gomock.Any(), container.ListOptions{All: true}).
+		Return(nil, errors.New("connection refused"))
 
 	mockClock := mock.NewMockClock(ctrl)
 	mockClock.EXPECT().
 		Now().
 		Return(time.Now()).
-		Times(1)
+		AnyTimes()
 
 	mockClock.EXPECT().
 		Since(gomock.Any()).
 		Return(2 * time.Second).
 		Times(1)
 
-	dc := collector.NewWithClient(cli, mockClock, ignoreLabel, nil)
+	dc := collector.NewWithClient(api, mockClock, collector.Options{IgnoreLabel: ignoreLabel})
 
 	const expected = `
 	# HELP docker_exporter_scrape_errors_total Total number of scrape errors
@@ -276,30 +343,26 @@ func TestCollectMetricsShouldCollectErrorWhenContainerInspectFails(t *testing.T)
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
-	srv := httptest.NewServer(http.HandlerFunc(mockContainerInspectErrorDockerApi))
-	defer srv.Close()
-
-	cli, err := client.NewClientWithOpts(
-		client.WithHost(srv.URL),
-		client.WithHTTPClient(&http.Client{}),
-	)
-
-	if err != nil {
-		panic(err)
-	}
+	api := newMockAPI(ctrl)
+	api.EXPECT().
+		ContainerList(gomock.Any(), container.ListOptions{All: true}).
+		Return(buildContainerListResponse(), nil)
+	api.EXPECT().
+		ContainerInspect(gomock.Any(), "testID").
+		Return(types.ContainerJSON{}, errors.New("no such container"))
 
 	mockClock := mock.NewMockClock(ctrl)
 	mockClock.EXPECT().
 		Now().
 		Return(time.Now()).
-		Times(1)
+		AnyTimes()
 
 	mockClock.EXPECT().
 		Since(gomock.Any()).
 		Return(2 * time.Second).
 		Times(1)
 
-	dc := collector.NewWithClient(cli, mockClock, ignoreLabel, nil)
+	dc := collector.NewWithClient(api, mockClock, collector.Options{IgnoreLabel: ignoreLabel})
 
 	const expected = `
 	# HELP docker_exporter_scrape_errors_total Total number of scrape errors
@@ -322,23 +385,22 @@ func TestCollectMetricsShouldCollectErrorWhenContainerStatsFails(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
-	srv := httptest.NewServer(http.HandlerFunc(mockContainerStatsErrorDockerApi))
-	defer srv.Close()
-
-	cli, err := client.NewClientWithOpts(
-		client.WithHost(srv.URL),
-		client.WithHTTPClient(&http.Client{}),
-	)
-
-	if err != nil {
-		panic(err)
-	}
+	api := newMockAPI(ctrl)
+	api.EXPECT().
+		ContainerList(gomock.Any(), container.ListOptions{All: true}).
+		Return(buildContainerListResponse(), nil)
+	api.EXPECT().
+		ContainerInspect(gomock.Any(), "testID").
+		Return(buildInspectResponse(), nil)
+	api.EXPECT().
+		ContainerStats(gomock.Any(), "testID", false).
+		Return(container.StatsResponseReader{}, errors.New("stats unavailable"))
 
 	mockClock := mock.NewMockClock(ctrl)
 	mockClock.EXPECT().
 		Now().
 		Return(time.Now()).
-		Times(1)
+		AnyTimes()
 
 	mockClock.EXPECT().
 		Parse(gomock.Any(), gomock.Any()).
@@ -359,12 +421,12 @@ func TestCollectMetricsShouldCollectErrorWhenContainerStatsFails(t *testing.T) {
 		Return(2 * time.Second).
 		Times(1)
 
-	dc := collector.NewWithClient(cli, mockClock, ignoreLabel, nil)
+	dc := collector.NewWithClient(api, mockClock, collector.Options{IgnoreLabel: ignoreLabel})
 
 	const expected = `
 	# HELP docker_container_info Infos about the container
 	# TYPE docker_container_info gauge
-	docker_container_info{image="sha256:d3751d33f9cd5049c4af2b462735457e4d3baf130bcbb87f389e349fbaeb20b9",image_name="myImage",name="testName"} 1
+	docker_container_info{image="sha256:d3751d33f9cd5049c4af2b462735457e4d3baf130bcbb87f389e349fbaeb20b9",image_name="myImage",name="testName",runtime="docker"} 1
 	# HELP docker_container_state State of the container
 	# TYPE docker_container_state gauge
 	docker_container_state{name="testName",state="running"} 1
@@ -390,6 +452,168 @@ func TestCollectMetricsShouldCollectErrorWhenContainerStatsFails(t *testing.T) {
 	}
 }
 
+func TestCollectMetricsShouldCollectErrorWhenContainerStatsCannotBeDecoded(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	api := newMockAPI(ctrl)
+	api.EXPECT().
+		ContainerList(gomock.Any(), container.ListOptions{All: true}).
+		Return(buildContainerListResponse(), nil)
+	api.EXPECT().
+		ContainerInspect(gomock.Any(), "testID").
+		Return(buildInspectResponse(), nil)
+	api.EXPECT().
+		ContainerStats(gomock.Any(), "testID", false).
+		Return(container.StatsResponseReader{Body: io.NopCloser(strings.NewReader(`{"cpu_stats":`))}, nil)
+
+	mockClock := mock.NewMockClock(ctrl)
+	mockClock.EXPECT().Now().Return(time.Now()).AnyTimes()
+	mockClock.EXPECT().
+		Parse(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(s1, s2 string) (time.Time, error) {
+			return time.Parse(s1, s2)
+		}).
+		Times(1)
+	mockClock.EXPECT().Since(gomock.Any()).Return(1 * time.Second).Times(1)
+	mockClock.EXPECT().Since(gomock.Any()).Return(2 * time.Second).Times(1)
+
+	dc := collector.NewWithClient(api, mockClock, collector.Options{IgnoreLabel: ignoreLabel})
+
+	const expected = `
+	# HELP docker_exporter_scrape_errors_total Total number of scrape errors
+	# TYPE docker_exporter_scrape_errors_total counter
+	docker_exporter_scrape_errors_total 1
+	`
+
+	if err := testutil.CollectAndCompare(dc, strings.NewReader(expected),
+		"docker_container_cpu_usage_seconds_total",
+		"docker_exporter_scrape_errors_total",
+	); err != nil {
+		t.Errorf("unexpected collecting result:\n%s", err)
+	}
+}
+
+func TestCollectMetricsShouldCollectOtherContainersWhenOneInspectFails(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	list := append(buildContainerListResponse(), types.Container{
+		ID:    "brokenID",
+		Names: []string{"/broken"},
+		State: "running",
+	})
+
+	api := newMockAPI(ctrl)
+	api.EXPECT().
+		ContainerList(gomock.Any(), container.ListOptions{All: true}).
+		Return(list, nil)
+	api.EXPECT().
+		ContainerInspect(gomock.Any(), "testID").
+		Return(buildInspectResponse(), nil)
+	api.EXPECT().
+		ContainerInspect(gomock.Any(), "brokenID").
+		Return(types.ContainerJSON{}, errors.New("no such container"))
+	api.EXPECT().
+		ContainerStats(gomock.Any(), "testID", false).
+		Return(statsReader(buildStatsResponse()), nil)
+
+	mockClock := mock.NewMockClock(ctrl)
+	mockClock.EXPECT().Now().Return(time.Now()).AnyTimes()
+	mockClock.EXPECT().
+		Parse(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(s1, s2 string) (time.Time, error) {
+			return time.Parse(s1, s2)
+		}).
+		Times(1)
+	mockClock.EXPECT().Since(gomock.Any()).Return(1 * time.Second).Times(1)
+	mockClock.EXPECT().Since(gomock.Any()).Return(2 * time.Second).Times(1)
+
+	dc := collector.NewWithClient(api, mockClock, collector.Options{IgnoreLabel: ignoreLabel})
+
+	const expected = `
+	# HELP docker_container_state State of the container
+	# TYPE docker_container_state gauge
+	docker_container_state{name="testName",state="running"} 1
+	# HELP docker_exporter_scrape_errors_total Total number of scrape errors
+	# TYPE docker_exporter_scrape_errors_total counter
+	docker_exporter_scrape_errors_total 1
+	`
+
+	if err := testutil.CollectAndCompare(dc, strings.NewReader(expected),
+		"docker_container_state",
+		"docker_exporter_scrape_errors_total",
+	); err != nil {
+		t.Errorf("unexpected collecting result:\n%s", err)
+	}
+}
+
+func TestCollectMetricsShouldQueryStatsConcurrently(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	list := []types.Container{
+		{ID: "slowID", Names: []string{"/slow"}, State: "running"},
+		{ID: "fastID", Names: []string{"/fast"}, State: "running"},
+	}
+
+	// The stats of the slow container only return once the fast container's
+	// stats were requested, which deadlocks if containers are collected one
+	// after another.
+	fastRequested := make(chan struct{})
+
+	api := newMockAPI(ctrl)
+	api.EXPECT().
+		ContainerList(gomock.Any(), container.ListOptions{All: true}).
+		Return(list, nil)
+	api.EXPECT().
+		ContainerInspect(gomock.Any(), gomock.Any()).
+		Return(buildInspectResponse(), nil).
+		Times(2)
+	api.EXPECT().
+		ContainerStats(gomock.Any(), "slowID", false).
+		DoAndReturn(func(context.Context, string, bool) (container.StatsResponseReader, error) {
+			select {
+			case <-fastRequested:
+				return statsReader(buildStatsResponse()), nil
+			case <-time.After(5 * time.Second):
+				return container.StatsResponseReader{}, errors.New("stats were not queried concurrently")
+			}
+		})
+	api.EXPECT().
+		ContainerStats(gomock.Any(), "fastID", false).
+		DoAndReturn(func(context.Context, string, bool) (container.StatsResponseReader, error) {
+			close(fastRequested)
+			return statsReader(buildStatsResponse()), nil
+		})
+
+	mockClock := mock.NewMockClock(ctrl)
+	mockClock.EXPECT().Now().Return(time.Now()).AnyTimes()
+	mockClock.EXPECT().
+		Parse(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(s1, s2 string) (time.Time, error) {
+			return time.Parse(s1, s2)
+		}).
+		Times(2)
+	mockClock.EXPECT().Since(gomock.Any()).Return(1 * time.Second).Times(3)
+
+	dc := collector.NewWithClient(api, mockClock, collector.Options{IgnoreLabel: ignoreLabel})
+
+	const expected = `
+	# HELP docker_container_pids_current Current number of pids
+	# TYPE docker_container_pids_current gauge
+	docker_container_pids_current{name="fast"} 12
+	docker_container_pids_current{name="slow"} 12
+	`
+
+	if err := testutil.CollectAndCompare(dc, strings.NewReader(expected),
+		"docker_container_pids_current",
+		"docker_exporter_scrape_errors_total",
+	); err != nil {
+		t.Errorf("unexpected collecting result:\n%s", err)
+	}
+}
+
 func buildInspectResponse() types.ContainerJSON {
 	return types.ContainerJSON{
 		ContainerJSONBase: &types.ContainerJSONBase{
@@ -486,79 +710,50 @@ func buildStatsResponse() container.StatsResponse {
 	}
 }
 
-func mockJsonResponse(w http.ResponseWriter, r *http.Request, body any) {
-	raw, err := json.Marshal(body)
-	if err != nil {
-		panic(err)
-	}
+// newMockAPI returns a Docker API mock for a Docker engine.
+func newMockAPI(ctrl *gomock.Controller) *mock.MockAPI {
+	api := mock.NewMockAPI(ctrl)
+	api.EXPECT().
+		ServerVersion(gomock.Any()).
+		Return(types.Version{Components: []types.ComponentVersion{{Name: "Engine"}}}, nil).
+		AnyTimes()
 
-	_, _ = w.Write(raw)
+	return api
 }
 
-func mockDockerApi(w http.ResponseWriter, r *http.Request) {
-	if strings.Contains(r.URL.Path, "stats") {
-		mockJsonResponse(w, r, buildStatsResponse())
-		return
-	}
-
-	if strings.Contains(r.URL.Path, "testID") {
-		mockJsonResponse(w, r, buildInspectResponse())
-		return
-	}
-
-	mockJsonResponse(w, r, buildContainerListResponse())
-}
-
-func mockErrorDockerApi(w http.ResponseWriter, r *http.Request) {
-	w.WriteHeader(http.StatusInternalServerError)
+// expectFixtures expects a scrape of the fixture containers. The ignored
+// container must be neither inspected nor queried for stats.
+func expectFixtures(api *mock.MockAPI) {
+	api.EXPECT().
+		ContainerList(gomock.Any(), container.ListOptions{All: true}).
+		Return(buildContainerListResponse(), nil)
+	api.EXPECT().
+		ContainerInspect(gomock.Any(), "testID").
+		Return(buildInspectResponse(), nil)
+	api.EXPECT().
+		ContainerStats(gomock.Any(), "testID", false).
+		Return(statsReader(buildStatsResponse()), nil)
 }
 
-func mockContainerInspectErrorDockerApi(w http.ResponseWriter, r *http.Request) {
-	if strings.Contains(r.URL.Path, "stats") {
-		mockJsonResponse(w, r, buildStatsResponse())
-		return
-	}
-
-	if strings.Contains(r.URL.Path, "testID") {
-		w.WriteHeader(http.StatusInternalServerError)
-		return
-	}
-
-	mockJsonResponse(w, r, buildContainerListResponse())
-}
-
-func mockContainerStatsErrorDockerApi(w http.ResponseWriter, r *http.Request) {
-	if strings.Contains(r.URL.Path, "stats") {
-		w.WriteHeader(http.StatusInternalServerError)
-		return
-	}
-
-	if strings.Contains(r.URL.Path, "testID") {
-		mockJsonResponse(w, r, buildInspectResponse())
-		return
+// statsReader encodes stats the way the daemon streams them.
+func statsReader(stats any) container.StatsResponseReader {
+	raw, err := json.Marshal(stats)
+	if err != nil {
+		panic(err)
 	}
 
-	mockJsonResponse(w, r, buildContainerListResponse())
+	return container.StatsResponseReader{Body: io.NopCloser(bytes.NewReader(raw))}
 }
 
 func TestCollectContainerLabels(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
-	srv := httptest.NewServer(http.HandlerFunc(mockDockerApi))
-	defer srv.Close()
-
-	cli, err := client.NewClientWithOpts(
-		client.WithHost(srv.URL),
-		client.WithHTTPClient(&http.Client{}),
-	)
-
-	if err != nil {
-		panic(err)
-	}
+	api := newMockAPI(ctrl)
+	expectFixtures(api)
 
 	mockClock := mock.NewMockClock(ctrl)
-	mockClock.EXPECT().Now().Return(time.Now()).Times(1)
+	mockClock.EXPECT().Now().Return(time.Now()).AnyTimes()
 	mockClock.EXPECT().
 		Parse(gomock.Any(), gomock.Any()).
 		DoAndReturn(func(s1, s2 string) (time.Time, error) {
@@ -568,9 +763,12 @@ func TestCollectContainerLabels(t *testing.T) {
 	mockClock.EXPECT().Since(gomock.Any()).Return(1 * time.Second).Times(1)
 	mockClock.EXPECT().Since(gomock.Any()).Return(2 * time.Second).Times(1)
 
-	dc := collector.NewWithClient(cli, mockClock, ignoreLabel, []string{
-		"com.docker.compose.project",
-		"unset",
+	dc := collector.NewWithClient(api, mockClock, collector.Options{
+		IgnoreLabel: ignoreLabel,
+		ContainerLabels: []string{
+			"com.docker.compose.project",
+			"unset",
+		},
 	})
 	// "unset" is not present on the container, so (kube_pod_labels style) it is
 	// simply omitted rather than exposed with an empty value. "maintainer" is
@@ -605,29 +803,19 @@ func TestCollectContainerLabelsFromContainerLabel(t *testing.T) {
 		},
 	}
 
-	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		switch {
-		case strings.Contains(r.URL.Path, "stats"):
-			mockJsonResponse(w, r, buildStatsResponse())
-		case strings.Contains(r.URL.Path, "testID"):
-			mockJsonResponse(w, r, buildInspectResponse())
-		default:
-			mockJsonResponse(w, r, list)
-		}
-	}))
-	defer srv.Close()
-
-	cli, err := client.NewClientWithOpts(
-		client.WithHost(srv.URL),
-		client.WithHTTPClient(&http.Client{}),
-	)
-
-	if err != nil {
-		panic(err)
-	}
+	api := newMockAPI(ctrl)
+	api.EXPECT().
+		ContainerList(gomock.Any(), container.ListOptions{All: true}).
+		Return(list, nil)
+	api.EXPECT().
+		ContainerInspect(gomock.Any(), "testID").
+		Return(buildInspectResponse(), nil)
+	api.EXPECT().
+		ContainerStats(gomock.Any(), "testID", false).
+		Return(statsReader(buildStatsResponse()), nil)
 
 	mockClock := mock.NewMockClock(ctrl)
-	mockClock.EXPECT().Now().Return(time.Now()).Times(1)
+	mockClock.EXPECT().Now().Return(time.Now()).AnyTimes()
 	mockClock.EXPECT().
 		Parse(gomock.Any(), gomock.Any()).
 		DoAndReturn(func(s1, s2 string) (time.Time, error) {
@@ -637,7 +825,7 @@ func TestCollectContainerLabelsFromContainerLabel(t *testing.T) {
 	mockClock.EXPECT().Since(gomock.Any()).Return(1 * time.Second).Times(1)
 	mockClock.EXPECT().Since(gomock.Any()).Return(2 * time.Second).Times(1)
 
-	dc := collector.NewWithClient(cli, mockClock, ignoreLabel, nil)
+	dc := collector.NewWithClient(api, mockClock, collector.Options{IgnoreLabel: ignoreLabel})
 
 	// Only "maintainer" was opted in; "com.docker.compose.project" is present
 	// but not selected.
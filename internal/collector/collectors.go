@@ -0,0 +1,48 @@
+package collector
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Names of the per-container metric families that can be toggled.
+const (
+	CollectorCPU     = "cpu"
+	CollectorMemory  = "memory"
+	CollectorNetwork = "network"
+	CollectorBlockIO = "blkio"
+	CollectorPIDs    = "pids"
+)
+
+// CollectorNames lists every metric family that can be toggled, in the order
+// they are emitted.
+var CollectorNames = []string{
+	CollectorCPU,
+	CollectorMemory,
+	CollectorNetwork,
+	CollectorBlockIO,
+	CollectorPIDs,
+}
+
+// Collectors enables or disables metric families by name. Families missing
+// from the map are enabled, so the zero value collects everything.
+type Collectors map[string]bool
+
+// Enabled reports whether the named family is collected.
+func (c Collectors) Enabled(name string) bool {
+	enabled, ok := c[name]
+	return !ok || enabled
+}
+
+// Validate returns an error if the map references an unknown family.
+func (c Collectors) Validate() error {
+	for name := range c {
+		if !slices.Contains(CollectorNames, name) {
+			return fmt.Errorf("unknown collector %q (valid: %s)",
+				name, strings.Join(CollectorNames, ", "))
+		}
+	}
+
+	return nil
+}
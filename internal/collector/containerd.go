@@ -0,0 +1,395 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	cgroup1 "github.com/containerd/cgroups/v3/cgroup1/stats"
+	cgroup2 "github.com/containerd/cgroups/v3/cgroup2/stats"
+	containerd "github.com/containerd/containerd/v2/client"
+	"github.com/containerd/containerd/v2/pkg/namespaces"
+	"github.com/containerd/errdefs"
+	"github.com/containerd/typeurl/v2"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultContainerdAddress is the socket of a containerd installed by a
+// distribution package or nerdctl.
+const DefaultContainerdAddress = "/run/containerd/containerd.sock"
+
+// nerdctlNameLabel holds the name nerdctl gives a container.
+const nerdctlNameLabel = "nerdctl/name"
+
+// containerdRuntime reads containers from containerd. Containers are listed
+// across the configured namespaces, or all namespaces when none are set, and
+// addressed as "<namespace>/<id>".
+type containerdRuntime struct {
+	client     *containerd.Client
+	namespaces []string
+}
+
+// NewContainerdRuntime connects to the containerd socket at address. The
+// connection is established lazily on the first request.
+func NewContainerdRuntime(address string, namespaces []string) (Runtime, error) {
+	if address == "" {
+		address = DefaultContainerdAddress
+	}
+
+	client, err := containerd.New(address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create containerd client: %w", err)
+	}
+
+	return &containerdRuntime{
+		client:     client,
+		namespaces: namespaces,
+	}, nil
+}
+
+func (r *containerdRuntime) Name() string {
+	return RuntimeContainerd
+}
+
+// ContainerList ignores the Docker list filters; the collector filters the
+// containers itself.
+func (r *containerdRuntime) ContainerList(ctx context.Context, _ filters.Args) ([]types.Container, error) {
+	nsList := r.namespaces
+	if len(nsList) == 0 {
+		var err error
+		if nsList, err = r.client.NamespaceService().List(ctx); err != nil {
+			return nil, fmt.Errorf("failed to list containerd namespaces: %w", err)
+		}
+	}
+
+	var list []types.Container
+	for _, ns := range nsList {
+		nsCtx := namespaces.WithNamespace(ctx, ns)
+
+		containers, err := r.client.Containers(nsCtx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list containers of namespace %q: %w", ns, err)
+		}
+
+		list = append(list, containerdContainers(nsCtx, ns, containers)...)
+	}
+
+	// Every container is skipped once the scrape timed out, so the list
+	// fails instead of being empty.
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return list, nil
+}
+
+// containerdContainers converts the containers of namespace ns. Containers
+// failing to load, e.g. because they were deleted while being listed, are
+// logged and skipped, so they do not fail the whole list.
+func containerdContainers(ctx context.Context, ns string, containers []containerd.Container) []types.Container {
+	list := make([]types.Container, 0, len(containers))
+	for _, c := range containers {
+		container, err := containerdContainer(ctx, ns, c)
+		if err != nil {
+			log.WithError(err).WithField("id", ns+"/"+c.ID()).
+				Warn("skipping containerd container")
+			continue
+		}
+
+		list = append(list, container)
+	}
+
+	return list
+}
+
+func containerdContainer(ctx context.Context, ns string, c containerd.Container) (types.Container, error) {
+	info, err := c.Info(ctx, containerd.WithoutRefreshedMetadata)
+	if err != nil {
+		return types.Container{}, err
+	}
+
+	status, err := taskStatus(ctx, c)
+	if err != nil {
+		return types.Container{}, err
+	}
+
+	return types.Container{
+		ID:      ns + "/" + info.ID,
+		Names:   []string{"/" + containerdName(info.ID, info.Labels)},
+		Image:   info.Image,
+		Created: info.CreatedAt.Unix(),
+		Labels:  info.Labels,
+		State:   containerState(status.Status),
+	}, nil
+}
+
+func (r *containerdRuntime) ContainerInspect(ctx context.Context, id string) (types.ContainerJSON, error) {
+	ctx, c, err := r.load(ctx, id)
+	if err != nil {
+		return types.ContainerJSON{}, err
+	}
+
+	info, err := c.Info(ctx, containerd.WithoutRefreshedMetadata)
+	if err != nil {
+		return types.ContainerJSON{}, err
+	}
+
+	status, err := taskStatus(ctx, c)
+	if err != nil {
+		return types.ContainerJSON{}, err
+	}
+
+	var imageID string
+	if img, err := r.client.ImageService().Get(ctx, info.Image); err == nil {
+		imageID = img.Target.Digest.String()
+	}
+
+	// containerd does not record when a task was started, so StartedAt is
+	// left empty.
+	state := &types.ContainerState{
+		Status:   containerState(status.Status),
+		Running:  status.Status == containerd.Running,
+		Paused:   status.Status == containerd.Paused,
+		ExitCode: int(status.ExitStatus),
+	}
+	if !status.ExitTime.IsZero() {
+		state.FinishedAt = status.ExitTime.Format(time.RFC3339Nano)
+	}
+
+	return types.ContainerJSON{
+		ContainerJSONBase: &types.ContainerJSONBase{
+			ID:      info.ID,
+			Created: info.CreatedAt.Format(time.RFC3339Nano),
+			Image:   imageID,
+			Name:    "/" + containerdName(info.ID, info.Labels),
+			State:   state,
+		},
+		Config: &container.Config{
+			Image:  info.Image,
+			Labels: info.Labels,
+		},
+	}, nil
+}
+
+func (r *containerdRuntime) ContainerStats(ctx context.Context, id string) (*container.StatsResponse, error) {
+	ctx, c, err := r.load(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	task, err := c.Task(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	metric, err := task.Metrics(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := typeurl.UnmarshalAny(metric.Data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode containerd metrics: %w", err)
+	}
+
+	switch m := data.(type) {
+	case *cgroup2.Metrics:
+		return cgroup2Stats(m), nil
+	case *cgroup1.Metrics:
+		return cgroup1Stats(m), nil
+	}
+
+	return nil, fmt.Errorf("unsupported containerd metrics type %T", data)
+}
+
+func (r *containerdRuntime) Ping(ctx context.Context) error {
+	serving, err := r.client.IsServing(ctx)
+	if err != nil {
+		return err
+	}
+	if !serving {
+		return errors.New("containerd is not serving")
+	}
+
+	return nil
+}
+
+func (r *containerdRuntime) Close() error {
+	return r.client.Close()
+}
+
+// load splits a "<namespace>/<id>" container ID and loads the container.
+func (r *containerdRuntime) load(ctx context.Context, id string) (context.Context, containerd.Container, error) {
+	ns, containerID, ok := strings.Cut(id, "/")
+	if !ok {
+		return nil, nil, fmt.Errorf("invalid containerd container id %q", id)
+	}
+
+	ctx = namespaces.WithNamespace(ctx, ns)
+
+	c, err := r.client.LoadContainer(ctx, containerID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return ctx, c, nil
+}
+
+// taskStatus returns the status of the container's task. Containers without
+// a task were created but never started, or their task was deleted.
+func taskStatus(ctx context.Context, c containerd.Container) (containerd.Status, error) {
+	task, err := c.Task(ctx, nil)
+	if errors.Is(err, errdefs.ErrNotFound) {
+		return containerd.Status{Status: containerd.Created}, nil
+	}
+	if err != nil {
+		return containerd.Status{}, err
+	}
+
+	status, err := task.Status(ctx)
+	// The task exited and was deleted since it was loaded.
+	if errors.Is(err, errdefs.ErrNotFound) {
+		return containerd.Status{Status: containerd.Stopped}, nil
+	}
+	if err != nil {
+		return containerd.Status{}, err
+	}
+
+	return status, nil
+}
+
+// containerdName returns the nerdctl name of a container, falling back to its
+// ID for containers created by other clients.
+func containerdName(id string, labels map[string]string) string {
+	if name := labels[nerdctlNameLabel]; name != "" {
+		return name
+	}
+
+	return id
+}
+
+// containerState maps a containerd task status to the Docker container state
+// of the docker_container_state metric.
+func containerState(status containerd.ProcessStatus) string {
+	switch status {
+	case containerd.Running:
+		return "running"
+	case containerd.Paused, containerd.Pausing:
+		return "paused"
+	case containerd.Stopped:
+		return "exited"
+	case containerd.Created:
+		return "created"
+	}
+
+	return "dead"
+}
+
+// containerdMemoryLimit returns the memory limit of cgroup metrics, or zero
+// for containers without one. cgroups report those with the largest possible
+// limit, and containerd does not know the host's memory to report instead.
+func containerdMemoryLimit(limit uint64) uint64 {
+	if limit >= unlimitedMemory {
+		return 0
+	}
+
+	return limit
+}
+
+// cgroup2Stats converts cgroup v2 task metrics. They have no per-CPU
+// breakdown, so the online CPUs are left unknown.
+func cgroup2Stats(m *cgroup2.Metrics) *container.StatsResponse {
+	stats := &container.StatsResponse{
+		Networks: networkStats(m.GetNetwork()),
+	}
+
+	if cpu := m.GetCPU(); cpu != nil {
+		stats.CPUStats.CPUUsage.TotalUsage = cpu.UsageUsec * 1000
+		stats.CPUStats.CPUUsage.UsageInKernelmode = cpu.SystemUsec * 1000
+		stats.CPUStats.CPUUsage.UsageInUsermode = cpu.UserUsec * 1000
+	}
+
+	if mem := m.GetMemory(); mem != nil {
+		stats.MemoryStats = container.MemoryStats{
+			Usage: mem.Usage,
+			Limit: containerdMemoryLimit(mem.UsageLimit),
+			Stats: map[string]uint64{"inactive_file": mem.InactiveFile},
+		}
+	}
+
+	if pids := m.GetPids(); pids != nil {
+		stats.PidsStats = container.PidsStats{Current: pids.Current, Limit: pids.Limit}
+	}
+
+	if io := m.GetIo(); io != nil {
+		for _, e := range io.Usage {
+			stats.BlkioStats.IoServiceBytesRecursive = append(stats.BlkioStats.IoServiceBytesRecursive,
+				container.BlkioStatEntry{Major: e.Major, Minor: e.Minor, Op: "read", Value: e.Rbytes},
+				container.BlkioStatEntry{Major: e.Major, Minor: e.Minor, Op: "write", Value: e.Wbytes},
+			)
+		}
+	}
+
+	return stats
+}
+
+// cgroup1Stats converts cgroup v1 task metrics.
+func cgroup1Stats(m *cgroup1.Metrics) *container.StatsResponse {
+	stats := &container.StatsResponse{
+		Networks: make(map[string]container.NetworkStats),
+	}
+
+	for _, n := range m.GetNetwork() {
+		stats.Networks[n.Name] = container.NetworkStats{
+			RxBytes: n.RxBytes, RxPackets: n.RxPackets, RxErrors: n.RxErrors, RxDropped: n.RxDropped,
+			TxBytes: n.TxBytes, TxPackets: n.TxPackets, TxErrors: n.TxErrors, TxDropped: n.TxDropped,
+		}
+	}
+
+	if usage := m.GetCPU().GetUsage(); usage != nil {
+		stats.CPUStats.CPUUsage = container.CPUUsage{
+			TotalUsage:        usage.Total,
+			PercpuUsage:       usage.PerCPU,
+			UsageInKernelmode: usage.Kernel,
+			UsageInUsermode:   usage.User,
+		}
+	}
+
+	if mem := m.GetMemory(); mem != nil {
+		stats.MemoryStats = container.MemoryStats{
+			Usage: mem.GetUsage().GetUsage(),
+			Limit: containerdMemoryLimit(mem.GetUsage().GetLimit()),
+			Stats: map[string]uint64{"total_inactive_file": mem.TotalInactiveFile},
+		}
+	}
+
+	if pids := m.GetPids(); pids != nil {
+		stats.PidsStats = container.PidsStats{Current: pids.Current, Limit: pids.Limit}
+	}
+
+	for _, e := range m.GetBlkio().GetIoServiceBytesRecursive() {
+		stats.BlkioStats.IoServiceBytesRecursive = append(stats.BlkioStats.IoServiceBytesRecursive,
+			container.BlkioStatEntry{Major: e.Major, Minor: e.Minor, Op: e.Op, Value: e.Value})
+	}
+
+	return stats
+}
+
+// networkStats converts the per-interface network stats of cgroup v2 metrics.
+func networkStats(networks []*cgroup2.NetworkStat) map[string]container.NetworkStats {
+	stats := make(map[string]container.NetworkStats, len(networks))
+
+	for _, n := range networks {
+		stats[n.Name] = container.NetworkStats{
+			RxBytes: n.RxBytes, RxPackets: n.RxPackets, RxErrors: n.RxErrors, RxDropped: n.RxDropped,
+			TxBytes: n.TxBytes, TxPackets: n.TxPackets, TxErrors: n.TxErrors, TxDropped: n.TxDropped,
+		}
+	}
+
+	return stats
+}
@@ -0,0 +1,97 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+
+	cgroup2 "github.com/containerd/cgroups/v3/cgroup2/stats"
+	containerd "github.com/containerd/containerd/v2/client"
+	"github.com/containerd/containerd/v2/core/containers"
+	"github.com/containerd/containerd/v2/pkg/cio"
+	"github.com/containerd/errdefs"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeContainerdContainer is a containerd container with a task in status,
+// failing with the given errors.
+type fakeContainerdContainer struct {
+	containerd.Container
+	id        string
+	infoErr   error
+	taskErr   error
+	status    containerd.ProcessStatus
+	statusErr error
+}
+
+func (c *fakeContainerdContainer) ID() string {
+	return c.id
+}
+
+func (c *fakeContainerdContainer) Info(context.Context, ...containerd.InfoOpts) (containers.Container, error) {
+	return containers.Container{ID: c.id, Image: "docker.io/library/nginx:1.25"}, c.infoErr
+}
+
+func (c *fakeContainerdContainer) Task(context.Context, cio.Attach) (containerd.Task, error) {
+	if c.taskErr != nil {
+		return nil, c.taskErr
+	}
+
+	return &fakeContainerdTask{status: c.status, err: c.statusErr}, nil
+}
+
+type fakeContainerdTask struct {
+	containerd.Task
+	status containerd.ProcessStatus
+	err    error
+}
+
+func (t *fakeContainerdTask) Status(context.Context) (containerd.Status, error) {
+	return containerd.Status{Status: t.status}, t.err
+}
+
+func TestContainerdContainersSkipsFailingContainers(t *testing.T) {
+	list := containerdContainers(context.Background(), "default", []containerd.Container{
+		&fakeContainerdContainer{id: "running", status: containerd.Running},
+		&fakeContainerdContainer{id: "deleted", infoErr: errdefs.ErrNotFound},
+		&fakeContainerdContainer{id: "created", taskErr: errdefs.ErrNotFound},
+		&fakeContainerdContainer{id: "exited", statusErr: errdefs.ErrNotFound},
+		&fakeContainerdContainer{id: "broken", statusErr: errors.New("shim disconnected")},
+	})
+
+	states := make(map[string]string)
+	for _, c := range list {
+		states[c.ID] = c.State
+	}
+
+	assert.Equal(t, map[string]string{
+		"default/running": "running",
+		"default/created": "created",
+		"default/exited":  "exited",
+	}, states)
+}
+
+func TestCgroup2Stats(t *testing.T) {
+	stats := cgroup2Stats(&cgroup2.Metrics{
+		CPU:    &cgroup2.CPUStat{UsageUsec: 1500000, UserUsec: 1000000, SystemUsec: 500000},
+		Memory: &cgroup2.MemoryStat{Usage: 4194304, UsageLimit: 8388608, InactiveFile: 1024},
+	})
+
+	assert.Equal(t, uint64(1500000000), stats.CPUStats.CPUUsage.TotalUsage)
+	assert.Equal(t, uint64(4194304), stats.MemoryStats.Usage)
+	assert.Equal(t, uint64(8388608), stats.MemoryStats.Limit)
+	// cgroup v2 has no per-CPU breakdown, and the host of the exporter may
+	// not be the one of the containers.
+	assert.Zero(t, stats.CPUStats.OnlineCPUs)
+}
+
+func TestCgroup2StatsWithoutMemoryLimit(t *testing.T) {
+	// cgroup v2 reports "max" as the largest possible limit.
+	stats := cgroup2Stats(&cgroup2.Metrics{
+		Memory: &cgroup2.MemoryStat{Usage: 4194304, UsageLimit: math.MaxUint64},
+	})
+
+	assert.Equal(t, uint64(4194304), stats.MemoryStats.Usage)
+	assert.Zero(t, stats.MemoryStats.Limit)
+}
@@ -0,0 +1,74 @@
+package collector
+
+import (
+	"context"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// collectorFunc is an unchecked prometheus.Collector collecting with f.
+type collectorFunc func(ch chan<- prometheus.Metric)
+
+func (f collectorFunc) Describe(_ chan<- *prometheus.Desc) {}
+
+func (f collectorFunc) Collect(ch chan<- prometheus.Metric) {
+	f(ch)
+}
+
+// exporterMetrics holds the exporter metrics of the last scrape when they are
+// served apart from the container metrics.
+type exporterMetrics struct {
+	mu      sync.Mutex
+	metrics []prometheus.Metric
+}
+
+// ContainerMetrics returns a collector of the container metrics alone. The
+// exporter metrics describing its scrapes are kept for ExporterMetrics, so
+// both can be scraped at different intervals. The requests to the container
+// runtime are canceled with ctx.
+func (c *DockerCollector) ContainerMetrics(ctx context.Context) prometheus.Collector {
+	return collectorFunc(func(ch chan<- prometheus.Metric) {
+		c.collectContainers(ctx, ch)
+	})
+}
+
+// ExporterMetrics returns a collector of the exporter metrics: those of the
+// last scrape of ContainerMetrics and the Docker API metrics. It does not
+// scrape the containers itself.
+func (c *DockerCollector) ExporterMetrics() prometheus.Collector {
+	return collectorFunc(c.collectExporter)
+}
+
+func (c *DockerCollector) collectContainers(ctx context.Context, ch chan<- prometheus.Metric) {
+	self := make(chan prometheus.Metric)
+	done := make(chan []prometheus.Metric)
+
+	go func() {
+		var metrics []prometheus.Metric
+		for m := range self {
+			metrics = append(metrics, m)
+		}
+		done <- metrics
+	}()
+
+	c.scrape(ctx, ch, self, nil)
+	close(self)
+	metrics := <-done
+
+	c.exporter.mu.Lock()
+	c.exporter.metrics = metrics
+	c.exporter.mu.Unlock()
+}
+
+func (c *DockerCollector) collectExporter(ch chan<- prometheus.Metric) {
+	c.exporter.mu.Lock()
+	metrics := c.exporter.metrics
+	c.exporter.mu.Unlock()
+
+	for _, m := range metrics {
+		ch <- m
+	}
+
+	c.api.collect(ch)
+}
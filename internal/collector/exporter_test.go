@@ -0,0 +1,65 @@
+package collector_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidborzek/docker-exporter/internal/collector"
+	"github.com/davidborzek/docker-exporter/internal/mock"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+)
+
+func TestCollectContainerAndExporterMetricsSeparately(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	api := newMockAPI(ctrl)
+	expectFixtures(api)
+
+	mockClock := mock.NewMockClock(ctrl)
+	mockClock.EXPECT().Now().Return(time.Now()).AnyTimes()
+	mockClock.EXPECT().
+		Parse(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(s1, s2 string) (time.Time, error) {
+			return time.Parse(s1, s2)
+		}).
+		Times(1)
+	mockClock.EXPECT().Since(gomock.Any()).Return(1 * time.Second).Times(1)
+	mockClock.EXPECT().Since(gomock.Any()).Return(2 * time.Second).Times(1)
+
+	dc := collector.NewWithClient(api, mockClock, collector.Options{
+		IgnoreLabel:         ignoreLabel,
+		NoDeprecatedMetrics: true,
+	})
+
+	// Nothing was scraped yet.
+	assert.Empty(t, gather(t, dc.ExporterMetrics()))
+
+	for name := range gather(t, dc.ContainerMetrics(context.Background())) {
+		assert.True(t, strings.HasPrefix(name, "docker_container_"), name)
+	}
+
+	// The exporter metrics describe the last scrape without scraping again.
+	families := gather(t, dc.ExporterMetrics())
+	for name := range families {
+		assert.True(t, strings.HasPrefix(name, "docker_exporter_"), name)
+	}
+	assert.Contains(t, families, "docker_exporter_container_collection_duration_seconds")
+	assert.Contains(t, families, "docker_exporter_docker_api_request_duration_seconds")
+
+	const expected = `
+	# HELP docker_exporter_scrape_duration_seconds Duration of the scrape in seconds
+	# TYPE docker_exporter_scrape_duration_seconds gauge
+	docker_exporter_scrape_duration_seconds 2
+	`
+
+	if err := testutil.CollectAndCompare(dc.ExporterMetrics(), strings.NewReader(expected),
+		"docker_exporter_scrape_duration_seconds",
+	); err != nil {
+		t.Errorf("unexpected collecting result:\n%s", err)
+	}
+}
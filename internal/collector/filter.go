@@ -0,0 +1,281 @@
+package collector
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+)
+
+// composeProjectLabel is the label Docker Compose sets to the project name.
+const composeProjectLabel = "com.docker.compose.project"
+
+// FilterConfig selects the containers a collector reports on. A container is
+// collected when it matches every configured include filter and none of the
+// exclude filters; empty filters match all containers.
+type FilterConfig struct {
+	// Names are regular expressions matched against the whole container
+	// name, without the leading slash.
+	Names        []string
+	ExcludeNames []string
+	// Images are glob patterns matched against the image reference the
+	// container was created from. "*" matches any sequence of characters,
+	// including "/", and "?" a single character.
+	Images        []string
+	ExcludeImages []string
+	// ComposeProjects match the com.docker.compose.project label.
+	ComposeProjects        []string
+	ExcludeComposeProjects []string
+	// LabelSelector is a comma-separated list of label requirements, all of
+	// which must hold: "key=value" (or "key==value"), "key!=value", "key"
+	// for an existing label and "!key" for a missing one.
+	LabelSelector string
+}
+
+// Filter is the compiled form of a FilterConfig. A nil *Filter matches every
+// container.
+type Filter struct {
+	names           []*regexp.Regexp
+	excludeNames    []*regexp.Regexp
+	images          []*regexp.Regexp
+	excludeImages   []*regexp.Regexp
+	projects        map[string]bool
+	excludeProjects map[string]bool
+	selector        []labelRequirement
+}
+
+// labelRequirement is a single term of a label selector.
+type labelRequirement struct {
+	key    string
+	value  string
+	negate bool
+	// exists requirements only check the presence of key.
+	exists bool
+}
+
+// NewFilter compiles cfg. It returns nil when cfg is empty.
+func NewFilter(cfg FilterConfig) (*Filter, error) {
+	f := &Filter{
+		projects:        toSet(cfg.ComposeProjects),
+		excludeProjects: toSet(cfg.ExcludeComposeProjects),
+	}
+
+	var err error
+	if f.names, err = compileNamePatterns(cfg.Names); err != nil {
+		return nil, err
+	}
+	if f.excludeNames, err = compileNamePatterns(cfg.ExcludeNames); err != nil {
+		return nil, err
+	}
+	if f.images, err = compileImagePatterns(cfg.Images); err != nil {
+		return nil, err
+	}
+	if f.excludeImages, err = compileImagePatterns(cfg.ExcludeImages); err != nil {
+		return nil, err
+	}
+	if f.selector, err = parseLabelSelector(cfg.LabelSelector); err != nil {
+		return nil, err
+	}
+
+	if len(f.names) == 0 && len(f.excludeNames) == 0 &&
+		len(f.images) == 0 && len(f.excludeImages) == 0 &&
+		len(f.projects) == 0 && len(f.excludeProjects) == 0 &&
+		len(f.selector) == 0 {
+		return nil, nil
+	}
+
+	return f, nil
+}
+
+// Match reports whether the container passes the filter.
+func (f *Filter) Match(container types.Container) bool {
+	if f == nil {
+		return true
+	}
+
+	name := containerName(container)
+	if len(f.names) > 0 && !matchAny(f.names, name) {
+		return false
+	}
+	if matchAny(f.excludeNames, name) {
+		return false
+	}
+
+	if len(f.images) > 0 && !matchAny(f.images, container.Image) {
+		return false
+	}
+	if matchAny(f.excludeImages, container.Image) {
+		return false
+	}
+
+	project, ok := container.Labels[composeProjectLabel]
+	if len(f.projects) > 0 && (!ok || !f.projects[project]) {
+		return false
+	}
+	if ok && f.excludeProjects[project] {
+		return false
+	}
+
+	for _, r := range f.selector {
+		if !r.matches(container.Labels) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// listFilters returns the part of the filter the Docker daemon can evaluate
+// itself, so fewer containers are transferred. Everything else is applied
+// by Match.
+func (f *Filter) listFilters() filters.Args {
+	var labels []string
+
+	if f != nil {
+		if len(f.projects) == 1 {
+			for project := range f.projects {
+				labels = append(labels, composeProjectLabel+"="+project)
+			}
+		}
+
+		for _, r := range f.selector {
+			switch {
+			case r.negate:
+				// The daemon has no negated label filter.
+			case r.exists:
+				labels = append(labels, r.key)
+			default:
+				labels = append(labels, r.key+"="+r.value)
+			}
+		}
+	}
+
+	if len(labels) == 0 {
+		return filters.Args{}
+	}
+
+	args := filters.NewArgs()
+	for _, l := range labels {
+		args.Add("label", l)
+	}
+
+	return args
+}
+
+func (r labelRequirement) matches(labels map[string]string) bool {
+	value, ok := labels[r.key]
+
+	if r.exists {
+		return ok != r.negate
+	}
+
+	if r.negate {
+		return !ok || value != r.value
+	}
+
+	return ok && value == r.value
+}
+
+// parseLabelSelector parses a selector like "env=prod,tier!=batch".
+func parseLabelSelector(selector string) ([]labelRequirement, error) {
+	var requirements []labelRequirement
+
+	for _, term := range strings.Split(selector, ",") {
+		term = strings.TrimSpace(term)
+		if term == "" {
+			continue
+		}
+
+		var r labelRequirement
+		switch {
+		case strings.Contains(term, "!="):
+			r.key, r.value, _ = strings.Cut(term, "!=")
+			r.negate = true
+		case strings.Contains(term, "=="):
+			r.key, r.value, _ = strings.Cut(term, "==")
+		case strings.Contains(term, "="):
+			r.key, r.value, _ = strings.Cut(term, "=")
+		case strings.HasPrefix(term, "!"):
+			r.key = strings.TrimPrefix(term, "!")
+			r.exists, r.negate = true, true
+		default:
+			r.key = term
+			r.exists = true
+		}
+
+		r.key = strings.TrimSpace(r.key)
+		r.value = strings.TrimSpace(r.value)
+		if r.key == "" || strings.ContainsAny(r.key, "!=") {
+			return nil, fmt.Errorf("invalid label selector %q: malformed term %q", selector, term)
+		}
+
+		requirements = append(requirements, r)
+	}
+
+	return requirements, nil
+}
+
+// compileNamePatterns compiles anchored regular expressions.
+func compileNamePatterns(patterns []string) ([]*regexp.Regexp, error) {
+	compiled := make([]*regexp.Regexp, 0, len(patterns))
+
+	for _, p := range patterns {
+		re, err := regexp.Compile("^(?:" + p + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("invalid container name pattern %q: %w", p, err)
+		}
+		compiled = append(compiled, re)
+	}
+
+	return compiled, nil
+}
+
+// compileImagePatterns turns image globs into anchored regular expressions.
+func compileImagePatterns(patterns []string) ([]*regexp.Regexp, error) {
+	compiled := make([]*regexp.Regexp, 0, len(patterns))
+
+	for _, p := range patterns {
+		if p == "" {
+			return nil, fmt.Errorf("invalid image pattern %q: must not be empty", p)
+		}
+
+		var b strings.Builder
+		b.WriteString("^")
+		for _, r := range p {
+			switch r {
+			case '*':
+				b.WriteString(".*")
+			case '?':
+				b.WriteString(".")
+			default:
+				b.WriteString(regexp.QuoteMeta(string(r)))
+			}
+		}
+		b.WriteString("$")
+
+		compiled = append(compiled, regexp.MustCompile(b.String()))
+	}
+
+	return compiled, nil
+}
+
+func matchAny(patterns []*regexp.Regexp, s string) bool {
+	for _, re := range patterns {
+		if re.MatchString(s) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func toSet(values []string) map[string]bool {
+	set := make(map[string]bool, len(values))
+	for _, v := range values {
+		set[v] = true
+	}
+
+	return set
+}
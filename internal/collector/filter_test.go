@@ -0,0 +1,327 @@
+package collector_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidborzek/docker-exporter/internal/collector"
+	"github.com/davidborzek/docker-exporter/internal/mock"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestFilterMatch(t *testing.T) {
+	web := types.Container{
+		Names: []string{"/shop-web-1"},
+		Image: "ghcr.io/acme/shop/web:1.4",
+		Labels: map[string]string{
+			"com.docker.compose.project": "shop",
+			"env":                        "prod",
+			"tier":                       "frontend",
+		},
+	}
+
+	tests := []struct {
+		name   string
+		config collector.FilterConfig
+		want   bool
+	}{
+		{
+			name: "empty filter",
+			want: true,
+		},
+		{
+			name:   "name regex",
+			config: collector.FilterConfig{Names: []string{"shop-.*"}},
+			want:   true,
+		},
+		{
+			name:   "name regex is anchored",
+			config: collector.FilterConfig{Names: []string{"web"}},
+			want:   false,
+		},
+		{
+			name:   "excluded name",
+			config: collector.FilterConfig{ExcludeNames: []string{".*-web-[0-9]+"}},
+			want:   false,
+		},
+		{
+			name:   "image glob crosses path segments",
+			config: collector.FilterConfig{Images: []string{"ghcr.io/acme/*"}},
+			want:   true,
+		},
+		{
+			name:   "image glob mismatch",
+			config: collector.FilterConfig{Images: []string{"docker.io/*", "nginx:?.??"}},
+			want:   false,
+		},
+		{
+			name:   "excluded image",
+			config: collector.FilterConfig{ExcludeImages: []string{"*:1.4"}},
+			want:   false,
+		},
+		{
+			name:   "compose project",
+			config: collector.FilterConfig{ComposeProjects: []string{"billing", "shop"}},
+			want:   true,
+		},
+		{
+			name:   "other compose project",
+			config: collector.FilterConfig{ComposeProjects: []string{"billing"}},
+			want:   false,
+		},
+		{
+			name:   "excluded compose project",
+			config: collector.FilterConfig{ExcludeComposeProjects: []string{"shop"}},
+			want:   false,
+		},
+		{
+			name:   "label selector",
+			config: collector.FilterConfig{LabelSelector: "env=prod, tier!=batch, com.docker.compose.project, !debug"},
+			want:   true,
+		},
+		{
+			name:   "label selector with double equals",
+			config: collector.FilterConfig{LabelSelector: "env==staging"},
+			want:   false,
+		},
+		{
+			name:   "label selector negation",
+			config: collector.FilterConfig{LabelSelector: "tier!=frontend"},
+			want:   false,
+		},
+		{
+			name:   "label selector missing label",
+			config: collector.FilterConfig{LabelSelector: "team"},
+			want:   false,
+		},
+		{
+			name:   "label selector excluded label",
+			config: collector.FilterConfig{LabelSelector: "!env"},
+			want:   false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := collector.NewFilter(tt.config)
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.want, f.Match(web))
+		})
+	}
+}
+
+func TestFilterMatchWithoutComposeProject(t *testing.T) {
+	f, err := collector.NewFilter(collector.FilterConfig{ExcludeComposeProjects: []string{"shop"}})
+	require.NoError(t, err)
+	assert.True(t, f.Match(types.Container{Names: []string{"/standalone"}}))
+
+	f, err = collector.NewFilter(collector.FilterConfig{ComposeProjects: []string{"shop"}})
+	require.NoError(t, err)
+	assert.False(t, f.Match(types.Container{Names: []string{"/standalone"}}))
+}
+
+func TestNewFilterErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  collector.FilterConfig
+		wantErr string
+	}{
+		{
+			name:    "invalid name regex",
+			config:  collector.FilterConfig{Names: []string{"web("}},
+			wantErr: `invalid container name pattern "web("`,
+		},
+		{
+			name:    "empty image pattern",
+			config:  collector.FilterConfig{ExcludeImages: []string{""}},
+			wantErr: `invalid image pattern "": must not be empty`,
+		},
+		{
+			name:    "malformed selector",
+			config:  collector.FilterConfig{LabelSelector: "env=prod,=batch"},
+			wantErr: `invalid label selector "env=prod,=batch": malformed term "=batch"`,
+		},
+		{
+			name:    "negated equality",
+			config:  collector.FilterConfig{LabelSelector: "!env=prod"},
+			wantErr: `malformed term "!env=prod"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := collector.NewFilter(tt.config)
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestNewFilterEmpty(t *testing.T) {
+	f, err := collector.NewFilter(collector.FilterConfig{LabelSelector: " , "})
+	require.NoError(t, err)
+	assert.Nil(t, f)
+}
+
+func TestCollectMetricsAppliesFilter(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	list := []types.Container{
+		{ID: "webID", Names: []string{"/web"}, State: "exited", Labels: map[string]string{
+			"com.docker.compose.project": "shop", "env": "prod",
+		}},
+		{ID: "batchID", Names: []string{"/batch"}, State: "exited", Labels: map[string]string{
+			"com.docker.compose.project": "shop", "env": "prod", "tier": "batch",
+		}},
+	}
+
+	// Equality and existence requirements are evaluated by the daemon, the
+	// negated one by the collector.
+	want := filters.NewArgs(
+		filters.Arg("label", "com.docker.compose.project=shop"),
+		filters.Arg("label", "env=prod"),
+	)
+
+	api := newMockAPI(ctrl)
+	api.EXPECT().
+		ContainerList(gomock.Any(), container.ListOptions{All: true, Filters: want}).
+		Return(list, nil)
+	api.EXPECT().
+		ContainerInspect(gomock.Any(), "webID").
+		Return(buildInspectResponse(), nil)
+
+	mockClock := mock.NewMockClock(ctrl)
+	mockClock.EXPECT().Now().Return(time.Now()).AnyTimes()
+	mockClock.EXPECT().Since(gomock.Any()).Return(1 * time.Second).Times(1)
+
+	f, err := collector.NewFilter(collector.FilterConfig{
+		ComposeProjects: []string{"shop"},
+		LabelSelector:   "env=prod,tier!=batch",
+	})
+	require.NoError(t, err)
+
+	dc := collector.NewWithClient(api, mockClock, collector.Options{
+		IgnoreLabel: ignoreLabel,
+		Filter:      f,
+	})
+
+	const expected = `
+	# HELP docker_container_state State of the container
+	# TYPE docker_container_state gauge
+	docker_container_state{name="web",state="exited"} 1
+	`
+
+	err = testutil.CollectAndCompare(dc, strings.NewReader(expected), "docker_container_state")
+	assert.NoError(t, err)
+}
+
+func TestCollectScopedMetrics(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	list := []types.Container{
+		{ID: "webID", Names: []string{"/web"}, State: "exited", Labels: map[string]string{
+			"com.docker.compose.project": "shop",
+		}},
+		{ID: "dbID", Names: []string{"/db"}, State: "exited", Labels: map[string]string{
+			"com.docker.compose.project": "billing",
+		}},
+	}
+
+	api := newMockAPI(ctrl)
+	api.EXPECT().
+		ContainerList(gomock.Any(), container.ListOptions{All: true}).
+		Return(list, nil).
+		Times(2)
+	api.EXPECT().
+		ContainerInspect(gomock.Any(), "webID").
+		Return(buildInspectResponse(), nil).
+		Times(2)
+	api.EXPECT().
+		ContainerInspect(gomock.Any(), "dbID").
+		Return(buildInspectResponse(), nil)
+
+	mockClock := mock.NewMockClock(ctrl)
+	mockClock.EXPECT().Now().Return(time.Now()).AnyTimes()
+	mockClock.EXPECT().Since(gomock.Any()).Return(1 * time.Second).Times(2)
+
+	dc := collector.NewWithClient(api, mockClock, collector.Options{
+		IgnoreLabel:         ignoreLabel,
+		NoDeprecatedMetrics: true,
+	})
+
+	scope, err := collector.NewFilter(collector.FilterConfig{ComposeProjects: []string{"shop"}})
+	require.NoError(t, err)
+
+	const scoped = `
+	# HELP docker_container_state State of the container
+	# TYPE docker_container_state gauge
+	docker_container_state{name="web",state="exited"} 1
+	`
+
+	err = testutil.CollectAndCompare(dc.Scoped(context.Background(), scope), strings.NewReader(scoped), "docker_container_state")
+	assert.NoError(t, err)
+
+	const all = `
+	# HELP docker_container_state State of the container
+	# TYPE docker_container_state gauge
+	docker_container_state{name="db",state="exited"} 1
+	docker_container_state{name="web",state="exited"} 1
+	`
+
+	err = testutil.CollectAndCompare(dc.Scoped(context.Background(), nil), strings.NewReader(all), "docker_container_state")
+	assert.NoError(t, err)
+}
+
+func TestScopedMetricsLeaveOutExporterMetrics(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	type key struct{}
+	ctx := context.WithValue(context.Background(), key{}, "request")
+
+	api := newMockAPI(ctrl)
+	api.EXPECT().
+		ContainerList(gomock.Any(), container.ListOptions{All: true}).
+		DoAndReturn(func(ctx context.Context, _ container.ListOptions) ([]types.Container, error) {
+			// The requests are made with the context of the scrape.
+			assert.Equal(t, "request", ctx.Value(key{}))
+			return buildContainerListResponse(), nil
+		})
+	api.EXPECT().
+		ContainerInspect(gomock.Any(), "testID").
+		Return(buildInspectResponse(), nil)
+	api.EXPECT().
+		ContainerStats(gomock.Any(), "testID", false).
+		Return(statsReader(buildStatsResponse()), nil)
+
+	mockClock := mock.NewMockClock(ctrl)
+	mockClock.EXPECT().Now().Return(time.Now()).AnyTimes()
+	mockClock.EXPECT().
+		Parse(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(s1, s2 string) (time.Time, error) {
+			return time.Parse(s1, s2)
+		}).
+		AnyTimes()
+	mockClock.EXPECT().Since(gomock.Any()).Return(1 * time.Second).AnyTimes()
+
+	dc := collector.NewWithClient(api, mockClock, collector.Options{
+		IgnoreLabel:         ignoreLabel,
+		NoDeprecatedMetrics: true,
+	})
+
+	families := gather(t, dc.Scoped(ctx, nil))
+	assert.Contains(t, families, "docker_container_state")
+	for name := range families {
+		assert.False(t, strings.HasPrefix(name, "docker_exporter_"), name)
+	}
+}
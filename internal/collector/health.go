@@ -0,0 +1,27 @@
+package collector
+
+import (
+	"context"
+	"errors"
+)
+
+// Ping checks that the container runtime is reachable.
+func (c *DockerCollector) Ping(ctx context.Context) error {
+	return c.runtime.Ping(ctx)
+}
+
+// Warm checks that the state the collector keeps between scrapes is known,
+// i.e. the container engine behind the Docker API was detected. It detects the
+// engine if that did not succeed yet.
+func (c *DockerCollector) Warm(ctx context.Context) error {
+	c.detectEngine(ctx)
+
+	c.engineMu.Lock()
+	defer c.engineMu.Unlock()
+
+	if c.detected == nil {
+		return errors.New("container engine not detected yet")
+	}
+
+	return nil
+}
@@ -0,0 +1,81 @@
+package collector_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/davidborzek/docker-exporter/internal/collector"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPingChecksTheRuntime(t *testing.T) {
+	rt := newFakeRuntime()
+	dc := newRuntimeCollector(t, rt)
+
+	assert.NoError(t, dc.Ping(context.Background()))
+
+	rt.pingErr = errors.New("connection refused")
+	assert.EqualError(t, dc.Ping(context.Background()), "connection refused")
+}
+
+func TestWarmDetectsTheEngine(t *testing.T) {
+	var reachable atomic.Bool
+	var versionRequests atomic.Int32
+	now := time.Now()
+	dc := newPodmanCollectorAt(t, func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/version") {
+			versionRequests.Add(1)
+			if !reachable.Load() {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+		}
+		mockPodmanApi(w, r)
+	}, collector.EngineAuto, func() time.Time { return now })
+
+	assert.EqualError(t, dc.Warm(context.Background()), "container engine not detected yet")
+
+	// A failed detection is not retried right away.
+	reachable.Store(true)
+	assert.EqualError(t, dc.Warm(context.Background()), "container engine not detected yet")
+	assert.Equal(t, int32(1), versionRequests.Load())
+
+	now = now.Add(10 * time.Second)
+	assert.NoError(t, dc.Warm(context.Background()))
+	assert.Equal(t, int32(2), versionRequests.Load())
+}
+
+func TestWarmDetectsTheEngineOnce(t *testing.T) {
+	release := make(chan struct{})
+	var versionRequests atomic.Int32
+	dc := newPodmanCollector(t, func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/version") {
+			versionRequests.Add(1)
+			<-release
+		}
+		mockPodmanApi(w, r)
+	}, collector.EngineAuto)
+
+	// Concurrent callers share a single detection.
+	var wg sync.WaitGroup
+	for range 3 {
+		wg.Go(func() {
+			assert.NoError(t, dc.Warm(context.Background()))
+		})
+	}
+
+	// Waiting callers give up with their context.
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.Error(t, dc.Warm(ctx))
+
+	close(release)
+	wg.Wait()
+	assert.Equal(t, int32(1), versionRequests.Load())
+}
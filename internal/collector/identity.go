@@ -0,0 +1,203 @@
+package collector
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/docker/docker/api/types"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+// Identity labels that can be attached to every per-container metric, next to
+// the container name.
+const (
+	IdentityContainerID            = "container_id"
+	IdentityContainerShortID       = "container_short_id"
+	IdentityComposeProject         = "compose_project"
+	IdentityComposeService         = "compose_service"
+	IdentityComposeContainerNumber = "compose_container_number"
+	IdentitySwarmService           = "swarm_service"
+	IdentitySwarmTask              = "swarm_task"
+)
+
+// IdentityLabelNames lists every identity label in the order they are
+// attached.
+var IdentityLabelNames = []string{
+	IdentityContainerID,
+	IdentityContainerShortID,
+	IdentityComposeProject,
+	IdentityComposeService,
+	IdentityComposeContainerNumber,
+	IdentitySwarmService,
+	IdentitySwarmTask,
+}
+
+// identityDockerLabels maps the identity labels read from a Docker label to
+// that label.
+var identityDockerLabels = map[string]string{
+	IdentityComposeProject:         composeProjectLabel,
+	IdentityComposeService:         "com.docker.compose.service",
+	IdentityComposeContainerNumber: "com.docker.compose.container-number",
+	IdentitySwarmService:           "com.docker.swarm.service.name",
+	IdentitySwarmTask:              "com.docker.swarm.task.name",
+}
+
+// shortIDLength is the length of the abbreviated container IDs the Docker CLI
+// prints.
+const shortIDLength = 12
+
+// ValidateIdentityLabels returns an error if names contains an unknown
+// identity label.
+func ValidateIdentityLabels(names []string) error {
+	for _, name := range names {
+		if !slices.Contains(IdentityLabelNames, name) {
+			return fmt.Errorf("unknown identity label %q (valid: %s)",
+				name, strings.Join(IdentityLabelNames, ", "))
+		}
+	}
+
+	return nil
+}
+
+// identityLabels returns the configured identity labels without duplicates,
+// in the order of IdentityLabelNames so every collector labels its series
+// the same way.
+func identityLabels(names []string) []string {
+	labels := make([]string, 0, len(names))
+	for _, name := range IdentityLabelNames {
+		if slices.Contains(names, name) {
+			labels = append(labels, name)
+		}
+	}
+
+	return labels
+}
+
+// containerDesc describes a per-container metric family. Its series are
+// labelled with the container name and the configured identity labels,
+// followed by the family's own labels.
+type containerDesc struct {
+	fqName string
+	help   string
+	labels []string
+
+	// descs caches the prometheus.Desc per set of identity labels.
+	descs sync.Map
+}
+
+func newContainerDesc(fqName, help string, labels ...string) *containerDesc {
+	return &containerDesc{fqName: fqName, help: help, labels: labels}
+}
+
+func (d *containerDesc) desc(identity []string) *prometheus.Desc {
+	key := strings.Join(identity, ",")
+	if desc, ok := d.descs.Load(key); ok {
+		return desc.(*prometheus.Desc)
+	}
+
+	desc := prometheus.NewDesc(d.fqName, d.help, slices.Concat(identity, d.labels), nil)
+	actual, _ := d.descs.LoadOrStore(key, desc)
+
+	return actual.(*prometheus.Desc)
+}
+
+// containerIdentity holds the labels identifying the series of a container:
+// its name and the configured identity labels, as changed by the relabeling
+// rules.
+type containerIdentity struct {
+	names  []string
+	values []string
+}
+
+// containerIdentity returns the identity of a container. It reports false
+// when the relabeling rules drop the container.
+func (c *DockerCollector) containerIdentity(container types.Container) (containerIdentity, bool) {
+	id := containerIdentity{
+		names:  make([]string, 0, len(c.identityLabels)+1),
+		values: make([]string, 0, len(c.identityLabels)+1),
+	}
+
+	if c.relabeler == nil {
+		id.add("name", containerName(container))
+		for _, name := range c.identityLabels {
+			id.add(name, identityValue(name, container))
+		}
+
+		return id, true
+	}
+
+	labels := metaLabels(container)
+	labels["name"] = containerName(container)
+	for _, name := range c.identityLabels {
+		labels[name] = identityValue(name, container)
+	}
+
+	if !c.relabeler.process(labels) {
+		return id, false
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(labels)) {
+		if strings.HasPrefix(name, reservedLabelPrefix) {
+			continue
+		}
+
+		// The label would clash with a label of a metric family.
+		if slices.Contains(containerFamilyLabels, name) {
+			log.WithField("label", name).
+				Debug("ignoring relabeled label reserved for a metric family")
+			continue
+		}
+
+		id.add(name, labels[name])
+	}
+
+	return id, true
+}
+
+func (id *containerIdentity) add(name, value string) {
+	id.names = append(id.names, name)
+	id.values = append(id.values, value)
+}
+
+// metric creates a metric of the container for the family d.
+func (id containerIdentity) metric(d *containerDesc, valueType prometheus.ValueType, value float64, labelValues ...string) prometheus.Metric {
+	return prometheus.MustNewConstMetric(d.desc(id.names), valueType, value,
+		slices.Concat(id.values, labelValues)...)
+}
+
+// identityValue returns the value of an identity label for a container. It
+// is empty when the container does not carry the information, e.g. the
+// compose labels of a container not created by Docker Compose.
+func identityValue(name string, container types.Container) string {
+	switch name {
+	case IdentityContainerID:
+		return container.ID
+	case IdentityContainerShortID:
+		return shortID(container.ID)
+	}
+
+	return container.Labels[identityDockerLabels[name]]
+}
+
+// shortID abbreviates a container ID like the Docker CLI. The namespace
+// prefix of containerd IDs is kept.
+func shortID(id string) string {
+	namespace, containerID, ok := strings.Cut(id, "/")
+	if !ok {
+		namespace, containerID = "", id
+	}
+
+	if len(containerID) > shortIDLength {
+		containerID = containerID[:shortIDLength]
+	}
+
+	if ok {
+		return namespace + "/" + containerID
+	}
+
+	return containerID
+}
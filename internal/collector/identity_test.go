@@ -0,0 +1,98 @@
+package collector_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidborzek/docker-exporter/internal/collector"
+	"github.com/davidborzek/docker-exporter/internal/mock"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+)
+
+func TestCollectMetricsWithIdentityLabels(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	const id = "4f66ad9a0b2e3b0cdc5a9b1e1f2b5d7c0e1a2b3c4d5e6f708192a3b4c5d6e7f8"
+
+	list := []types.Container{
+		{
+			ID:    id,
+			Names: []string{"/shop-web-2"},
+			State: "running",
+			Labels: map[string]string{
+				"com.docker.compose.project":          "shop",
+				"com.docker.compose.service":          "web",
+				"com.docker.compose.container-number": "2",
+			},
+		},
+	}
+
+	api := newMockAPI(ctrl)
+	api.EXPECT().
+		ContainerList(gomock.Any(), container.ListOptions{All: true}).
+		Return(list, nil)
+	api.EXPECT().
+		ContainerInspect(gomock.Any(), id).
+		Return(buildInspectResponse(), nil)
+	api.EXPECT().
+		ContainerStats(gomock.Any(), id, false).
+		Return(statsReader(buildStatsResponse()), nil)
+
+	mockClock := mock.NewMockClock(ctrl)
+	mockClock.EXPECT().Now().Return(time.Now()).AnyTimes()
+	mockClock.EXPECT().
+		Parse(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(s1, s2 string) (time.Time, error) {
+			return time.Parse(s1, s2)
+		}).
+		Times(1)
+	mockClock.EXPECT().Since(gomock.Any()).Return(1 * time.Second).Times(2)
+
+	// The order of the configured labels does not matter, duplicates are
+	// ignored and labels the container does not carry are left empty.
+	dc := collector.NewWithClient(api, mockClock, collector.Options{
+		IgnoreLabel:     ignoreLabel,
+		ContainerLabels: []string{"com.docker.compose.project"},
+		IdentityLabels: []string{
+			collector.IdentitySwarmService,
+			collector.IdentityComposeService,
+			collector.IdentityContainerShortID,
+			collector.IdentityComposeService,
+			collector.IdentityComposeContainerNumber,
+		},
+	})
+
+	const expected = `
+	# HELP docker_container_labels Container labels converted to Prometheus labels
+	# TYPE docker_container_labels gauge
+	docker_container_labels{compose_container_number="2",compose_service="web",container_label_com_docker_compose_project="shop",container_short_id="4f66ad9a0b2e",name="shop-web-2",swarm_service=""} 1
+	# HELP docker_container_network_receive_bytes_total Total network bytes received
+	# TYPE docker_container_network_receive_bytes_total counter
+	docker_container_network_receive_bytes_total{compose_container_number="2",compose_service="web",container_short_id="4f66ad9a0b2e",name="shop-web-2",network="eth0",swarm_service=""} 135
+	# HELP docker_container_state State of the container
+	# TYPE docker_container_state gauge
+	docker_container_state{compose_container_number="2",compose_service="web",container_short_id="4f66ad9a0b2e",name="shop-web-2",state="running",swarm_service=""} 1
+	`
+
+	err := testutil.CollectAndCompare(dc, strings.NewReader(expected),
+		"docker_container_labels",
+		"docker_container_network_receive_bytes_total",
+		"docker_container_state",
+	)
+	assert.NoError(t, err)
+}
+
+func TestValidateIdentityLabels(t *testing.T) {
+	assert.NoError(t, collector.ValidateIdentityLabels(collector.IdentityLabelNames))
+	assert.NoError(t, collector.ValidateIdentityLabels(nil))
+
+	err := collector.ValidateIdentityLabels([]string{collector.IdentityContainerID, "id"})
+	assert.EqualError(t, err, `unknown identity label "id" (valid: container_id, container_short_id, `+
+		`compose_project, compose_service, compose_container_number, swarm_service, swarm_task)`)
+}
@@ -0,0 +1,128 @@
+package collector
+
+import (
+	"io"
+	"time"
+
+	"github.com/davidborzek/docker-exporter/internal/clock"
+	"github.com/docker/docker/errdefs"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Docker API endpoints the exporter requests, as reported in the endpoint
+// label of the API metrics.
+const (
+	endpointList    = "list"
+	endpointInspect = "inspect"
+	endpointStats   = "stats"
+	endpointInfo    = "info"
+	endpointVersion = "version"
+	endpointPods    = "pods"
+	endpointPing    = "ping"
+)
+
+// Outcomes of a Docker API request, as reported in the status label: the
+// class of the HTTP status code, or "error" for requests without a response,
+// e.g. when the daemon is unreachable or the scrape timed out.
+const (
+	requestSuccess     = "2xx"
+	requestClientError = "4xx"
+	requestServerError = "5xx"
+	requestError       = "error"
+)
+
+// apiMetrics instruments the requests of a collector to the Docker API.
+// Unlike the container metrics they accumulate over the lifetime of the
+// collector. A nil *apiMetrics records nothing, e.g. for the containerd
+// runtime.
+type apiMetrics struct {
+	clock           clock.Clock
+	requestDuration *prometheus.HistogramVec
+	// statsBytes is a vector without labels, so like the histogram it is
+	// only reported once there was a request.
+	statsBytes *prometheus.CounterVec
+}
+
+func newAPIMetrics(clk clock.Clock) *apiMetrics {
+	return &apiMetrics{
+		clock: clk,
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "docker_exporter_docker_api_request_duration_seconds",
+			Help:    "Duration of the requests to the Docker API in seconds",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"endpoint", "status"}),
+		statsBytes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "docker_exporter_docker_api_stats_decoded_bytes_total",
+			Help: "Total bytes of container stats responses of the Docker API decoded by the exporter",
+		}, nil),
+	}
+}
+
+// now returns the start time of a request passed to observe.
+func (m *apiMetrics) now() time.Time {
+	if m == nil {
+		return time.Time{}
+	}
+
+	return m.clock.Now()
+}
+
+// observe records a request to endpoint which started at start and failed
+// with err, if any.
+func (m *apiMetrics) observe(endpoint string, start time.Time, err error) {
+	if m == nil {
+		return
+	}
+
+	m.requestDuration.WithLabelValues(endpoint, requestStatus(err)).
+		Observe(m.clock.Now().Sub(start).Seconds())
+}
+
+// requestStatus returns the status label of a request failing with err. The
+// Docker client reports the status code of a failed response by the type of
+// its error.
+func requestStatus(err error) string {
+	switch {
+	case err == nil:
+		return requestSuccess
+	case errdefs.IsInvalidParameter(err), errdefs.IsUnauthorized(err),
+		errdefs.IsForbidden(err), errdefs.IsNotFound(err), errdefs.IsConflict(err):
+		return requestClientError
+	case errdefs.IsSystem(err), errdefs.IsUnavailable(err), errdefs.IsNotImplemented(err):
+		return requestServerError
+	}
+
+	return requestError
+}
+
+// decodedStats records the bytes read from a stats response. The other
+// responses are decoded by the Docker client, which does not tell their size.
+func (m *apiMetrics) decodedStats(r *countingReader) {
+	if m == nil {
+		return
+	}
+
+	m.statsBytes.WithLabelValues().Add(float64(r.n))
+}
+
+func (m *apiMetrics) collect(ch chan<- prometheus.Metric) {
+	if m == nil {
+		return
+	}
+
+	m.requestDuration.Collect(ch)
+	m.statsBytes.Collect(ch)
+}
+
+// countingReader counts the bytes read from r.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+
+	return n, err
+}
@@ -0,0 +1,146 @@
+package collector_test
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/davidborzek/docker-exporter/internal/collector"
+	"github.com/davidborzek/docker-exporter/internal/mock"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/errdefs"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestCollectMetricsInstrumentsDockerAPI(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	api := newMockAPI(ctrl)
+	expectFixtures(api)
+
+	// Every reading of the clock advances it by a second, so every request
+	// takes a second.
+	now := time.Now()
+	mockClock := mock.NewMockClock(ctrl)
+	mockClock.EXPECT().Now().DoAndReturn(func() time.Time {
+		now = now.Add(time.Second)
+		return now
+	}).AnyTimes()
+	mockClock.EXPECT().
+		Parse(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(s1, s2 string) (time.Time, error) {
+			return time.Parse(s1, s2)
+		}).
+		Times(1)
+	mockClock.EXPECT().Since(gomock.Any()).Return(1 * time.Second).Times(2)
+
+	dc := collector.NewWithClient(api, mockClock, collector.Options{IgnoreLabel: ignoreLabel})
+	families := gather(t, dc)
+
+	requests := families["docker_exporter_docker_api_request_duration_seconds"]
+	require.NotNil(t, requests)
+	assert.Equal(t, map[string]uint64{
+		"version/2xx": 1,
+		"list/2xx":    1,
+		"inspect/2xx": 1,
+		"stats/2xx":   1,
+	}, requestCounts(requests))
+	for _, m := range requests.GetMetric() {
+		assert.Equal(t, 1.0, m.GetHistogram().GetSampleSum())
+	}
+
+	raw, err := json.Marshal(buildStatsResponse())
+	require.NoError(t, err)
+
+	decoded := families["docker_exporter_docker_api_stats_decoded_bytes_total"]
+	require.NotNil(t, decoded)
+	require.Len(t, decoded.GetMetric(), 1)
+	assert.Equal(t, float64(len(raw)), decoded.GetMetric()[0].GetCounter().GetValue())
+
+	// Only the container which is not ignored is collected.
+	durations := families["docker_exporter_container_collection_duration_seconds"]
+	require.NotNil(t, durations)
+	require.Len(t, durations.GetMetric(), 1)
+	assert.Equal(t, "testName", durations.GetMetric()[0].GetLabel()[0].GetValue())
+	// The inspect and the stats request, and the readings around them.
+	assert.Equal(t, 5.0, durations.GetMetric()[0].GetGauge().GetValue())
+}
+
+func TestCollectMetricsInstrumentsFailedDockerAPIRequests(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	api := newMockAPI(ctrl)
+	api.EXPECT().
+		ContainerList(gomock.Any(), container.ListOptions{All: true}).
+		Return(buildContainerListResponse(), nil).
+		Times(3)
+	api.EXPECT().
+		ContainerInspect(gomock.Any(), "testID").
+		Return(types.ContainerJSON{}, errdefs.NotFound(errors.New("no such container")))
+	api.EXPECT().
+		ContainerInspect(gomock.Any(), "testID").
+		Return(types.ContainerJSON{}, errdefs.System(errors.New("daemon error")))
+	api.EXPECT().
+		ContainerInspect(gomock.Any(), "testID").
+		Return(types.ContainerJSON{}, errors.New("connection refused"))
+
+	mockClock := mock.NewMockClock(ctrl)
+	mockClock.EXPECT().Now().Return(time.Now()).AnyTimes()
+	mockClock.EXPECT().Since(gomock.Any()).Return(1 * time.Second).Times(3)
+
+	dc := collector.NewWithClient(api, mockClock, collector.Options{IgnoreLabel: ignoreLabel})
+
+	// The requests of every scrape add up, the engine is only detected once.
+	// Failed requests are told apart by the class of their status code.
+	gather(t, dc)
+	gather(t, dc)
+	families := gather(t, dc)
+
+	assert.Equal(t, map[string]uint64{
+		"version/2xx":   1,
+		"list/2xx":      3,
+		"inspect/4xx":   1,
+		"inspect/5xx":   1,
+		"inspect/error": 1,
+	}, requestCounts(families["docker_exporter_docker_api_request_duration_seconds"]))
+}
+
+func gather(t *testing.T, c prometheus.Collector) map[string]*dto.MetricFamily {
+	t.Helper()
+
+	registry := prometheus.NewPedanticRegistry()
+	require.NoError(t, registry.Register(c))
+
+	mfs, err := registry.Gather()
+	require.NoError(t, err)
+
+	families := make(map[string]*dto.MetricFamily, len(mfs))
+	for _, mf := range mfs {
+		families[mf.GetName()] = mf
+	}
+
+	return families
+}
+
+// requestCounts returns the number of observed requests keyed by
+// "<endpoint>/<status>".
+func requestCounts(mf *dto.MetricFamily) map[string]uint64 {
+	counts := map[string]uint64{}
+	for _, m := range mf.GetMetric() {
+		labels := map[string]string{}
+		for _, l := range m.GetLabel() {
+			labels[l.GetName()] = l.GetValue()
+		}
+		counts[labels["endpoint"]+"/"+labels["status"]] = m.GetHistogram().GetSampleCount()
+	}
+
+	return counts
+}
@@ -2,7 +2,9 @@ package collector
 
 import (
 	"fmt"
+	"maps"
 	"regexp"
+	"slices"
 	"strings"
 
 	"github.com/docker/docker/api/types"
@@ -32,6 +34,24 @@ func sanitizeLabelName(key string) string {
 	return containerLabelPrefix + invalidLabelChar.ReplaceAllString(key, "_")
 }
 
+// ValidateConstLabels returns an error if a constant label added to every
+// metric has an invalid name or one the exporter's own metrics use.
+func ValidateConstLabels(labels map[string]string) error {
+	for _, name := range slices.Sorted(maps.Keys(labels)) {
+		switch {
+		case !validLabelName.MatchString(name) || strings.HasPrefix(name, reservedLabelPrefix):
+			return fmt.Errorf("invalid const label name %q", name)
+		case name == "name" ||
+			slices.Contains(containerFamilyLabels, name) ||
+			slices.Contains(IdentityLabelNames, name) ||
+			strings.HasPrefix(name, containerLabelPrefix):
+			return fmt.Errorf("const label %q clashes with a label of the exported metrics", name)
+		}
+	}
+
+	return nil
+}
+
 // buildContainerLabels resolves Docker label keys to unique Prometheus label
 // names, preserving order. Keys whose sanitized names collide get a
 // "_conflictN" suffix so the resulting names stay unique.
@@ -89,7 +109,7 @@ func (c *DockerCollector) selectedLabelKeys(container types.Container) []string
 // container, exposing only the selected labels the container actually sets
 // (following the kube_pod_labels convention). It is a no-op when nothing is
 // selected or present, so every series carries a consistent, meaningful set.
-func (c *DockerCollector) collectContainerLabels(ch chan<- prometheus.Metric, name string, container types.Container) {
+func (c *DockerCollector) collectContainerLabels(ch chan<- prometheus.Metric, id containerIdentity, container types.Container) {
 	present := make([]string, 0)
 	for _, key := range c.selectedLabelKeys(container) {
 		if _, ok := container.Labels[key]; ok {
@@ -103,11 +123,15 @@ func (c *DockerCollector) collectContainerLabels(ch chan<- prometheus.Metric, na
 
 	labels := buildContainerLabels(present)
 
-	names := make([]string, 0, len(labels)+1)
-	values := make([]string, 0, len(labels)+1)
-	names = append(names, "name")
-	values = append(values, name)
+	names := make([]string, 0, len(id.names)+len(labels))
+	values := make([]string, 0, len(id.values)+len(labels))
+	names = append(names, id.names...)
+	values = append(values, id.values...)
 	for _, l := range labels {
+		// Relabeling may already have attached the label to every metric.
+		if slices.Contains(id.names, l.promName) {
+			continue
+		}
 		names = append(names, l.promName)
 		values = append(values, container.Labels[l.dockerKey])
 	}
@@ -54,3 +54,25 @@ func TestSelectedLabelKeys(t *testing.T) {
 		}
 	}
 }
+
+func TestValidateConstLabels(t *testing.T) {
+	if err := ValidateConstLabels(map[string]string{"datacenter": "fra1", "env": "prod"}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	tests := map[string]string{
+		"data-center":         `invalid const label name "data-center"`,
+		"__address__":         `invalid const label name "__address__"`,
+		"name":                `const label "name" clashes with a label of the exported metrics`,
+		"network":             `const label "network" clashes with a label of the exported metrics`,
+		"compose_service":     `const label "compose_service" clashes with a label of the exported metrics`,
+		"container_label_env": `const label "container_label_env" clashes with a label of the exported metrics`,
+	}
+
+	for name, want := range tests {
+		err := ValidateConstLabels(map[string]string{name: "x"})
+		if err == nil || err.Error() != want {
+			t.Errorf("ValidateConstLabels(%q) = %v, want %q", name, err, want)
+		}
+	}
+}
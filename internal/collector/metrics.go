@@ -3,46 +3,50 @@ package collector
 import "github.com/prometheus/client_golang/prometheus"
 
 var (
-	containerStateMetric = prometheus.NewDesc(
+	containerStateMetric = newContainerDesc(
 		"docker_container_state",
 		"State of the container",
-		[]string{"name", "state"},
-		nil,
+		"state",
 	)
 
-	containerExitCode = prometheus.NewDesc(
+	containerExitCode = newContainerDesc(
 		"docker_container_exit_code",
 		"Exit code of the container's last run (meaningful when the container is not running)",
-		[]string{"name"},
-		nil,
 	)
 
-	containerRestartsTotal = prometheus.NewDesc(
+	containerRestartsTotal = newContainerDesc(
 		"docker_container_restarts_total",
 		"Total number of times the container has been restarted by its restart policy",
-		[]string{"name"},
-		nil,
 	)
 
-	containerHealth = prometheus.NewDesc(
+	containerHealth = newContainerDesc(
 		"docker_container_health",
 		"Container health-check status (value 1 for the current status; 'none' when no HEALTHCHECK is defined)",
-		[]string{"name", "status"},
-		nil,
+		"status",
 	)
 
-	containerInfo = prometheus.NewDesc(
+	containerInfo = newContainerDesc(
 		"docker_container_info",
 		"Infos about the container",
-		[]string{"name", "image_name", "image"},
-		nil,
+		"image_name",
+		"image",
+		"runtime",
 	)
 
-	containerUptimeSeconds = prometheus.NewDesc(
+	// containerInfoPodman replaces containerInfo in Podman compatibility
+	// mode, adding the pod a container belongs to.
+	containerInfoPodman = newContainerDesc(
+		"docker_container_info",
+		"Infos about the container",
+		"image_name",
+		"image",
+		"runtime",
+		"pod",
+	)
+
+	containerUptimeSeconds = newContainerDesc(
 		"docker_container_uptime_seconds",
 		"Uptime of the container in seconds",
-		[]string{"name"},
-		nil,
 	)
 
 	scrapeDurationSeconds = prometheus.NewDesc(
@@ -52,6 +56,11 @@ var (
 		nil,
 	)
 
+	containerCollectionDurationSeconds = newContainerDesc(
+		"docker_exporter_container_collection_duration_seconds",
+		"Duration of collecting the metrics of the container in seconds",
+	)
+
 	scrapeErrorsTotal = prometheus.NewDesc(
 		"docker_exporter_scrape_errors_total",
 		"Total number of scrape errors",
@@ -63,132 +72,108 @@ var (
 		CPU Metrics
 	*/
 
-	cpuUsageSecondsTotal = prometheus.NewDesc(
+	cpuUsageSecondsTotal = newContainerDesc(
 		"docker_container_cpu_usage_seconds_total",
 		"Total CPU time consumed in seconds",
-		[]string{"name"},
-		nil,
 	)
 
-	cpuOnlineCPUs = prometheus.NewDesc(
+	cpuOnlineCPUs = newContainerDesc(
 		"docker_container_cpu_online_cpus",
 		"Number of online CPUs",
-		[]string{"name"},
-		nil,
 	)
 
 	/*
 		Memory Metrics
 	*/
 
-	memoryUsageBytes = prometheus.NewDesc(
+	memoryUsageBytes = newContainerDesc(
 		"docker_container_memory_usage_bytes",
 		"Memory usage in bytes",
-		[]string{"name"},
-		nil,
 	)
 
-	memoryLimitBytes = prometheus.NewDesc(
+	memoryLimitBytes = newContainerDesc(
 		"docker_container_memory_limit_bytes",
 		"Memory limit in bytes",
-		[]string{"name"},
-		nil,
 	)
 
-	memoryUsageRatio = prometheus.NewDesc(
+	memoryUsageRatio = newContainerDesc(
 		"docker_container_memory_usage_ratio",
 		"Memory usage as a ratio of the limit (0-1)",
-		[]string{"name"},
-		nil,
 	)
 
 	/*
 		Network Metrics
 	*/
 
-	networkReceiveBytesTotal = prometheus.NewDesc(
+	networkReceiveBytesTotal = newContainerDesc(
 		"docker_container_network_receive_bytes_total",
 		"Total network bytes received",
-		[]string{"name", "network"},
-		nil,
+		"network",
 	)
 
-	networkReceivePacketsTotal = prometheus.NewDesc(
+	networkReceivePacketsTotal = newContainerDesc(
 		"docker_container_network_receive_packets_total",
 		"Total network packets received",
-		[]string{"name", "network"},
-		nil,
+		"network",
 	)
 
-	networkReceivePacketsDroppedTotal = prometheus.NewDesc(
+	networkReceivePacketsDroppedTotal = newContainerDesc(
 		"docker_container_network_receive_packets_dropped_total",
 		"Total network packets dropped while receiving",
-		[]string{"name", "network"},
-		nil,
+		"network",
 	)
 
-	networkReceiveErrorsTotal = prometheus.NewDesc(
+	networkReceiveErrorsTotal = newContainerDesc(
 		"docker_container_network_receive_errors_total",
 		"Total network receive errors",
-		[]string{"name", "network"},
-		nil,
+		"network",
 	)
 
-	networkTransmitBytesTotal = prometheus.NewDesc(
+	networkTransmitBytesTotal = newContainerDesc(
 		"docker_container_network_transmit_bytes_total",
 		"Total network bytes transmitted",
-		[]string{"name", "network"},
-		nil,
+		"network",
 	)
 
-	networkTransmitPacketsTotal = prometheus.NewDesc(
+	networkTransmitPacketsTotal = newContainerDesc(
 		"docker_container_network_transmit_packets_total",
 		"Total network packets transmitted",
-		[]string{"name", "network"},
-		nil,
+		"network",
 	)
 
-	networkTransmitPacketsDroppedTotal = prometheus.NewDesc(
+	networkTransmitPacketsDroppedTotal = newContainerDesc(
 		"docker_container_network_transmit_packets_dropped_total",
 		"Total network packets dropped while transmitting",
-		[]string{"name", "network"},
-		nil,
+		"network",
 	)
 
-	networkTransmitErrorsTotal = prometheus.NewDesc(
+	networkTransmitErrorsTotal = newContainerDesc(
 		"docker_container_network_transmit_errors_total",
 		"Total network transmit errors",
-		[]string{"name", "network"},
-		nil,
+		"network",
 	)
 
 	/*
 		Filesystem (Block I/O) Metrics
 	*/
 
-	fsReadsBytesTotal = prometheus.NewDesc(
+	fsReadsBytesTotal = newContainerDesc(
 		"docker_container_fs_reads_bytes_total",
 		"Total bytes read from block devices",
-		[]string{"name"},
-		nil,
 	)
 
-	fsWritesBytesTotal = prometheus.NewDesc(
+	fsWritesBytesTotal = newContainerDesc(
 		"docker_container_fs_writes_bytes_total",
 		"Total bytes written to block devices",
-		[]string{"name"},
-		nil,
 	)
 
 	/*
 		PIDs Metrics
 	*/
 
-	pidsCurrent = prometheus.NewDesc(
+	pidsCurrent = newContainerDesc(
 		"docker_container_pids_current",
 		"Current number of pids",
-		[]string{"name"},
-		nil,
 	)
 )
 
@@ -196,102 +181,82 @@ var (
 // alongside the standard metrics above. They will be removed in a future
 // release; prefer the replacements named in each help string.
 var (
-	cpuUsagePercentage = prometheus.NewDesc(
+	cpuUsagePercentage = newContainerDesc(
 		"docker_container_cpu_usage_percentage",
 		"CPU usage in percentage (deprecated; use docker_container_cpu_usage_seconds_total)",
-		[]string{"name"},
-		nil,
 	)
 
-	memoryTotalBytes = prometheus.NewDesc(
+	memoryTotalBytes = newContainerDesc(
 		"docker_container_memory_total_bytes",
 		"Total memory in bytes (deprecated; use docker_container_memory_limit_bytes)",
-		[]string{"name"},
-		nil,
 	)
 
-	memoryUsagePercentage = prometheus.NewDesc(
+	memoryUsagePercentage = newContainerDesc(
 		"docker_container_memory_usage_percentage",
 		"Memory usage in percentage (deprecated; use docker_container_memory_usage_ratio)",
-		[]string{"name"},
-		nil,
 	)
 
-	networkRxBytes = prometheus.NewDesc(
+	networkRxBytes = newContainerDesc(
 		"docker_container_network_rx_bytes",
 		"Network received bytes total (deprecated; use docker_container_network_receive_bytes_total)",
-		[]string{"name", "network"},
-		nil,
+		"network",
 	)
 
-	networkRxPackets = prometheus.NewDesc(
+	networkRxPackets = newContainerDesc(
 		"docker_container_network_rx_packets",
 		"Network received packets total (deprecated; use docker_container_network_receive_packets_total)",
-		[]string{"name", "network"},
-		nil,
+		"network",
 	)
 
-	networkRxDroppedPackets = prometheus.NewDesc(
+	networkRxDroppedPackets = newContainerDesc(
 		"docker_container_network_rx_dropped_packets",
 		"Network dropped packets total (deprecated; use docker_container_network_receive_packets_dropped_total)",
-		[]string{"name", "network"},
-		nil,
+		"network",
 	)
 
-	networkRxErrors = prometheus.NewDesc(
+	networkRxErrors = newContainerDesc(
 		"docker_container_network_rx_errors",
 		"Network received errors (deprecated; use docker_container_network_receive_errors_total)",
-		[]string{"name", "network"},
-		nil,
+		"network",
 	)
 
-	networkTxBytes = prometheus.NewDesc(
+	networkTxBytes = newContainerDesc(
 		"docker_container_network_tx_bytes",
 		"Network sent bytes total (deprecated; use docker_container_network_transmit_bytes_total)",
-		[]string{"name", "network"},
-		nil,
+		"network",
 	)
 
-	networkTxPackets = prometheus.NewDesc(
+	networkTxPackets = newContainerDesc(
 		"docker_container_network_tx_packets",
 		"Network sent packets total (deprecated; use docker_container_network_transmit_packets_total)",
-		[]string{"name", "network"},
-		nil,
+		"network",
 	)
 
-	networkTxDroppedPackets = prometheus.NewDesc(
+	networkTxDroppedPackets = newContainerDesc(
 		"docker_container_network_tx_dropped_packets",
 		"Network dropped packets total (deprecated; use docker_container_network_transmit_packets_dropped_total)",
-		[]string{"name", "network"},
-		nil,
+		"network",
 	)
 
-	networkTxErrors = prometheus.NewDesc(
+	networkTxErrors = newContainerDesc(
 		"docker_container_network_tx_errors",
 		"Network sent errors (deprecated; use docker_container_network_transmit_errors_total)",
-		[]string{"name", "network"},
-		nil,
+		"network",
 	)
 
-	blockIOReadBytes = prometheus.NewDesc(
+	blockIOReadBytes = newContainerDesc(
 		"docker_container_block_io_read_bytes",
 		"Block I/O read bytes total (deprecated; use docker_container_fs_reads_bytes_total)",
-		[]string{"name"},
-		nil,
 	)
 
-	blockIOWriteBytes = prometheus.NewDesc(
+	blockIOWriteBytes = newContainerDesc(
 		"docker_container_block_io_write_bytes",
 		"Block I/O write bytes total (deprecated; use docker_container_fs_writes_bytes_total)",
-		[]string{"name"},
-		nil,
 	)
 
-	containerUptime = prometheus.NewDesc(
+	containerUptime = newContainerDesc(
 		"docker_container_uptime",
 		"Uptime of the container in seconds (deprecated; use docker_container_uptime_seconds)",
-		[]string{"name"},
-		nil,
 	)
 
 	scrapeDuration = prometheus.NewDesc(
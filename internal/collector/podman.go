@@ -0,0 +1,278 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/errdefs"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+// Container engines the collector can talk to.
+const (
+	// EngineAuto detects the engine from the version endpoint.
+	EngineAuto   = "auto"
+	EngineDocker = "docker"
+	EnginePodman = "podman"
+)
+
+// libpodContainersPath lists containers through Podman's native API, which
+// unlike the Docker-compatible one reports pod membership.
+const libpodContainersPath = "/v4.0.0/libpod/containers/json"
+
+// engineRetryInterval is how long a failed detection of the engine is used
+// before the engine is detected again.
+const engineRetryInterval = 10 * time.Second
+
+// unlimitedMemory is reported by Podman as the memory limit of containers
+// without one when the memory controller is not delegated (rootless). The
+// limits cgroups report for containers without one are above it, too.
+const unlimitedMemory = 1 << 62
+
+// ValidateEngine returns an error if name is not a known engine.
+func ValidateEngine(name string) error {
+	switch name {
+	case "", EngineAuto, EngineDocker, EnginePodman:
+		return nil
+	}
+
+	return fmt.Errorf("unknown engine %q (valid: %s, %s, %s)",
+		name, EngineAuto, EngineDocker, EnginePodman)
+}
+
+// engine describes the container engine behind the Docker API.
+type engine struct {
+	podman bool
+	// ncpu and memTotal are the host's resources, used where Podman leaves
+	// the per-container values empty.
+	ncpu     int
+	memTotal int64
+}
+
+// podmanScrape holds the Podman specific state of a single scrape.
+type podmanScrape struct {
+	engine
+	// pods maps container IDs to the name of their pod.
+	pods map[string]string
+}
+
+// detectEngine resolves the configured engine, querying the version endpoint
+// in auto mode. The result is cached once detection succeeds. A failed
+// detection is retried after engineRetryInterval at the earliest; until then
+// its result, assuming docker, is used.
+//
+// Only one detection runs at a time, without holding engineMu. It is bounded
+// by the scrape timeout rather than by the ctx of the caller starting it, as
+// other callers share its result; each caller gives up once its ctx is done.
+func (c *DockerCollector) detectEngine(ctx context.Context) engine {
+	c.engineMu.Lock()
+	if c.detected != nil {
+		defer c.engineMu.Unlock()
+		return *c.detected
+	}
+
+	// Podman is only reachable through the docker runtime.
+	if c.client == nil || c.engineName == EngineDocker {
+		defer c.engineMu.Unlock()
+		c.detected = &engine{}
+		return *c.detected
+	}
+
+	if !c.detectFailed.IsZero() && c.clock.Now().Sub(c.detectFailed) < engineRetryInterval {
+		defer c.engineMu.Unlock()
+		return c.fallback
+	}
+	c.engineMu.Unlock()
+
+	done := c.detect.DoChan("", func() (any, error) {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), c.timeout)
+		defer cancel()
+
+		e, err := c.queryEngine(ctx)
+
+		c.engineMu.Lock()
+		defer c.engineMu.Unlock()
+
+		if err != nil {
+			c.detectFailed, c.fallback = c.clock.Now(), e
+			return e, nil
+		}
+
+		c.detected = &e
+		return e, nil
+	})
+
+	select {
+	case res := <-done:
+		return res.Val.(engine)
+	case <-ctx.Done():
+		return engine{}
+	}
+}
+
+// queryEngine asks the daemon for its engine. On error, the engine detected
+// so far is returned with it.
+func (c *DockerCollector) queryEngine(ctx context.Context) (engine, error) {
+	podman := c.engineName == EnginePodman
+	if !podman {
+		start := c.api.now()
+		version, err := c.client.ServerVersion(ctx)
+		c.api.observe(endpointVersion, start, err)
+		if err != nil {
+			log.WithError(err).
+				Warn("failed to detect container engine - assuming docker")
+			return engine{}, err
+		}
+		podman = isPodman(version)
+	}
+
+	detected := engine{podman: podman}
+	if podman {
+		start := c.api.now()
+		info, err := c.client.Info(ctx)
+		c.api.observe(endpointInfo, start, err)
+		if err != nil {
+			log.WithError(err).
+				Warn("failed to fetch podman host info")
+			return detected, err
+		}
+		detected.ncpu = info.NCPU
+		detected.memTotal = info.MemTotal
+
+		log.Info("podman detected - enabling podman compatibility mode")
+	}
+
+	return detected, nil
+}
+
+// podmanScrape returns the Podman state for a scrape, or nil when the
+// daemon is not Podman. A failed pod lookup counts as a scrape error, which is
+// reported to self, but does not stop the collection.
+func (c *DockerCollector) podmanScrape(ctx context.Context, self chan<- prometheus.Metric) *podmanScrape {
+	e := c.detectEngine(ctx)
+	if !e.podman {
+		return nil
+	}
+
+	pods, err := c.podNames(ctx)
+	if err != nil {
+		log.WithError(err).
+			Error("failed to fetch podman pods")
+		c.collectScrapeError(self)
+	}
+
+	return &podmanScrape{engine: e, pods: pods}
+}
+
+// isPodman reports whether a version response comes from Podman.
+func isPodman(version types.Version) bool {
+	for _, component := range version.Components {
+		if strings.HasPrefix(component.Name, "Podman") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// podNames returns the pod name of every container that belongs to a pod,
+// keyed by container ID.
+func (c *DockerCollector) podNames(ctx context.Context) (map[string]string, error) {
+	start := c.api.now()
+	pods, err := c.fetchPodNames(ctx)
+	c.api.observe(endpointPods, start, err)
+
+	return pods, err
+}
+
+func (c *DockerCollector) fetchPodNames(ctx context.Context) (map[string]string, error) {
+	host, err := client.ParseHostURL(c.client.DaemonHost())
+	if err != nil {
+		return nil, err
+	}
+
+	addr := host.Host
+	if host.Scheme == "unix" || host.Scheme == "npipe" {
+		addr = client.DummyHost
+	}
+
+	u := url.URL{
+		Scheme:   c.apiScheme,
+		Host:     addr,
+		Path:     host.Path + libpodContainersPath,
+		RawQuery: "all=true",
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.client.HTTPClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, errdefs.FromStatusCode(
+			fmt.Errorf("unexpected status listing podman containers: %s", resp.Status),
+			resp.StatusCode)
+	}
+
+	var containers []struct {
+		ID      string `json:"Id"`
+		PodName string `json:"PodName"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&containers); err != nil {
+		return nil, err
+	}
+
+	pods := make(map[string]string, len(containers))
+	for _, ctr := range containers {
+		if ctr.PodName != "" {
+			pods[ctr.ID] = ctr.PodName
+		}
+	}
+
+	return pods, nil
+}
+
+// normalizeInspect aligns a Podman inspect response with Docker's: Podman
+// reports an empty health status instead of omitting it.
+func (p *podmanScrape) normalizeInspect(inspect *types.ContainerJSON) {
+	if inspect.State != nil && inspect.State.Health != nil && inspect.State.Health.Status == "" {
+		inspect.State.Health = nil
+	}
+}
+
+// normalizeStats aligns a Podman stats response with Docker's before the
+// cpu, memory and network metrics are derived from it.
+func (p *podmanScrape) normalizeStats(stats *container.StatsResponse) {
+	// Podman leaves online_cpus at 0 and omits percpu_usage on cgroup v2.
+	if stats.CPUStats.OnlineCPUs == 0 && len(stats.CPUStats.CPUUsage.PercpuUsage) == 0 && p.ncpu > 0 {
+		stats.CPUStats.OnlineCPUs = uint32(p.ncpu)
+		if stats.PreCPUStats.OnlineCPUs == 0 {
+			stats.PreCPUStats.OnlineCPUs = uint32(p.ncpu)
+		}
+	}
+
+	// Docker reports the host memory as the limit of unlimited containers,
+	// rootless Podman the maximum cgroup value.
+	if stats.MemoryStats.Limit >= unlimitedMemory && p.memTotal > 0 {
+		stats.MemoryStats.Limit = uint64(p.memTotal)
+	}
+
+	// Rootless containers without their own network namespace report an
+	// empty network entry which would otherwise show up as a network "".
+	delete(stats.Networks, "")
+}
@@ -0,0 +1,167 @@
+package collector_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidborzek/docker-exporter/internal/collector"
+	"github.com/davidborzek/docker-exporter/internal/mock"
+	"github.com/docker/docker/client"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+)
+
+// mockPodmanApi serves the recorded responses of a rootless Podman 4.9
+// service from testdata/podman.
+func mockPodmanApi(w http.ResponseWriter, r *http.Request) {
+	p := r.URL.Path
+
+	var file string
+	switch {
+	case strings.HasSuffix(p, "/libpod/containers/json"):
+		file = "libpod_containers.json"
+	case strings.HasSuffix(p, "/containers/json"):
+		file = "containers.json"
+	case strings.HasSuffix(p, "/stats"):
+		file = "stats_" + path.Base(path.Dir(p)) + ".json"
+	case strings.HasSuffix(p, "/json"):
+		file = "inspect_" + path.Base(path.Dir(p)) + ".json"
+	case strings.HasSuffix(p, "/version"):
+		file = "version.json"
+	case strings.HasSuffix(p, "/info"):
+		file = "info.json"
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	http.ServeFile(w, r, filepath.Join("testdata", "podman", file))
+}
+
+func newPodmanCollector(t *testing.T, handler http.HandlerFunc, engine string) *collector.DockerCollector {
+	t.Helper()
+
+	now := time.Now()
+	return newPodmanCollectorAt(t, handler, engine, func() time.Time { return now })
+}
+
+// newPodmanCollectorAt is newPodmanCollector with a clock reading now.
+func newPodmanCollectorAt(t *testing.T, handler http.HandlerFunc, engine string, now func() time.Time) *collector.DockerCollector {
+	t.Helper()
+
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+
+	cli, err := client.NewClientWithOpts(
+		client.WithHost(srv.URL),
+		client.WithHTTPClient(&http.Client{}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctrl := gomock.NewController(t)
+	mockClock := mock.NewMockClock(ctrl)
+	mockClock.EXPECT().Now().DoAndReturn(now).AnyTimes()
+	mockClock.EXPECT().
+		Parse(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(s1, s2 string) (time.Time, error) {
+			return time.Parse(s1, s2)
+		}).
+		AnyTimes()
+	mockClock.EXPECT().Since(gomock.Any()).Return(1 * time.Second).AnyTimes()
+
+	return collector.NewWithClient(cli, mockClock, collector.Options{
+		IgnoreLabel: ignoreLabel,
+		Engine:      engine,
+	})
+}
+
+func TestCollectPodmanMetrics(t *testing.T) {
+	dc := newPodmanCollector(t, mockPodmanApi, collector.EngineAuto)
+
+	const expected = `
+	# HELP docker_container_cpu_online_cpus Number of online CPUs
+	# TYPE docker_container_cpu_online_cpus gauge
+	docker_container_cpu_online_cpus{name="backup"} 8
+	docker_container_cpu_online_cpus{name="shop-web"} 8
+	# HELP docker_container_health Container health-check status (value 1 for the current status; 'none' when no HEALTHCHECK is defined)
+	# TYPE docker_container_health gauge
+	docker_container_health{name="backup",status="none"} 1
+	docker_container_health{name="shop-web",status="none"} 1
+	# HELP docker_container_info Infos about the container
+	# TYPE docker_container_info gauge
+	docker_container_info{image="sha256:92b11f67642b62bbb98e7e49169c346b30e20cd3c1c034d31087e46924b9312e",image_name="docker.io/library/nginx:1.25",name="shop-web",pod="shop",runtime="podman"} 1
+	docker_container_info{image="sha256:a606584aa9aa875552092ec9e1d62cb98d486f51f389609914039aabd9414687",image_name="docker.io/library/alpine:3.20",name="backup",pod="",runtime="podman"} 1
+	# HELP docker_container_memory_limit_bytes Memory limit in bytes
+	# TYPE docker_container_memory_limit_bytes gauge
+	docker_container_memory_limit_bytes{name="backup"} 5.36870912e+08
+	docker_container_memory_limit_bytes{name="shop-web"} 1.664657408e+10
+	# HELP docker_container_network_receive_bytes_total Total network bytes received
+	# TYPE docker_container_network_receive_bytes_total counter
+	docker_container_network_receive_bytes_total{name="shop-web",network="eth0"} 1024
+	`
+
+	err := testutil.CollectAndCompare(dc, strings.NewReader(expected),
+		"docker_container_cpu_online_cpus",
+		"docker_container_health",
+		"docker_container_info",
+		"docker_container_memory_limit_bytes",
+		"docker_container_network_receive_bytes_total",
+	)
+	assert.NoError(t, err)
+
+	assert.Equal(t, 0, testutil.CollectAndCount(dc, "docker_exporter_scrape_errors_total"))
+}
+
+func TestCollectPodmanPodLookupError(t *testing.T) {
+	dc := newPodmanCollector(t, func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.Path, "/libpod/") {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		mockPodmanApi(w, r)
+	}, collector.EngineAuto)
+
+	const expected = `
+	# HELP docker_exporter_scrape_errors_total Total number of scrape errors
+	# TYPE docker_exporter_scrape_errors_total counter
+	docker_exporter_scrape_errors_total 1
+	`
+
+	err := testutil.CollectAndCompare(dc, strings.NewReader(expected), "docker_exporter_scrape_errors_total")
+	assert.NoError(t, err)
+
+	// Containers are still collected, without a pod.
+	assert.Equal(t, 2, testutil.CollectAndCount(dc, "docker_container_info"))
+}
+
+func TestCollectDockerEngineSkipsPodmanMode(t *testing.T) {
+	dc := newPodmanCollector(t, mockPodmanApi, collector.EngineDocker)
+
+	const expected = `
+	# HELP docker_container_memory_limit_bytes Memory limit in bytes
+	# TYPE docker_container_memory_limit_bytes gauge
+	docker_container_memory_limit_bytes{name="backup"} 5.36870912e+08
+	docker_container_memory_limit_bytes{name="shop-web"} 1.8446744073709552e+19
+	`
+
+	err := testutil.CollectAndCompare(dc, strings.NewReader(expected), "docker_container_memory_limit_bytes")
+	assert.NoError(t, err)
+}
+
+func TestValidateEngine(t *testing.T) {
+	for _, name := range []string{"", collector.EngineAuto, collector.EngineDocker, collector.EnginePodman} {
+		assert.NoError(t, collector.ValidateEngine(name))
+	}
+
+	assert.EqualError(t, collector.ValidateEngine("containerd"),
+		`unknown engine "containerd" (valid: auto, docker, podman)`)
+}
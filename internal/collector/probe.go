@@ -0,0 +1,168 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/davidborzek/docker-exporter/internal/clock"
+	"github.com/davidborzek/docker-exporter/internal/docker"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// DefaultModule is the module used by probes that do not name one. Like any
+// other module, it must be configured.
+const DefaultModule = "default"
+
+// probeSchemes are the schemes of the Docker hosts a module may allow.
+var probeSchemes = []string{"tcp", "unix", "ssh"}
+
+// DefaultProbeSchemes are the target schemes allowed by modules that do not
+// restrict them.
+var DefaultProbeSchemes = []string{"tcp"}
+
+// Module is a named set of connection and collection settings applied to
+// every daemon probed with it.
+type Module struct {
+	APIVersion string
+	TLS        *docker.TLSConfig
+	SSH        docker.SSHConfig
+	Options    Options
+	// AllowedSchemes are the schemes of the targets probed with the module,
+	// e.g. "tcp" or "ssh". Empty allows DefaultProbeSchemes.
+	AllowedSchemes []string
+	// AllowedHosts are glob patterns, see path.Match, of the host names of
+	// the targets probed with the module, or of the socket paths of unix
+	// targets. Empty allows any host.
+	AllowedHosts []string
+}
+
+// ValidateProbeTargets checks that schemes are schemes of Docker hosts and
+// that hosts are valid glob patterns.
+func ValidateProbeTargets(schemes, hosts []string) error {
+	for _, scheme := range schemes {
+		if !slices.Contains(probeSchemes, scheme) {
+			return fmt.Errorf("unknown target scheme %q: expected one of %s", scheme, strings.Join(probeSchemes, ", "))
+		}
+	}
+
+	for _, host := range hosts {
+		if _, err := path.Match(host, ""); err != nil {
+			return fmt.Errorf("invalid host pattern %q: %w", host, err)
+		}
+	}
+
+	return nil
+}
+
+// allows reports an error unless host, a Docker host URL, may be probed with
+// the module.
+func (m Module) allows(host string) error {
+	u, err := url.Parse(host)
+	if err != nil {
+		return err
+	}
+
+	schemes := m.AllowedSchemes
+	if len(schemes) == 0 {
+		schemes = DefaultProbeSchemes
+	}
+	if !slices.Contains(schemes, u.Scheme) {
+		return fmt.Errorf("scheme %q is not allowed", u.Scheme)
+	}
+
+	if len(m.AllowedHosts) == 0 {
+		return nil
+	}
+
+	name := u.Hostname()
+	if u.Scheme == "unix" {
+		name = u.Path
+	}
+	for _, pattern := range m.AllowedHosts {
+		if ok, _ := path.Match(pattern, name); ok {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("host %q is not allowed", name)
+}
+
+// Prober builds short-lived collectors for remote Docker daemons, in the
+// style of the blackbox and snmp exporters' multi-target pattern.
+type Prober struct {
+	clock clock.Clock
+
+	mu      sync.RWMutex
+	modules map[string]Module
+}
+
+func NewProber(clk clock.Clock, modules map[string]Module) *Prober {
+	return &Prober{
+		clock:   clk,
+		modules: modules,
+	}
+}
+
+// SetModules replaces the modules, e.g. after the configuration was
+// reloaded. Probes already running keep the module they started with.
+func (p *Prober) SetModules(modules map[string]Module) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.modules = modules
+}
+
+// Probe creates a collector for the daemon at target using the named module.
+// Its requests to the daemon are canceled with ctx or after the timeout of the
+// module. The returned function closes the collector's Docker client and must
+// be called once the probe has been served.
+func (p *Prober) Probe(ctx context.Context, target, module string) (prometheus.Collector, func(), error) {
+	if module == "" {
+		module = DefaultModule
+	}
+
+	p.mu.RLock()
+	m, ok := p.modules[module]
+	p.mu.RUnlock()
+
+	if !ok {
+		return nil, nil, fmt.Errorf("unknown module %q", module)
+	}
+
+	host := probeHost(target)
+	if err := m.allows(host); err != nil {
+		return nil, nil, fmt.Errorf("target %q is not allowed by module %q: %w", target, module, err)
+	}
+
+	cfg := docker.Config{
+		Host:       host,
+		APIVersion: m.APIVersion,
+		TLS:        m.TLS,
+		SSH:        m.SSH,
+	}
+
+	cli, err := docker.NewClient(cfg)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid target %q: %w", target, err)
+	}
+
+	dc := NewWithClient(cli, p.clock, m.Options)
+	dc.apiScheme = cfg.Scheme()
+
+	return dc.WithContext(ctx), func() { _ = dc.Close() }, nil
+}
+
+// probeHost turns a probe target into a Docker host, defaulting to TCP when
+// no scheme is given so "host:2376" can be used directly.
+func probeHost(target string) string {
+	if strings.Contains(target, "://") {
+		return target
+	}
+
+	return "tcp://" + target
+}
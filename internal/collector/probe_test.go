@@ -0,0 +1,167 @@
+package collector_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidborzek/docker-exporter/internal/clock"
+	"github.com/davidborzek/docker-exporter/internal/collector"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProbeCollectsTarget(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(mockDockerApi))
+	defer srv.Close()
+
+	p := collector.NewProber(clock.NewClock(), map[string]collector.Module{
+		"no-network": {
+			Options: collector.Options{
+				IgnoreLabel: ignoreLabel,
+				Collectors:  collector.Collectors{collector.CollectorNetwork: false},
+			},
+		},
+	})
+
+	c, release, err := p.Probe(context.Background(), srv.Listener.Addr().String(), "no-network")
+	require.NoError(t, err)
+	defer release()
+
+	const expected = `
+	# HELP docker_container_info Infos about the container
+	# TYPE docker_container_info gauge
+	docker_container_info{image="sha256:d3751d33f9cd5049c4af2b462735457e4d3baf130bcbb87f389e349fbaeb20b9",image_name="myImage",name="testName",runtime="docker"} 1
+	`
+
+	if err := testutil.CollectAndCompare(c, strings.NewReader(expected),
+		"docker_container_info",
+		"docker_container_network_receive_bytes_total",
+	); err != nil {
+		t.Errorf("unexpected collecting result:\n%s", err)
+	}
+}
+
+func TestProbeUsesDefaultModule(t *testing.T) {
+	p := collector.NewProber(clock.NewClock(), map[string]collector.Module{
+		collector.DefaultModule: {},
+	})
+
+	_, release, err := p.Probe(context.Background(), "docker:2376", "")
+	require.NoError(t, err)
+	release()
+}
+
+func TestProbeRequiresDefaultModule(t *testing.T) {
+	p := collector.NewProber(clock.NewClock(), nil)
+
+	_, _, err := p.Probe(context.Background(), "docker:2376", "")
+	assert.ErrorContains(t, err, `unknown module "default"`)
+}
+
+func TestProbeReturnsErrorForUnknownModule(t *testing.T) {
+	p := collector.NewProber(clock.NewClock(), nil)
+
+	_, _, err := p.Probe(context.Background(), "docker:2376", "unknown")
+	assert.ErrorContains(t, err, `unknown module "unknown"`)
+}
+
+func TestProbeRestrictsTargets(t *testing.T) {
+	p := collector.NewProber(clock.NewClock(), map[string]collector.Module{
+		collector.DefaultModule: {},
+		"internal": {
+			AllowedSchemes: []string{"tcp", "ssh"},
+			AllowedHosts:   []string{"*.docker.internal", "10.0.0.*"},
+		},
+		"local": {
+			AllowedSchemes: []string{"unix"},
+			AllowedHosts:   []string{"/run/docker/*.sock"},
+		},
+	})
+
+	for _, tc := range []struct {
+		target, module, err string
+	}{
+		{target: "docker:2376"},
+		{target: "tcp://docker:2376"},
+		{target: "unix:///var/run/docker.sock", err: `scheme "unix" is not allowed`},
+		{target: "ssh://user@docker", err: `scheme "ssh" is not allowed`},
+		{target: "http://docker:2375", err: `scheme "http" is not allowed`},
+		{target: "a.docker.internal:2376", module: "internal"},
+		{target: "ssh://user@10.0.1.7", module: "internal", err: `host "10.0.1.7" is not allowed`},
+		{target: "metadata.google.internal:80", module: "internal", err: `host "metadata.google.internal" is not allowed`},
+		{target: "10.0.1.7:2376", module: "internal", err: `host "10.0.1.7" is not allowed`},
+		{target: "unix:///run/docker/a.sock", module: "local"},
+		{target: "unix:///var/run/docker.sock", module: "local", err: `host "/var/run/docker.sock" is not allowed`},
+	} {
+		t.Run(tc.module+"/"+tc.target, func(t *testing.T) {
+			_, release, err := p.Probe(context.Background(), tc.target, tc.module)
+			if tc.err != "" {
+				assert.ErrorContains(t, err, tc.err)
+				return
+			}
+
+			require.NoError(t, err)
+			release()
+		})
+	}
+}
+
+func TestProbeTimesOut(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+
+	p := collector.NewProber(clock.NewClock(), map[string]collector.Module{
+		collector.DefaultModule: {Options: collector.Options{Timeout: 50 * time.Millisecond}},
+	})
+
+	c, release, err := p.Probe(context.Background(), srv.Listener.Addr().String(), "")
+	require.NoError(t, err)
+	defer release()
+
+	const expected = `
+	# HELP docker_exporter_scrape_errors_total Total number of scrape errors
+	# TYPE docker_exporter_scrape_errors_total counter
+	docker_exporter_scrape_errors_total 1
+	`
+
+	start := time.Now()
+	if err := testutil.CollectAndCompare(c, strings.NewReader(expected),
+		"docker_exporter_scrape_errors_total",
+	); err != nil {
+		t.Errorf("unexpected collecting result:\n%s", err)
+	}
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
+
+func mockJsonResponse(w http.ResponseWriter, r *http.Request, body any) {
+	raw, err := json.Marshal(body)
+	if err != nil {
+		panic(err)
+	}
+
+	_, _ = w.Write(raw)
+}
+
+// mockDockerApi emulates the Docker API of a probed daemon serving the
+// fixture containers.
+func mockDockerApi(w http.ResponseWriter, r *http.Request) {
+	if strings.Contains(r.URL.Path, "stats") {
+		mockJsonResponse(w, r, buildStatsResponse())
+		return
+	}
+
+	if strings.Contains(r.URL.Path, "testID") {
+		mockJsonResponse(w, r, buildInspectResponse())
+		return
+	}
+
+	mockJsonResponse(w, r, buildContainerListResponse())
+}
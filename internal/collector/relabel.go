@@ -0,0 +1,246 @@
+package collector
+
+import (
+	"crypto/md5"
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+)
+
+// Relabeling actions, with the semantics of Prometheus' relabel_configs.
+const (
+	RelabelReplace   = "replace"
+	RelabelKeep      = "keep"
+	RelabelDrop      = "drop"
+	RelabelHashMod   = "hashmod"
+	RelabelLabelMap  = "labelmap"
+	RelabelLabelDrop = "labeldrop"
+	RelabelLabelKeep = "labelkeep"
+)
+
+// Meta labels describing a container to the relabeling rules. Like all labels
+// starting with "__", they are removed once the rules were applied.
+const (
+	metaContainerID            = "__meta_docker_container_id"
+	metaContainerName          = "__meta_docker_container_name"
+	metaContainerImage         = "__meta_docker_container_image"
+	metaContainerState         = "__meta_docker_container_state"
+	metaContainerLabelPrefix   = "__meta_docker_container_label_"
+	metaComposeProject         = "__meta_docker_compose_project"
+	metaComposeService         = "__meta_docker_compose_service"
+	metaComposeContainerNumber = "__meta_docker_compose_container_number"
+
+	reservedLabelPrefix = "__"
+)
+
+// DefaultRelabelConfig holds the values of the fields a relabeling rule does
+// not set.
+var DefaultRelabelConfig = RelabelConfig{
+	Action:      RelabelReplace,
+	Separator:   ";",
+	Regex:       "(.*)",
+	Replacement: "$1",
+}
+
+// containerFamilyLabels are the labels of the per-container metric families
+// themselves. Relabeling must not produce them.
+var containerFamilyLabels = []string{"state", "status", "image_name", "image", "runtime", "pod", "network"}
+
+var validLabelName = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// RelabelConfig is a relabeling rule applied to the labels of a container
+// before its metrics are emitted. It follows Prometheus' relabel_config.
+type RelabelConfig struct {
+	SourceLabels []string
+	Separator    string
+	// Regex is anchored at both ends.
+	Regex       string
+	Modulus     uint64
+	TargetLabel string
+	Replacement string
+	Action      string
+}
+
+// Relabeler applies relabeling rules to containers. A nil *Relabeler leaves
+// the labels unchanged.
+type Relabeler struct {
+	rules []relabelRule
+}
+
+type relabelRule struct {
+	RelabelConfig
+	regex *regexp.Regexp
+}
+
+// NewRelabeler compiles the rules. It returns nil when there are none.
+func NewRelabeler(configs []RelabelConfig) (*Relabeler, error) {
+	if len(configs) == 0 {
+		return nil, nil
+	}
+
+	r := &Relabeler{rules: make([]relabelRule, 0, len(configs))}
+
+	for i, cfg := range configs {
+		if cfg.Action == "" {
+			cfg.Action = DefaultRelabelConfig.Action
+		}
+
+		regex, err := regexp.Compile("^(?:" + cfg.Regex + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("invalid relabel config %d: invalid regex %q: %w", i, cfg.Regex, err)
+		}
+
+		if err := cfg.validate(); err != nil {
+			return nil, fmt.Errorf("invalid relabel config %d: %w", i, err)
+		}
+
+		r.rules = append(r.rules, relabelRule{RelabelConfig: cfg, regex: regex})
+	}
+
+	return r, nil
+}
+
+func (cfg RelabelConfig) validate() error {
+	switch cfg.Action {
+	case RelabelReplace:
+		if cfg.TargetLabel == "" {
+			return fmt.Errorf("target label is required for action %q", cfg.Action)
+		}
+	case RelabelHashMod:
+		if cfg.TargetLabel == "" {
+			return fmt.Errorf("target label is required for action %q", cfg.Action)
+		}
+		if cfg.Modulus == 0 {
+			return fmt.Errorf("modulus is required for action %q", cfg.Action)
+		}
+		if !validLabelName.MatchString(cfg.TargetLabel) {
+			return fmt.Errorf("invalid target label %q", cfg.TargetLabel)
+		}
+	case RelabelKeep, RelabelDrop:
+		if len(cfg.SourceLabels) == 0 {
+			return fmt.Errorf("source labels are required for action %q", cfg.Action)
+		}
+	case RelabelLabelMap, RelabelLabelDrop, RelabelLabelKeep:
+	default:
+		return fmt.Errorf("unknown action %q (valid: %s)", cfg.Action, strings.Join([]string{
+			RelabelReplace, RelabelKeep, RelabelDrop, RelabelHashMod,
+			RelabelLabelMap, RelabelLabelDrop, RelabelLabelKeep,
+		}, ", "))
+	}
+
+	return nil
+}
+
+// process applies the rules to labels, which it modifies. It reports false
+// when a keep or drop rule removed the container.
+func (r *Relabeler) process(labels map[string]string) bool {
+	if r == nil {
+		return true
+	}
+
+	for _, rule := range r.rules {
+		if !rule.apply(labels) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (rule relabelRule) apply(labels map[string]string) bool {
+	values := make([]string, 0, len(rule.SourceLabels))
+	for _, name := range rule.SourceLabels {
+		values = append(values, labels[name])
+	}
+	value := strings.Join(values, rule.Separator)
+
+	switch rule.Action {
+	case RelabelKeep:
+		return rule.regex.MatchString(value)
+	case RelabelDrop:
+		return !rule.regex.MatchString(value)
+	case RelabelReplace:
+		indexes := rule.regex.FindStringSubmatchIndex(value)
+		if indexes == nil {
+			break
+		}
+
+		target := string(rule.regex.ExpandString(nil, rule.TargetLabel, value, indexes))
+		if !validLabelName.MatchString(target) {
+			break
+		}
+
+		setLabel(labels, target, string(rule.regex.ExpandString(nil, rule.Replacement, value, indexes)))
+	case RelabelHashMod:
+		setLabel(labels, rule.TargetLabel, fmt.Sprint(sum64(md5.Sum([]byte(value)))%rule.Modulus))
+	case RelabelLabelMap:
+		for _, name := range slices.Sorted(maps.Keys(labels)) {
+			if rule.regex.MatchString(name) {
+				target := rule.regex.ReplaceAllString(name, rule.Replacement)
+				if validLabelName.MatchString(target) {
+					setLabel(labels, target, labels[name])
+				}
+			}
+		}
+	case RelabelLabelDrop, RelabelLabelKeep:
+		for name := range labels {
+			if rule.regex.MatchString(name) == (rule.Action == RelabelLabelDrop) {
+				delete(labels, name)
+			}
+		}
+	}
+
+	return true
+}
+
+// setLabel sets a label, removing it for an empty value like Prometheus does.
+func setLabel(labels map[string]string, name, value string) {
+	if value == "" {
+		delete(labels, name)
+		return
+	}
+
+	labels[name] = value
+}
+
+// sum64 folds an MD5 hash like Prometheus' hashmod action, so a container
+// lands in the same shard as with the same rule in Prometheus.
+func sum64(hash [md5.Size]byte) uint64 {
+	var s uint64
+	for i, b := range hash {
+		shift := uint64((md5.Size - 1 - i) * 8)
+		s |= uint64(b) << shift
+	}
+
+	return s
+}
+
+// metaLabels returns the meta labels of a container.
+func metaLabels(container types.Container) map[string]string {
+	labels := map[string]string{
+		metaContainerID:    container.ID,
+		metaContainerName:  containerName(container),
+		metaContainerImage: container.Image,
+		metaContainerState: container.State,
+	}
+
+	for key, value := range container.Labels {
+		labels[metaContainerLabelPrefix+invalidLabelChar.ReplaceAllString(key, "_")] = value
+	}
+
+	for name, key := range map[string]string{
+		metaComposeProject:         composeProjectLabel,
+		metaComposeService:         identityDockerLabels[IdentityComposeService],
+		metaComposeContainerNumber: identityDockerLabels[IdentityComposeContainerNumber],
+	} {
+		if value, ok := container.Labels[key]; ok {
+			labels[name] = value
+		}
+	}
+
+	return labels
+}
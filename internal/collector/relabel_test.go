@@ -0,0 +1,202 @@
+package collector_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidborzek/docker-exporter/internal/collector"
+	"github.com/davidborzek/docker-exporter/internal/mock"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+// relabelRule returns a rule with the defaults of the config file.
+func relabelRule(apply func(*collector.RelabelConfig)) collector.RelabelConfig {
+	rc := collector.DefaultRelabelConfig
+	apply(&rc)
+
+	return rc
+}
+
+func TestCollectMetricsAppliesRelabeling(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	list := []types.Container{
+		{ID: "webID", Names: []string{"/shop-web-1"}, Image: "nginx:1.27", State: "exited", Labels: map[string]string{
+			"com.docker.compose.project": "shop",
+			"com.docker.compose.service": "web",
+			"team.name":                  "checkout",
+		}},
+		{ID: "debugID", Names: []string{"/debug"}, Image: "busybox", State: "exited", Labels: map[string]string{
+			"debug": "true",
+		}},
+	}
+
+	api := newMockAPI(ctrl)
+	api.EXPECT().
+		ContainerList(gomock.Any(), container.ListOptions{All: true}).
+		Return(list, nil)
+	// The dropped container is not inspected.
+	api.EXPECT().
+		ContainerInspect(gomock.Any(), "webID").
+		Return(buildInspectResponse(), nil)
+
+	mockClock := mock.NewMockClock(ctrl)
+	mockClock.EXPECT().Now().Return(time.Now()).AnyTimes()
+	mockClock.EXPECT().Since(gomock.Any()).Return(1 * time.Second).Times(1)
+
+	relabeler, err := collector.NewRelabeler([]collector.RelabelConfig{
+		relabelRule(func(rc *collector.RelabelConfig) {
+			rc.Action = collector.RelabelDrop
+			rc.SourceLabels = []string{"__meta_docker_container_label_debug"}
+			rc.Regex = "true"
+		}),
+		relabelRule(func(rc *collector.RelabelConfig) {
+			rc.SourceLabels = []string{"__meta_docker_compose_project", "__meta_docker_compose_service"}
+			rc.Separator = "/"
+			rc.TargetLabel = "service"
+		}),
+		relabelRule(func(rc *collector.RelabelConfig) {
+			rc.SourceLabels = []string{"__meta_docker_container_image"}
+			rc.Regex = "([^:]+):.*"
+			rc.TargetLabel = "image_repository"
+		}),
+		relabelRule(func(rc *collector.RelabelConfig) {
+			rc.Action = collector.RelabelLabelMap
+			rc.Regex = "__meta_docker_container_label_team_(.+)"
+			rc.Replacement = "team_$1"
+		}),
+		relabelRule(func(rc *collector.RelabelConfig) {
+			rc.Action = collector.RelabelLabelDrop
+			rc.Regex = "name"
+		}),
+	})
+	require.NoError(t, err)
+
+	dc := collector.NewWithClient(api, mockClock, collector.Options{
+		IgnoreLabel: ignoreLabel,
+		Relabeler:   relabeler,
+	})
+
+	const expected = `
+	# HELP docker_container_state State of the container
+	# TYPE docker_container_state gauge
+	docker_container_state{image_repository="nginx",service="shop/web",state="exited",team_name="checkout"} 1
+	`
+
+	err = testutil.CollectAndCompare(dc, strings.NewReader(expected), "docker_container_state")
+	assert.NoError(t, err)
+}
+
+func TestCollectMetricsShardsContainersWithHashMod(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	var list []types.Container
+	for _, name := range []string{"a", "b", "c", "d", "e", "f", "g", "h"} {
+		list = append(list, types.Container{ID: name + "ID", Names: []string{"/" + name}, State: "exited"})
+	}
+
+	relabeler := func(shard string) *collector.Relabeler {
+		r, err := collector.NewRelabeler([]collector.RelabelConfig{
+			relabelRule(func(rc *collector.RelabelConfig) {
+				rc.Action = collector.RelabelHashMod
+				rc.SourceLabels = []string{"__meta_docker_container_name"}
+				rc.Modulus = 2
+				rc.TargetLabel = "__tmp_shard"
+			}),
+			relabelRule(func(rc *collector.RelabelConfig) {
+				rc.Action = collector.RelabelKeep
+				rc.SourceLabels = []string{"__tmp_shard"}
+				rc.Regex = shard
+			}),
+		})
+		require.NoError(t, err)
+
+		return r
+	}
+
+	// Every container is collected by exactly one of the two shards.
+	total := 0
+	for _, shard := range []string{"0", "1"} {
+		api := newMockAPI(ctrl)
+		api.EXPECT().
+			ContainerList(gomock.Any(), container.ListOptions{All: true}).
+			Return(list, nil)
+		api.EXPECT().
+			ContainerInspect(gomock.Any(), gomock.Any()).
+			Return(buildInspectResponse(), nil).
+			AnyTimes()
+
+		mockClock := mock.NewMockClock(ctrl)
+		mockClock.EXPECT().Now().Return(time.Now()).AnyTimes()
+		mockClock.EXPECT().Since(gomock.Any()).Return(1 * time.Second).Times(1)
+
+		dc := collector.NewWithClient(api, mockClock, collector.Options{
+			IgnoreLabel: ignoreLabel,
+			Relabeler:   relabeler(shard),
+		})
+
+		count := testutil.CollectAndCount(dc, "docker_container_state")
+		assert.Positive(t, count)
+		total += count
+	}
+
+	assert.Equal(t, len(list), total)
+}
+
+func TestNewRelabelerErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		apply   func(*collector.RelabelConfig)
+		wantErr string
+	}{
+		{
+			name:    "unknown action",
+			apply:   func(rc *collector.RelabelConfig) { rc.Action = "labeldrop_all" },
+			wantErr: `invalid relabel config 0: unknown action "labeldrop_all"`,
+		},
+		{
+			name:    "invalid regex",
+			apply:   func(rc *collector.RelabelConfig) { rc.Regex = "(" },
+			wantErr: `invalid relabel config 0: invalid regex "("`,
+		},
+		{
+			name:    "replace without target",
+			apply:   func(rc *collector.RelabelConfig) {},
+			wantErr: `target label is required for action "replace"`,
+		},
+		{
+			name: "hashmod without modulus",
+			apply: func(rc *collector.RelabelConfig) {
+				rc.Action = collector.RelabelHashMod
+				rc.TargetLabel = "__tmp_shard"
+			},
+			wantErr: `modulus is required for action "hashmod"`,
+		},
+		{
+			name:    "keep without source labels",
+			apply:   func(rc *collector.RelabelConfig) { rc.Action = collector.RelabelKeep },
+			wantErr: `source labels are required for action "keep"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := collector.NewRelabeler([]collector.RelabelConfig{relabelRule(tt.apply)})
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestNewRelabelerEmpty(t *testing.T) {
+	r, err := collector.NewRelabeler(nil)
+	require.NoError(t, err)
+	assert.Nil(t, r)
+}
@@ -0,0 +1,122 @@
+package collector
+
+import (
+	"context"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+// Reloadable is a prometheus.Collector delegating to a DockerCollector that
+// can be replaced while scrapes are running, e.g. after the configuration was
+// reloaded.
+type Reloadable struct {
+	mu      sync.RWMutex
+	current *generation
+}
+
+// generation is a collector together with the scrapes currently using it.
+type generation struct {
+	collector *DockerCollector
+	inflight  sync.WaitGroup
+}
+
+func NewReloadable(c *DockerCollector) *Reloadable {
+	return &Reloadable{current: &generation{collector: c}}
+}
+
+func (r *Reloadable) Describe(_ chan<- *prometheus.Desc) {}
+
+func (r *Reloadable) Collect(ch chan<- prometheus.Metric) {
+	r.collect(func(c *DockerCollector) { c.Collect(ch) })
+}
+
+// ContainerMetrics returns a collector of the container metrics of the
+// current collector, see DockerCollector.ContainerMetrics.
+func (r *Reloadable) ContainerMetrics(ctx context.Context) prometheus.Collector {
+	return collectorFunc(func(ch chan<- prometheus.Metric) {
+		r.collect(func(c *DockerCollector) { c.collectContainers(ctx, ch) })
+	})
+}
+
+// ExporterMetrics returns a collector of the exporter metrics of the current
+// collector, see DockerCollector.ExporterMetrics. They start over after a
+// reload.
+func (r *Reloadable) ExporterMetrics() prometheus.Collector {
+	return collectorFunc(func(ch chan<- prometheus.Metric) {
+		r.collect(func(c *DockerCollector) { c.collectExporter(ch) })
+	})
+}
+
+// WithContext returns a collector of the metrics of the current collector,
+// see DockerCollector.WithContext.
+func (r *Reloadable) WithContext(ctx context.Context) prometheus.Collector {
+	return collectorFunc(func(ch chan<- prometheus.Metric) {
+		r.collect(func(c *DockerCollector) { c.collect(ctx, ch) })
+	})
+}
+
+// Scoped returns a collector of the metrics of the containers of the current
+// collector matching scope, see DockerCollector.Scoped.
+func (r *Reloadable) Scoped(ctx context.Context, scope *Filter) prometheus.Collector {
+	return collectorFunc(func(ch chan<- prometheus.Metric) {
+		r.collect(func(c *DockerCollector) { c.Scoped(ctx, scope).Collect(ch) })
+	})
+}
+
+// Ping checks that the container runtime of the current collector is
+// reachable, see DockerCollector.Ping.
+func (r *Reloadable) Ping(ctx context.Context) error {
+	var err error
+	r.collect(func(c *DockerCollector) { err = c.Ping(ctx) })
+
+	return err
+}
+
+// Warm checks the state of the current collector, see DockerCollector.Warm.
+func (r *Reloadable) Warm(ctx context.Context) error {
+	var err error
+	r.collect(func(c *DockerCollector) { err = c.Warm(ctx) })
+
+	return err
+}
+
+// collect calls f with the current collector, which is not closed until f
+// returns.
+func (r *Reloadable) collect(f func(c *DockerCollector)) {
+	r.mu.RLock()
+	g := r.current
+	g.inflight.Add(1)
+	r.mu.RUnlock()
+
+	defer g.inflight.Done()
+
+	f(g.collector)
+}
+
+// Swap makes c collect all following scrapes. The previous collector is
+// closed once the scrapes still using it have finished.
+func (r *Reloadable) Swap(c *DockerCollector) {
+	r.mu.Lock()
+	previous := r.current
+	r.current = &generation{collector: c}
+	r.mu.Unlock()
+
+	go func() {
+		previous.inflight.Wait()
+
+		if err := previous.collector.Close(); err != nil {
+			log.WithError(err).
+				Warn("failed to close the previous collector")
+		}
+	}()
+}
+
+// Close closes the current collector.
+func (r *Reloadable) Close() error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.current.collector.Close()
+}
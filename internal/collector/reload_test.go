@@ -0,0 +1,82 @@
+package collector_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/davidborzek/docker-exporter/internal/collector"
+	"github.com/davidborzek/docker-exporter/internal/mock"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+// blockingRuntime lists its containers once release is closed.
+type blockingRuntime struct {
+	*fakeRuntime
+	listing chan struct{}
+	release chan struct{}
+	closed  chan struct{}
+}
+
+func (r *blockingRuntime) ContainerList(ctx context.Context, f filters.Args) ([]types.Container, error) {
+	close(r.listing)
+	<-r.release
+
+	return r.fakeRuntime.ContainerList(ctx, f)
+}
+
+func (r *blockingRuntime) Close() error {
+	close(r.closed)
+	return nil
+}
+
+func TestReloadableSwapWaitsForRunningScrapes(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockClock := mock.NewMockClock(ctrl)
+	mockClock.EXPECT().Now().Return(time.Now()).AnyTimes()
+	mockClock.EXPECT().Since(gomock.Any()).Return(1 * time.Second).AnyTimes()
+
+	old := &blockingRuntime{
+		fakeRuntime: newFakeRuntime(),
+		listing:     make(chan struct{}),
+		release:     make(chan struct{}),
+		closed:      make(chan struct{}),
+	}
+	r := collector.NewReloadable(collector.NewWithRuntime(old, mockClock, collector.Options{}))
+
+	scraped := make(chan int)
+	go func() {
+		scraped <- testutil.CollectAndCount(r, "docker_container_state")
+	}()
+	<-old.listing
+
+	// A container filter drops every container collected after the swap.
+	f, err := collector.NewFilter(collector.FilterConfig{Names: []string{"none"}})
+	require.NoError(t, err)
+	r.Swap(collector.NewWithRuntime(newFakeRuntime(), mockClock, collector.Options{Filter: f}))
+
+	select {
+	case <-old.closed:
+		t.Fatal("previous collector was closed during a running scrape")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	assert.Equal(t, 0, testutil.CollectAndCount(r, "docker_container_state"))
+
+	// The running scrape completes with the previous settings.
+	close(old.release)
+	assert.Equal(t, 2, <-scraped)
+
+	select {
+	case <-old.closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("previous collector was not closed")
+	}
+}
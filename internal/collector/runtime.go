@@ -0,0 +1,119 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/davidborzek/docker-exporter/internal/docker"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+)
+
+// Container runtimes the collector can read containers from.
+const (
+	RuntimeDocker     = "docker"
+	RuntimeContainerd = "containerd"
+)
+
+// ValidateRuntime returns an error if name is not a known runtime.
+func ValidateRuntime(name string) error {
+	switch name {
+	case "", RuntimeDocker, RuntimeContainerd:
+		return nil
+	}
+
+	return fmt.Errorf("unknown runtime %q (valid: %s, %s)",
+		name, RuntimeDocker, RuntimeContainerd)
+}
+
+// Runtime is the source of the containers a DockerCollector reports on.
+// Containers are exchanged in the Docker API's types, which every runtime maps
+// its own representation to, so the same metric families are emitted
+// regardless of the runtime.
+type Runtime interface {
+	// Name is the runtime reported in the runtime label.
+	Name() string
+	// ContainerList returns all containers, including stopped ones. Runtimes
+	// may use the Docker list filters to narrow the result, but do not have
+	// to: the collector applies its filter to every container it receives.
+	ContainerList(ctx context.Context, filters filters.Args) ([]types.Container, error)
+	// ContainerInspect returns the details of the container with the ID
+	// reported by ContainerList.
+	ContainerInspect(ctx context.Context, id string) (types.ContainerJSON, error)
+	// ContainerStats returns a single resource usage sample of a running
+	// container.
+	ContainerStats(ctx context.Context, id string) (*container.StatsResponse, error)
+	// Ping checks that the runtime is reachable.
+	Ping(ctx context.Context) error
+	// Close releases the connection to the runtime.
+	Close() error
+}
+
+// dockerRuntime reads containers from a Docker (or Docker-compatible) daemon.
+type dockerRuntime struct {
+	client  docker.API
+	metrics *apiMetrics
+}
+
+func (r *dockerRuntime) Name() string {
+	return RuntimeDocker
+}
+
+func (r *dockerRuntime) Ping(ctx context.Context) error {
+	start := r.metrics.now()
+	_, err := r.client.Ping(ctx)
+	r.metrics.observe(endpointPing, start, err)
+
+	return err
+}
+
+func (r *dockerRuntime) ContainerList(ctx context.Context, filters filters.Args) ([]types.Container, error) {
+	start := r.metrics.now()
+	containers, err := r.client.ContainerList(ctx, container.ListOptions{All: true, Filters: filters})
+	r.metrics.observe(endpointList, start, err)
+
+	return containers, err
+}
+
+func (r *dockerRuntime) ContainerInspect(ctx context.Context, id string) (types.ContainerJSON, error) {
+	start := r.metrics.now()
+	inspect, err := r.client.ContainerInspect(ctx, id)
+	r.metrics.observe(endpointInspect, start, err)
+
+	return inspect, err
+}
+
+// ContainerStats requests a stats sample. The request is observed once the
+// response is decoded, as the daemon only streams the body while sampling.
+func (r *dockerRuntime) ContainerStats(ctx context.Context, id string) (*container.StatsResponse, error) {
+	start := r.metrics.now()
+	stats, err := r.containerStats(ctx, id)
+	r.metrics.observe(endpointStats, start, err)
+
+	return stats, err
+}
+
+func (r *dockerRuntime) containerStats(ctx context.Context, id string) (*container.StatsResponse, error) {
+	resp, err := r.client.ContainerStats(ctx, id, false)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() { _ = resp.Body.Close() }()
+
+	body := &countingReader{r: resp.Body}
+	defer r.metrics.decodedStats(body)
+
+	var stats container.StatsResponse
+	if err := json.NewDecoder(body).Decode(&stats); err != nil {
+		return nil, err
+	}
+
+	return &stats, nil
+}
+
+func (r *dockerRuntime) Close() error {
+	return r.client.Close()
+}
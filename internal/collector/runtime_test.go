@@ -0,0 +1,229 @@
+package collector_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidborzek/docker-exporter/internal/collector"
+	"github.com/davidborzek/docker-exporter/internal/mock"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+)
+
+// fakeRuntime is an in-memory collector.Runtime shaped like the containerd
+// runtime: containers are addressed by namespace and carry no start time.
+type fakeRuntime struct {
+	containers []types.Container
+	inspects   map[string]types.ContainerJSON
+	stats      map[string]*container.StatsResponse
+	listErr    error
+	pingErr    error
+	closed     bool
+}
+
+func (r *fakeRuntime) Name() string {
+	return collector.RuntimeContainerd
+}
+
+func (r *fakeRuntime) Ping(_ context.Context) error {
+	return r.pingErr
+}
+
+func (r *fakeRuntime) ContainerList(_ context.Context, _ filters.Args) ([]types.Container, error) {
+	return r.containers, r.listErr
+}
+
+func (r *fakeRuntime) ContainerInspect(_ context.Context, id string) (types.ContainerJSON, error) {
+	inspect, ok := r.inspects[id]
+	if !ok {
+		return types.ContainerJSON{}, errors.New("no such container")
+	}
+
+	return inspect, nil
+}
+
+func (r *fakeRuntime) ContainerStats(_ context.Context, id string) (*container.StatsResponse, error) {
+	stats, ok := r.stats[id]
+	if !ok {
+		return nil, errors.New("no task")
+	}
+
+	return stats, nil
+}
+
+func (r *fakeRuntime) Close() error {
+	r.closed = true
+	return nil
+}
+
+func newFakeRuntime() *fakeRuntime {
+	return &fakeRuntime{
+		containers: []types.Container{
+			{
+				ID:     "default/c1",
+				Names:  []string{"/web"},
+				Image:  "docker.io/library/nginx:1.25",
+				State:  "running",
+				Labels: map[string]string{"nerdctl/name": "web"},
+			},
+			{
+				ID:    "k8s.io/c2",
+				Names: []string{"/c2"},
+				Image: "registry.k8s.io/pause:3.9",
+				State: "exited",
+			},
+		},
+		inspects: map[string]types.ContainerJSON{
+			"default/c1": {
+				ContainerJSONBase: &types.ContainerJSONBase{
+					Image: "sha256:92b11f67642b62bbb98e7e49169c346b30e20cd3c1c034d31087e46924b9312e",
+					State: &types.ContainerState{Status: "running", Running: true},
+				},
+				Config: &container.Config{Image: "docker.io/library/nginx:1.25"},
+			},
+			"k8s.io/c2": {
+				ContainerJSONBase: &types.ContainerJSONBase{
+					Image: "sha256:e6f1816883972d4be47bd48879a08919b96afcd344132622e4d444987919323c",
+					State: &types.ContainerState{Status: "exited", ExitCode: 137},
+				},
+				Config: &container.Config{Image: "registry.k8s.io/pause:3.9"},
+			},
+		},
+		stats: map[string]*container.StatsResponse{
+			"default/c1": {
+				Stats: container.Stats{
+					CPUStats: container.CPUStats{
+						CPUUsage:   container.CPUUsage{TotalUsage: 1500000000},
+						OnlineCPUs: 2,
+					},
+					MemoryStats: container.MemoryStats{
+						Usage: 4194304,
+						Limit: 8388608,
+						Stats: map[string]uint64{"inactive_file": 0},
+					},
+					PidsStats: container.PidsStats{Current: 5},
+				},
+			},
+		},
+	}
+}
+
+func newRuntimeCollector(t *testing.T, rt collector.Runtime) *collector.DockerCollector {
+	t.Helper()
+
+	ctrl := gomock.NewController(t)
+	mockClock := mock.NewMockClock(ctrl)
+	mockClock.EXPECT().Now().Return(time.Now()).AnyTimes()
+	mockClock.EXPECT().Since(gomock.Any()).Return(1 * time.Second).AnyTimes()
+
+	return collector.NewWithRuntime(rt, mockClock, collector.Options{IgnoreLabel: ignoreLabel})
+}
+
+func TestCollectRuntimeMetrics(t *testing.T) {
+	dc := newRuntimeCollector(t, newFakeRuntime())
+
+	const expected = `
+	# HELP docker_container_cpu_usage_seconds_total Total CPU time consumed in seconds
+	# TYPE docker_container_cpu_usage_seconds_total counter
+	docker_container_cpu_usage_seconds_total{name="web"} 1.5
+	# HELP docker_container_exit_code Exit code of the container's last run (meaningful when the container is not running)
+	# TYPE docker_container_exit_code gauge
+	docker_container_exit_code{name="c2"} 137
+	docker_container_exit_code{name="web"} 0
+	# HELP docker_container_info Infos about the container
+	# TYPE docker_container_info gauge
+	docker_container_info{image="sha256:92b11f67642b62bbb98e7e49169c346b30e20cd3c1c034d31087e46924b9312e",image_name="docker.io/library/nginx:1.25",name="web",runtime="containerd"} 1
+	docker_container_info{image="sha256:e6f1816883972d4be47bd48879a08919b96afcd344132622e4d444987919323c",image_name="registry.k8s.io/pause:3.9",name="c2",runtime="containerd"} 1
+	# HELP docker_container_memory_usage_ratio Memory usage as a ratio of the limit (0-1)
+	# TYPE docker_container_memory_usage_ratio gauge
+	docker_container_memory_usage_ratio{name="web"} 0.5
+	# HELP docker_container_pids_current Current number of pids
+	# TYPE docker_container_pids_current gauge
+	docker_container_pids_current{name="web"} 5
+	# HELP docker_container_state State of the container
+	# TYPE docker_container_state gauge
+	docker_container_state{name="c2",state="exited"} 1
+	docker_container_state{name="web",state="running"} 1
+	`
+
+	err := testutil.CollectAndCompare(dc, strings.NewReader(expected),
+		"docker_container_cpu_usage_seconds_total",
+		"docker_container_exit_code",
+		"docker_container_info",
+		"docker_container_memory_usage_ratio",
+		"docker_container_pids_current",
+		"docker_container_state",
+	)
+	assert.NoError(t, err)
+
+	// The fake runtime does not report start times.
+	assert.Equal(t, 0, testutil.CollectAndCount(dc, "docker_container_uptime_seconds"))
+}
+
+func TestCollectRuntimeMetricsWithoutLimits(t *testing.T) {
+	rt := newFakeRuntime()
+	stats := rt.stats["default/c1"]
+	stats.CPUStats.OnlineCPUs = 0
+	stats.MemoryStats.Limit = 0
+	dc := newRuntimeCollector(t, rt)
+
+	// Unknown online CPUs and memory limits are not reported as zero.
+	for _, name := range []string{
+		"docker_container_cpu_online_cpus",
+		"docker_container_memory_limit_bytes",
+		"docker_container_memory_usage_ratio",
+		"docker_container_memory_total_bytes",
+		"docker_container_memory_usage_percentage",
+	} {
+		assert.Equal(t, 0, testutil.CollectAndCount(dc, name), name)
+	}
+	assert.Equal(t, 1, testutil.CollectAndCount(dc, "docker_container_memory_usage_bytes"))
+}
+
+func TestCollectRuntimeStatsError(t *testing.T) {
+	rt := newFakeRuntime()
+	delete(rt.stats, "default/c1")
+	dc := newRuntimeCollector(t, rt)
+
+	const expected = `
+	# HELP docker_exporter_scrape_errors_total Total number of scrape errors
+	# TYPE docker_exporter_scrape_errors_total counter
+	docker_exporter_scrape_errors_total 1
+	`
+
+	err := testutil.CollectAndCompare(dc, strings.NewReader(expected), "docker_exporter_scrape_errors_total")
+	assert.NoError(t, err)
+}
+
+func TestCollectRuntimeListError(t *testing.T) {
+	rt := newFakeRuntime()
+	rt.listErr = errors.New("connection refused")
+	dc := newRuntimeCollector(t, rt)
+
+	assert.Equal(t, 0, testutil.CollectAndCount(dc, "docker_container_info"))
+	assert.Equal(t, 1, testutil.CollectAndCount(dc, "docker_exporter_scrape_errors_total"))
+}
+
+func TestCloseClosesRuntime(t *testing.T) {
+	rt := newFakeRuntime()
+	dc := newRuntimeCollector(t, rt)
+
+	assert.NoError(t, dc.Close())
+	assert.True(t, rt.closed)
+}
+
+func TestValidateRuntime(t *testing.T) {
+	for _, name := range []string{"", collector.RuntimeDocker, collector.RuntimeContainerd} {
+		assert.NoError(t, collector.ValidateRuntime(name))
+	}
+
+	assert.EqualError(t, collector.ValidateRuntime("cri-o"),
+		`unknown runtime "cri-o" (valid: docker, containerd)`)
+}
@@ -0,0 +1,637 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/davidborzek/docker-exporter/internal/collector"
+	"github.com/davidborzek/docker-exporter/internal/docker"
+	"github.com/davidborzek/docker-exporter/internal/handler"
+	"github.com/davidborzek/docker-exporter/internal/server"
+	"go.yaml.in/yaml/v3"
+)
+
+// Config is the content of the exporter's configuration file. It covers
+// every command line flag; flags given on the command line take precedence.
+type Config struct {
+	Server ServerConfig `yaml:"server"`
+	// ConstLabels are added to every exported metric. Changes are only
+	// applied on restart.
+	ConstLabels map[string]string `yaml:"const_labels"`
+	// ExporterMetrics configures the exporter's own metrics.
+	ExporterMetrics ExporterMetricsConfig `yaml:"exporter_metrics"`
+	// LogLevel is one of debug, info, warning, error or fatal.
+	LogLevel   string           `yaml:"log_level"`
+	Docker     DockerConfig     `yaml:"docker"`
+	Runtime    string           `yaml:"runtime"`
+	Containerd ContainerdConfig `yaml:"containerd"`
+	// CollectorConfig configures the collector behind /metrics.
+	CollectorConfig `yaml:",inline"`
+	// ScrapeTimeout bounds a scrape of /metrics. Zero uses the default.
+	ScrapeTimeout time.Duration `yaml:"scrape_timeout"`
+	// Modules are the named settings selectable by /probe requests.
+	Modules map[string]Module `yaml:"modules"`
+}
+
+// ServerConfig configures the exporter's HTTP server. Changes are only
+// applied on restart.
+type ServerConfig struct {
+	Host string `yaml:"host"`
+	Port string `yaml:"port"`
+	// Listen lists the addresses to listen on instead of host and port: TCP
+	// addresses like ":8080", unix domain sockets like
+	// "unix:/run/docker-exporter.sock", or "systemd" for the sockets passed
+	// by systemd socket activation.
+	Listen []string `yaml:"listen"`
+	// UnixSocketMode is the octal file mode of unix domain sockets, e.g.
+	// "0660".
+	UnixSocketMode string `yaml:"unix_socket_mode"`
+	// RoutePrefix serves every endpoint below it, e.g. "/docker-exporter".
+	RoutePrefix string `yaml:"route_prefix"`
+	// MetricsPath is the path of the container metrics below RoutePrefix.
+	MetricsPath string `yaml:"metrics_path"`
+	// EnableLifecycle serves the /-/reload endpoint.
+	EnableLifecycle bool   `yaml:"enable_lifecycle"`
+	AuthToken       string `yaml:"auth_token"`
+	// TokenFile is a YAML file of named bearer tokens, read again when it
+	// changes.
+	TokenFile string `yaml:"token_file"`
+	// BasicAuthUsers maps user names to bcrypt hashes of their passwords for
+	// HTTP basic auth.
+	BasicAuthUsers map[string]string `yaml:"basic_auth_users"`
+	// JWT accepts signed JWTs as bearer tokens when set.
+	JWT *ServerJWTConfig `yaml:"jwt"`
+	// TLS serves HTTPS when set.
+	TLS *ServerTLSConfig `yaml:"tls"`
+	// Access restricts the clients by their IP address.
+	Access ServerAccessConfig `yaml:"access"`
+	// Limits limits the scrapes of /metrics.
+	Limits ServerLimitsConfig `yaml:"limits"`
+	// Timeouts bound the requests and the shutdown of the server.
+	Timeouts ServerTimeoutsConfig `yaml:"timeouts"`
+}
+
+// ServerTimeoutsConfig bounds the requests of the exporter's HTTP server and
+// its shutdown. Durations are given like "30s". Unset fields use the defaults
+// of the server package, while zero disables a timeout.
+type ServerTimeoutsConfig struct {
+	Read                *time.Duration `yaml:"read"`
+	Write               *time.Duration `yaml:"write"`
+	Idle                *time.Duration `yaml:"idle"`
+	ShutdownGracePeriod *time.Duration `yaml:"shutdown_grace_period"`
+}
+
+// ServerAccessConfig restricts the clients of the exporter's HTTP server by
+// their IP address.
+type ServerAccessConfig struct {
+	Allow          []string `yaml:"allow"`
+	Deny           []string `yaml:"deny"`
+	TrustedProxies []string `yaml:"trusted_proxies"`
+}
+
+// ServerLimitsConfig limits the scrapes of /metrics.
+type ServerLimitsConfig struct {
+	RateLimit            float64 `yaml:"rate_limit"`
+	RateBurst            int     `yaml:"rate_burst"`
+	MaxConcurrentScrapes int     `yaml:"max_concurrent_scrapes"`
+}
+
+// ServerJWTConfig configures the validation of JWTs presented as bearer
+// tokens.
+type ServerJWTConfig struct {
+	JWKSFile   string   `yaml:"jwks_file"`
+	JWKSURL    string   `yaml:"jwks_url"`
+	Issuer     string   `yaml:"issuer"`
+	Audiences  []string `yaml:"audiences"`
+	Algorithms []string `yaml:"algorithms"`
+}
+
+// ServerTLSConfig configures TLS for the exporter's HTTP server. The
+// certificate files are read again when they change.
+type ServerTLSConfig struct {
+	CertFile     string   `yaml:"cert_file"`
+	KeyFile      string   `yaml:"key_file"`
+	ClientCAFile string   `yaml:"client_ca_file"`
+	MinVersion   string   `yaml:"min_version"`
+	CipherSuites []string `yaml:"cipher_suites"`
+}
+
+// ExporterMetricsConfig selects the metrics the exporter reports about itself
+// and where they are served. Changes are only applied on restart.
+type ExporterMetricsConfig struct {
+	// NoGoMetrics drops the go_* runtime metrics.
+	NoGoMetrics bool `yaml:"no_go_metrics"`
+	// NoProcessMetrics drops the process_* metrics.
+	NoProcessMetrics bool `yaml:"no_process_metrics"`
+	// Separate serves the docker_exporter_*, go_* and process_* metrics on
+	// /metrics/exporter instead of /metrics.
+	Separate bool `yaml:"separate"`
+}
+
+// DockerConfig configures the connection to the Docker daemon.
+type DockerConfig struct {
+	Host       string     `yaml:"host"`
+	Context    string     `yaml:"context"`
+	APIVersion string     `yaml:"api_version"`
+	TLS        *TLSConfig `yaml:"tls"`
+	SSH        SSHConfig  `yaml:"ssh"`
+}
+
+// ContainerdConfig configures the containerd runtime.
+type ContainerdConfig struct {
+	Address    string   `yaml:"address"`
+	Namespaces []string `yaml:"namespaces"`
+}
+
+// CollectorConfig configures what a collector collects.
+type CollectorConfig struct {
+	IgnoreLabel         string          `yaml:"ignore_label"`
+	ContainerLabels     []string        `yaml:"container_labels"`
+	IdentityLabels      []string        `yaml:"identity_labels"`
+	Collectors          map[string]bool `yaml:"collectors"`
+	Engine              string          `yaml:"engine"`
+	NoDeprecatedMetrics bool            `yaml:"no_deprecated_metrics"`
+	Filters             FiltersConfig   `yaml:"filters"`
+	RelabelConfigs      []RelabelConfig `yaml:"relabel_configs"`
+}
+
+// Module configures how a probed Docker daemon is reached and collected.
+type Module struct {
+	APIVersion string     `yaml:"api_version"`
+	TLS        *TLSConfig `yaml:"tls"`
+	SSH        SSHConfig  `yaml:"ssh"`
+	// Timeout bounds a probe. Zero uses the default; a shorter scrape
+	// timeout of Prometheus takes precedence.
+	Timeout time.Duration `yaml:"timeout"`
+	// AllowedSchemes are the schemes of the targets probed with the module.
+	// Empty allows tcp only.
+	AllowedSchemes []string `yaml:"allowed_schemes"`
+	// AllowedHosts are glob patterns of the host names, or the socket paths,
+	// of the targets probed with the module. Empty allows any host.
+	AllowedHosts    []string `yaml:"allowed_hosts"`
+	CollectorConfig `yaml:",inline"`
+}
+
+// RelabelConfig is a relabeling rule in the format of Prometheus'
+// relabel_configs. Unset fields take the Prometheus defaults.
+type RelabelConfig struct {
+	SourceLabels []string `yaml:"source_labels"`
+	Separator    *string  `yaml:"separator"`
+	Regex        *string  `yaml:"regex"`
+	Modulus      uint64   `yaml:"modulus"`
+	TargetLabel  string   `yaml:"target_label"`
+	Replacement  *string  `yaml:"replacement"`
+	Action       string   `yaml:"action"`
+}
+
+// FiltersConfig selects the containers collected by a module.
+type FiltersConfig struct {
+	Names                  []string `yaml:"names"`
+	ExcludeNames           []string `yaml:"exclude_names"`
+	Images                 []string `yaml:"images"`
+	ExcludeImages          []string `yaml:"exclude_images"`
+	ComposeProjects        []string `yaml:"compose_projects"`
+	ExcludeComposeProjects []string `yaml:"exclude_compose_projects"`
+	LabelSelector          string   `yaml:"label_selector"`
+}
+
+// TLSConfig holds the TLS material used to reach a Docker daemon.
+type TLSConfig struct {
+	CAFile             string `yaml:"ca_file"`
+	CertFile           string `yaml:"cert_file"`
+	KeyFile            string `yaml:"key_file"`
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify"`
+}
+
+// SSHConfig holds the SSH settings used for ssh:// Docker hosts.
+type SSHConfig struct {
+	KeyFile        string `yaml:"key_file"`
+	KnownHostsFile string `yaml:"known_hosts_file"`
+}
+
+// Load reads and validates the configuration file at path. Unknown keys are
+// rejected so typos do not silently fall back to defaults.
+func Load(path string) (*Config, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	var cfg Config
+	decoder := yaml.NewDecoder(bytes.NewReader(raw))
+	decoder.KnownFields(true)
+
+	if err := decoder.Decode(&cfg); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed to parse config file %q: %w", path, err)
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config file %q: %w", path, err)
+	}
+
+	return &cfg, nil
+}
+
+// logLevels are the valid values of log_level.
+var logLevels = []string{"debug", "info", "warning", "error", "fatal"}
+
+// Validate checks the configuration for values that cannot be applied. Errors
+// name the offending setting.
+func (c *Config) Validate() error {
+	if c.LogLevel != "" && !slices.Contains(logLevels, strings.ToLower(c.LogLevel)) {
+		return fmt.Errorf("log_level: unknown level %q (valid: %s)",
+			c.LogLevel, strings.Join(logLevels, ", "))
+	}
+
+	if c.Server.TokenFile != "" {
+		if _, err := handler.LoadTokenFile(c.Server.TokenFile); err != nil {
+			return fmt.Errorf("server.token_file: %w", err)
+		}
+	}
+
+	if err := handler.ValidateBasicAuthUsers(c.Server.BasicAuthUsers); err != nil {
+		return fmt.Errorf("server.basic_auth_users: %w", err)
+	}
+
+	if c.Server.JWT != nil {
+		if _, err := handler.NewJWTValidator(c.Server.JWT.Handler()); err != nil {
+			return fmt.Errorf("server.jwt: %w", err)
+		}
+	}
+
+	if _, err := handler.NewAccessList(c.Server.Access.Handler()); err != nil {
+		return fmt.Errorf("server.access: %w", err)
+	}
+
+	if err := c.Server.Limits.validate(); err != nil {
+		return fmt.Errorf("server.limits: %w", err)
+	}
+
+	for _, addr := range c.Server.Listen {
+		if err := server.ValidateListenAddress(addr); err != nil {
+			return fmt.Errorf("server.listen: %w", err)
+		}
+	}
+
+	if _, err := server.ParseUnixSocketMode(c.Server.UnixSocketMode); err != nil {
+		return fmt.Errorf("server.unix_socket_mode: %w", err)
+	}
+
+	if err := handler.ValidateRoutePrefix(c.Server.RoutePrefix); err != nil {
+		return fmt.Errorf("server.route_prefix: %w", err)
+	}
+
+	if c.Server.MetricsPath != "" {
+		if err := handler.ValidateMetricsPath(c.Server.MetricsPath); err != nil {
+			return fmt.Errorf("server.metrics_path: %w", err)
+		}
+	}
+
+	if err := c.Server.Timeouts.validate(); err != nil {
+		return fmt.Errorf("server.timeouts: %w", err)
+	}
+
+	if c.Server.TLS != nil {
+		if err := c.Server.TLS.Server().Validate(); err != nil {
+			return fmt.Errorf("server.tls: %w", err)
+		}
+	}
+
+	if err := collector.ValidateConstLabels(c.ConstLabels); err != nil {
+		return fmt.Errorf("const_labels: %w", err)
+	}
+
+	if err := collector.ValidateRuntime(c.Runtime); err != nil {
+		return fmt.Errorf("runtime: %w", err)
+	}
+
+	if err := c.Docker.validate(); err != nil {
+		return fmt.Errorf("docker: %w", err)
+	}
+
+	if err := c.CollectorConfig.validate(); err != nil {
+		return err
+	}
+
+	if c.ScrapeTimeout < 0 {
+		return errors.New("scrape_timeout must not be negative")
+	}
+
+	for name, m := range c.Modules {
+		if err := m.validate(); err != nil {
+			return fmt.Errorf("module %q: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+func (l ServerLimitsConfig) validate() error {
+	if l.RateLimit < 0 {
+		return errors.New("rate_limit must not be negative")
+	}
+	if l.RateBurst < 0 {
+		return errors.New("rate_burst must not be negative")
+	}
+	if l.MaxConcurrentScrapes < 0 {
+		return errors.New("max_concurrent_scrapes must not be negative")
+	}
+
+	return nil
+}
+
+func (t ServerTimeoutsConfig) validate() error {
+	if t.Read != nil && *t.Read < 0 {
+		return errors.New("read must not be negative")
+	}
+	if t.Write != nil && *t.Write < 0 {
+		return errors.New("write must not be negative")
+	}
+	if t.Idle != nil && *t.Idle < 0 {
+		return errors.New("idle must not be negative")
+	}
+	if t.ShutdownGracePeriod != nil && *t.ShutdownGracePeriod < 0 {
+		return errors.New("shutdown_grace_period must not be negative")
+	}
+
+	return nil
+}
+
+func (d DockerConfig) validate() error {
+	if d.Host != "" && d.Context != "" {
+		return errors.New("host and context cannot be set together")
+	}
+
+	if err := d.TLS.validate(); err != nil {
+		return err
+	}
+
+	if d.Context != "" {
+		return nil
+	}
+
+	return docker.Config{
+		Host:       d.Host,
+		APIVersion: d.APIVersion,
+		TLS:        d.TLS.docker(),
+		SSH:        d.SSH.docker(),
+	}.Validate()
+}
+
+func (m Module) validate() error {
+	if err := m.CollectorConfig.validate(); err != nil {
+		return err
+	}
+
+	if m.Timeout < 0 {
+		return errors.New("timeout must not be negative")
+	}
+
+	if err := collector.ValidateProbeTargets(m.AllowedSchemes, m.AllowedHosts); err != nil {
+		return err
+	}
+
+	if err := m.TLS.validate(); err != nil {
+		return err
+	}
+
+	dockerConfig := docker.Config{APIVersion: m.APIVersion, TLS: m.TLS.docker()}
+
+	return dockerConfig.Validate()
+}
+
+func (c CollectorConfig) validate() error {
+	if err := collector.Collectors(c.Collectors).Validate(); err != nil {
+		return err
+	}
+
+	if err := collector.ValidateEngine(c.Engine); err != nil {
+		return err
+	}
+
+	if err := collector.ValidateIdentityLabels(c.IdentityLabels); err != nil {
+		return err
+	}
+
+	_, err := c.Options()
+
+	return err
+}
+
+func (t *TLSConfig) validate() error {
+	if t != nil && (t.CertFile == "") != (t.KeyFile == "") {
+		return errors.New("tls.cert_file and tls.key_file must be set together")
+	}
+
+	return nil
+}
+
+// Options converts the settings for use by a collector. It compiles the
+// filters and relabeling rules.
+func (c CollectorConfig) Options() (collector.Options, error) {
+	filter, err := collector.NewFilter(c.Filters.collectorConfig())
+	if err != nil {
+		return collector.Options{}, err
+	}
+
+	relabeler, err := newRelabeler(c.RelabelConfigs)
+	if err != nil {
+		return collector.Options{}, err
+	}
+
+	ignoreLabel := c.IgnoreLabel
+	if ignoreLabel == "" {
+		ignoreLabel = collector.DefaultIgnoreLabel
+	}
+
+	return collector.Options{
+		IgnoreLabel:         ignoreLabel,
+		ContainerLabels:     c.ContainerLabels,
+		IdentityLabels:      c.IdentityLabels,
+		Collectors:          c.Collectors,
+		Engine:              c.Engine,
+		NoDeprecatedMetrics: c.NoDeprecatedMetrics,
+		Relabeler:           relabeler,
+		Filter:              filter,
+	}, nil
+}
+
+// RestartChanges returns the keys of the settings only applied on restart
+// whose values differ between c and running, the configuration the exporter
+// was started with.
+func (c *Config) RestartChanges(running *Config) []string {
+	var changed []string
+	if !reflect.DeepEqual(c.Server, running.Server) {
+		changed = append(changed, "server")
+	}
+	if !reflect.DeepEqual(c.ConstLabels, running.ConstLabels) {
+		changed = append(changed, "const_labels")
+	}
+	if c.ExporterMetrics != running.ExporterMetrics {
+		changed = append(changed, "exporter_metrics")
+	}
+
+	return changed
+}
+
+// ProbeModules converts the configured modules for use by a collector.Prober.
+func (c *Config) ProbeModules() (map[string]collector.Module, error) {
+	modules := make(map[string]collector.Module, len(c.Modules))
+
+	for name, m := range c.Modules {
+		opts, err := m.Options()
+		if err != nil {
+			return nil, fmt.Errorf("module %q: %w", name, err)
+		}
+
+		opts.Timeout = m.Timeout
+
+		modules[name] = collector.Module{
+			APIVersion:     m.APIVersion,
+			TLS:            m.TLS.docker(),
+			SSH:            m.SSH.docker(),
+			Options:        opts,
+			AllowedSchemes: m.AllowedSchemes,
+			AllowedHosts:   m.AllowedHosts,
+		}
+	}
+
+	return modules, nil
+}
+
+func (f FiltersConfig) collectorConfig() collector.FilterConfig {
+	return collector.FilterConfig{
+		Names:                  f.Names,
+		ExcludeNames:           f.ExcludeNames,
+		Images:                 f.Images,
+		ExcludeImages:          f.ExcludeImages,
+		ComposeProjects:        f.ComposeProjects,
+		ExcludeComposeProjects: f.ExcludeComposeProjects,
+		LabelSelector:          f.LabelSelector,
+	}
+}
+
+func newRelabeler(configs []RelabelConfig) (*collector.Relabeler, error) {
+	converted := make([]collector.RelabelConfig, 0, len(configs))
+
+	for _, c := range configs {
+		rc := collector.DefaultRelabelConfig
+		rc.SourceLabels = c.SourceLabels
+		rc.Modulus = c.Modulus
+		rc.TargetLabel = c.TargetLabel
+		if c.Action != "" {
+			rc.Action = c.Action
+		}
+		if c.Separator != nil {
+			rc.Separator = *c.Separator
+		}
+		if c.Regex != nil {
+			rc.Regex = *c.Regex
+		}
+		if c.Replacement != nil {
+			rc.Replacement = *c.Replacement
+		}
+
+		converted = append(converted, rc)
+	}
+
+	return collector.NewRelabeler(converted)
+}
+
+// Handler returns the settings of the handler package.
+func (a ServerAccessConfig) Handler() handler.AccessConfig {
+	return handler.AccessConfig{
+		Allow:          a.Allow,
+		Deny:           a.Deny,
+		TrustedProxies: a.TrustedProxies,
+	}
+}
+
+// Handler returns the settings of the handler package.
+func (l ServerLimitsConfig) Handler() handler.LimitConfig {
+	return handler.LimitConfig{
+		RateLimit:            l.RateLimit,
+		RateBurst:            l.RateBurst,
+		MaxConcurrentScrapes: l.MaxConcurrentScrapes,
+	}
+}
+
+// Handler returns the settings of the handler package.
+func (j *ServerJWTConfig) Handler() handler.JWTConfig {
+	return handler.JWTConfig{
+		JWKSFile:   j.JWKSFile,
+		JWKSURL:    j.JWKSURL,
+		Issuer:     j.Issuer,
+		Audiences:  j.Audiences,
+		Algorithms: j.Algorithms,
+	}
+}
+
+// Server returns the settings of the server package.
+func (t *ServerTLSConfig) Server() server.TLSConfig {
+	return server.TLSConfig{
+		CertFile:     t.CertFile,
+		KeyFile:      t.KeyFile,
+		ClientCAFile: t.ClientCAFile,
+		MinVersion:   t.MinVersion,
+		CipherSuites: t.CipherSuites,
+	}
+}
+
+// ListenAddresses returns the addresses of Listen, or the address of Host and
+// Port when none are listed.
+func (s ServerConfig) ListenAddresses() []string {
+	if len(s.Listen) > 0 {
+		return s.Listen
+	}
+
+	return []string{net.JoinHostPort(s.Host, s.Port)}
+}
+
+// Server returns the settings of the server package, using its defaults for
+// unset fields.
+func (t ServerTimeoutsConfig) Server() server.Timeouts {
+	return server.Timeouts{
+		Read:                durationOr(t.Read, server.DefaultReadTimeout),
+		Write:               durationOr(t.Write, server.DefaultWriteTimeout),
+		Idle:                durationOr(t.Idle, server.DefaultIdleTimeout),
+		ShutdownGracePeriod: durationOr(t.ShutdownGracePeriod, server.DefaultShutdownGracePeriod),
+	}
+}
+
+func durationOr(d *time.Duration, fallback time.Duration) time.Duration {
+	if d == nil {
+		return fallback
+	}
+
+	return *d
+}
+
+// docker converts the TLS section, returning nil when TLS is not configured.
+func (t *TLSConfig) docker() *docker.TLSConfig {
+	if t == nil {
+		return nil
+	}
+
+	return &docker.TLSConfig{
+		CAFile:             t.CAFile,
+		CertFile:           t.CertFile,
+		KeyFile:            t.KeyFile,
+		InsecureSkipVerify: t.InsecureSkipVerify,
+	}
+}
+
+func (s SSHConfig) docker() docker.SSHConfig {
+	return docker.SSHConfig{
+		KeyFile:        s.KeyFile,
+		KnownHostsFile: s.KnownHostsFile,
+	}
+}
@@ -0,0 +1,505 @@
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/davidborzek/docker-exporter/internal/collector"
+	"github.com/davidborzek/docker-exporter/internal/config"
+	"github.com/davidborzek/docker-exporter/internal/server"
+	"github.com/docker/docker/api/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "config.yml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+
+	return path
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func TestLoadModules(t *testing.T) {
+	path := writeConfig(t, `
+modules:
+  tls:
+    api_version: "1.43"
+    tls:
+      insecure_skip_verify: true
+    container_labels:
+      - com.docker.compose.project
+    identity_labels: [compose_service]
+    collectors:
+      network: false
+  plain: {}
+  podman:
+    engine: podman
+    no_deprecated_metrics: true
+  tenant-a:
+    filters:
+      compose_projects: [shop]
+      label_selector: "env=prod,tier!=batch"
+`)
+
+	cfg, err := config.Load(path)
+	require.NoError(t, err)
+
+	modules, err := cfg.ProbeModules()
+	require.NoError(t, err)
+	require.Len(t, modules, 4)
+
+	tls := modules["tls"]
+	require.NotNil(t, tls.TLS)
+	assert.True(t, tls.TLS.InsecureSkipVerify)
+	assert.Equal(t, "1.43", tls.APIVersion)
+	assert.Equal(t, []string{"com.docker.compose.project"}, tls.Options.ContainerLabels)
+	assert.Equal(t, []string{collector.IdentityComposeService}, tls.Options.IdentityLabels)
+	assert.False(t, tls.Options.Collectors.Enabled(collector.CollectorNetwork))
+	assert.True(t, tls.Options.Collectors.Enabled(collector.CollectorCPU))
+
+	plain := modules["plain"]
+	assert.Nil(t, plain.TLS)
+	assert.Equal(t, collector.DefaultIgnoreLabel, plain.Options.IgnoreLabel)
+
+	assert.Equal(t, collector.EnginePodman, modules["podman"].Options.Engine)
+	assert.True(t, modules["podman"].Options.NoDeprecatedMetrics)
+	assert.False(t, plain.Options.NoDeprecatedMetrics)
+
+	assert.Nil(t, plain.Options.Filter)
+	filter := modules["tenant-a"].Options.Filter
+	require.NotNil(t, filter)
+	assert.True(t, filter.Match(types.Container{Labels: map[string]string{
+		"com.docker.compose.project": "shop", "env": "prod",
+	}}))
+	assert.False(t, filter.Match(types.Container{Labels: map[string]string{
+		"com.docker.compose.project": "shop", "env": "prod", "tier": "batch",
+	}}))
+}
+
+func TestLoadModuleTargetsAndTimeout(t *testing.T) {
+	cfg, err := config.Load(writeConfig(t, `
+scrape_timeout: 20s
+modules:
+  internal:
+    timeout: 5s
+    allowed_schemes: [tcp, ssh]
+    allowed_hosts: ["*.docker.internal"]
+`))
+	require.NoError(t, err)
+	assert.Equal(t, 20*time.Second, cfg.ScrapeTimeout)
+
+	modules, err := cfg.ProbeModules()
+	require.NoError(t, err)
+
+	internal := modules["internal"]
+	assert.Equal(t, 5*time.Second, internal.Options.Timeout)
+	assert.Equal(t, []string{"tcp", "ssh"}, internal.AllowedSchemes)
+	assert.Equal(t, []string{"*.docker.internal"}, internal.AllowedHosts)
+}
+
+func TestLoadRejectsInvalidModuleTargets(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		wantErr string
+	}{
+		{
+			name:    "unknown scheme",
+			config:  "modules:\n  default:\n    allowed_schemes: [http]\n",
+			wantErr: `module "default": unknown target scheme "http"`,
+		},
+		{
+			name:    "invalid host pattern",
+			config:  "modules:\n  default:\n    allowed_hosts: [\"[a-\"]\n",
+			wantErr: `module "default": invalid host pattern "[a-"`,
+		},
+		{
+			name:    "negative module timeout",
+			config:  "modules:\n  default:\n    timeout: -1s\n",
+			wantErr: `module "default": timeout must not be negative`,
+		},
+		{
+			name:    "negative scrape timeout",
+			config:  "scrape_timeout: -1s\n",
+			wantErr: "scrape_timeout must not be negative",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := config.Load(writeConfig(t, tt.config))
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestLoadEmptyFile(t *testing.T) {
+	cfg, err := config.Load(writeConfig(t, ""))
+	require.NoError(t, err)
+	assert.Empty(t, cfg.Modules)
+}
+
+func TestLoadRejectsUnknownFields(t *testing.T) {
+	_, err := config.Load(writeConfig(t, `
+modules:
+  default:
+    tsl: {}
+`))
+	assert.ErrorContains(t, err, "field tsl not found")
+}
+
+func TestLoadRejectsUnknownCollector(t *testing.T) {
+	_, err := config.Load(writeConfig(t, `
+modules:
+  default:
+    collectors:
+      gpu: true
+`))
+	assert.ErrorContains(t, err, `module "default": unknown collector "gpu"`)
+}
+
+func TestLoadRejectsUnknownEngine(t *testing.T) {
+	_, err := config.Load(writeConfig(t, `
+modules:
+  default:
+    engine: rkt
+`))
+	assert.ErrorContains(t, err, `module "default": unknown engine "rkt"`)
+}
+
+func TestLoadRejectsUnknownIdentityLabel(t *testing.T) {
+	_, err := config.Load(writeConfig(t, `
+modules:
+  default:
+    identity_labels: [container_id, host]
+`))
+	assert.ErrorContains(t, err, `module "default": unknown identity label "host"`)
+}
+
+func TestLoadRelabelConfigs(t *testing.T) {
+	cfg, err := config.Load(writeConfig(t, `
+relabel_configs:
+  - source_labels: [__meta_docker_compose_service]
+    target_label: service
+  - action: labeldrop
+    regex: name
+  - source_labels: [__meta_docker_container_label_tier]
+    regex: batch
+    action: drop
+modules:
+  default:
+    relabel_configs:
+      - action: labelmap
+        regex: __meta_docker_container_label_(.+)
+        replacement: label_$1
+`))
+	require.NoError(t, err)
+
+	opts, err := cfg.Options()
+	require.NoError(t, err)
+	assert.NotNil(t, opts.Relabeler)
+
+	modules, err := cfg.ProbeModules()
+	require.NoError(t, err)
+	assert.NotNil(t, modules["default"].Options.Relabeler)
+}
+
+func TestLoadRejectsInvalidRelabelConfig(t *testing.T) {
+	_, err := config.Load(writeConfig(t, `
+relabel_configs:
+  - action: hashmod
+    source_labels: [__meta_docker_container_id]
+    target_label: __tmp_shard
+`))
+	assert.ErrorContains(t, err, `invalid relabel config 0: modulus is required for action "hashmod"`)
+
+	_, err = config.Load(writeConfig(t, `
+modules:
+  default:
+    relabel_configs:
+      - action: keep
+        source_labels: [__meta_docker_container_name]
+        regex: "web("
+`))
+	assert.ErrorContains(t, err, `module "default": invalid relabel config 0: invalid regex "web("`)
+}
+
+func TestLoadExporterSettings(t *testing.T) {
+	cfg, err := config.Load(writeConfig(t, `
+server:
+  host: 127.0.0.1
+  port: 9417
+  auth_token: secret
+  enable_lifecycle: true
+  timeouts:
+    write: 2m
+    shutdown_grace_period: 25s
+const_labels:
+  datacenter: fra1
+exporter_metrics:
+  no_go_metrics: true
+  separate: true
+log_level: debug
+docker:
+  host: tcp://docker:2376
+  api_version: "1.43"
+  tls:
+    insecure_skip_verify: true
+runtime: containerd
+containerd:
+  address: /run/k3s/containerd/containerd.sock
+  namespaces: [k8s.io]
+engine: docker
+ignore_label: acme.ignore
+identity_labels: [compose_service]
+collectors:
+  blkio: false
+no_deprecated_metrics: true
+filters:
+  exclude_names: ["debug-.*"]
+`))
+	require.NoError(t, err)
+
+	assert.Equal(t, config.ServerConfig{
+		Host:            "127.0.0.1",
+		Port:            "9417",
+		AuthToken:       "secret",
+		EnableLifecycle: true,
+		Timeouts:        config.ServerTimeoutsConfig{Write: ptr(2 * time.Minute), ShutdownGracePeriod: ptr(25 * time.Second)},
+	}, cfg.Server)
+	assert.Equal(t, []string{"127.0.0.1:9417"}, cfg.Server.ListenAddresses())
+
+	cfg.Server.Listen = []string{"unix:/run/docker-exporter.sock", "systemd"}
+	assert.Equal(t, cfg.Server.Listen, cfg.Server.ListenAddresses())
+	assert.Equal(t, map[string]string{"datacenter": "fra1"}, cfg.ConstLabels)
+	assert.Equal(t, config.ExporterMetricsConfig{NoGoMetrics: true, Separate: true}, cfg.ExporterMetrics)
+	assert.Equal(t, "debug", cfg.LogLevel)
+	assert.Equal(t, "tcp://docker:2376", cfg.Docker.Host)
+	assert.True(t, cfg.Docker.TLS.InsecureSkipVerify)
+	assert.Equal(t, collector.RuntimeContainerd, cfg.Runtime)
+	assert.Equal(t, []string{"k8s.io"}, cfg.Containerd.Namespaces)
+
+	opts, err := cfg.Options()
+	require.NoError(t, err)
+	assert.Equal(t, "acme.ignore", opts.IgnoreLabel)
+	assert.Equal(t, collector.EngineDocker, opts.Engine)
+	assert.Equal(t, []string{collector.IdentityComposeService}, opts.IdentityLabels)
+	assert.False(t, opts.Collectors.Enabled(collector.CollectorBlockIO))
+	assert.True(t, opts.NoDeprecatedMetrics)
+	assert.False(t, opts.Filter.Match(types.Container{Names: []string{"/debug-1"}}))
+}
+
+func TestLoadServerTimeouts(t *testing.T) {
+	cfg, err := config.Load(writeConfig(t, `
+server:
+  timeouts:
+    read: 0s
+    write: 2m
+`))
+	require.NoError(t, err)
+
+	// A zero timeout is disabled, unset ones use the defaults.
+	assert.Equal(t, server.Timeouts{
+		Read:                0,
+		Write:               2 * time.Minute,
+		Idle:                server.DefaultIdleTimeout,
+		ShutdownGracePeriod: server.DefaultShutdownGracePeriod,
+	}, cfg.Server.Timeouts.Server())
+}
+
+func TestRestartChanges(t *testing.T) {
+	running, err := config.Load(writeConfig(t, `
+server:
+  auth_token: secret
+const_labels:
+  datacenter: fra1
+log_level: info
+`))
+	require.NoError(t, err)
+
+	// Settings applied on reload are not reported.
+	cfg, err := config.Load(writeConfig(t, `
+server:
+  auth_token: secret
+const_labels:
+  datacenter: fra1
+log_level: debug
+engine: podman
+`))
+	require.NoError(t, err)
+	assert.Empty(t, cfg.RestartChanges(running))
+
+	cfg, err = config.Load(writeConfig(t, `
+server:
+  auth_token: rotated
+const_labels:
+  datacenter: fra2
+exporter_metrics:
+  separate: true
+`))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"server", "const_labels", "exporter_metrics"}, cfg.RestartChanges(running))
+}
+
+func TestLoadRejectsInvalidExporterSettings(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		wantErr string
+	}{
+		{
+			name:    "unknown key",
+			config:  "server:\n  address: :8080\n",
+			wantErr: "line 2: field address not found",
+		},
+		{
+			name:    "log level",
+			config:  "log_level: verbose\n",
+			wantErr: `log_level: unknown level "verbose" (valid: debug, info, warning, error, fatal)`,
+		},
+		{
+			name:    "token file",
+			config:  "server:\n  token_file: /nonexistent/tokens.yaml\n",
+			wantErr: "server.token_file: stat /nonexistent/tokens.yaml: no such file or directory",
+		},
+		{
+			name:    "basic auth user",
+			config:  "server:\n  basic_auth_users:\n    prometheus: secret\n",
+			wantErr: `server.basic_auth_users: user "prometheus": invalid bcrypt hash`,
+		},
+		{
+			name:    "server jwt",
+			config:  "server:\n  jwt:\n    issuer: https://auth.example.com\n",
+			wantErr: "server.jwt: JWKS file or JWKS URL is required",
+		},
+		{
+			name:    "server access",
+			config:  "server:\n  access:\n    allow: [10.0.0.0/33]\n",
+			wantErr: `server.access: allow: invalid network "10.0.0.0/33"`,
+		},
+		{
+			name:    "server limits",
+			config:  "server:\n  limits:\n    rate_limit: -1\n",
+			wantErr: "server.limits: rate_limit must not be negative",
+		},
+		{
+			name:    "server listen",
+			config:  "server:\n  listen: [\"unix:\"]\n",
+			wantErr: `server.listen: listen address "unix:": unix socket path is required`,
+		},
+		{
+			name:    "unix socket mode",
+			config:  "server:\n  unix_socket_mode: \"888\"\n",
+			wantErr: `server.unix_socket_mode: invalid unix socket mode "888": expected octal permissions like 0660`,
+		},
+		{
+			name:    "metrics path",
+			config:  "server:\n  metrics_path: /health\n",
+			wantErr: `server.metrics_path: metrics path "/health" is used by another endpoint`,
+		},
+		{
+			name:    "server timeouts",
+			config:  "server:\n  timeouts:\n    write: -1s\n",
+			wantErr: "server.timeouts: write must not be negative",
+		},
+		{
+			name:    "server timeouts duration",
+			config:  "server:\n  timeouts:\n    idle: forever\n",
+			wantErr: "cannot unmarshal !!str `forever` into time.Duration",
+		},
+		{
+			name:    "server tls",
+			config:  "server:\n  tls:\n    cert_file: /certs/tls.crt\n",
+			wantErr: "server.tls: cert file and key file are required",
+		},
+		{
+			name:    "const label",
+			config:  "const_labels:\n  network: dmz\n",
+			wantErr: `const_labels: const label "network" clashes with a label of the exported metrics`,
+		},
+		{
+			name:    "runtime",
+			config:  "runtime: cri-o\n",
+			wantErr: `runtime: unknown runtime "cri-o"`,
+		},
+		{
+			name:    "docker host and context",
+			config:  "docker:\n  host: tcp://docker:2376\n  context: remote\n",
+			wantErr: "docker: host and context cannot be set together",
+		},
+		{
+			name:    "docker certificate without key",
+			config:  "docker:\n  host: tcp://docker:2376\n  tls:\n    cert_file: /certs/cert.pem\n",
+			wantErr: "docker: tls.cert_file and tls.key_file must be set together",
+		},
+		{
+			name:    "docker API version",
+			config:  "docker:\n  api_version: latest\n",
+			wantErr: `docker: invalid docker API version "latest"`,
+		},
+		{
+			name:    "top-level filter",
+			config:  "filters:\n  label_selector: \"=prod\"\n",
+			wantErr: `invalid label selector "=prod"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := config.Load(writeConfig(t, tt.config))
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestLoadRejectsInvalidFilter(t *testing.T) {
+	_, err := config.Load(writeConfig(t, `
+modules:
+  default:
+    filters:
+      names: ["web("]
+`))
+	assert.ErrorContains(t, err, `module "default": invalid container name pattern "web("`)
+}
+
+func TestLoadRejectsCertWithoutKey(t *testing.T) {
+	_, err := config.Load(writeConfig(t, `
+modules:
+  default:
+    tls:
+      cert_file: /certs/cert.pem
+`))
+	assert.ErrorContains(t, err, "tls.cert_file and tls.key_file must be set together")
+}
+
+func TestLoadRejectsMissingCAFile(t *testing.T) {
+	_, err := config.Load(writeConfig(t, `
+modules:
+  default:
+    tls:
+      ca_file: /does/not/exist.pem
+`))
+	assert.ErrorContains(t, err, `module "default": failed to read docker TLS CA file`)
+}
+
+func TestLoadRejectsInvalidAPIVersion(t *testing.T) {
+	_, err := config.Load(writeConfig(t, `
+modules:
+  default:
+    api_version: latest
+`))
+	assert.ErrorContains(t, err, `invalid docker API version "latest"`)
+}
+
+func TestLoadMissingFile(t *testing.T) {
+	_, err := config.Load(filepath.Join(t.TempDir(), "missing.yml"))
+	assert.ErrorContains(t, err, "failed to read config file")
+}
@@ -0,0 +1,132 @@
+package docker
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/docker/docker/client"
+)
+
+// DefaultContext is the Docker CLI context that uses DOCKER_HOST or the
+// platform default instead of a stored endpoint.
+const DefaultContext = "default"
+
+// contextMeta is the subset of a context's meta.json used by the exporter.
+type contextMeta struct {
+	Name      string `json:"Name"`
+	Endpoints map[string]struct {
+		Host          string `json:"Host"`
+		SkipTLSVerify bool   `json:"SkipTLSVerify"`
+	} `json:"Endpoints"`
+}
+
+// ConfigDir returns the Docker CLI configuration directory: DOCKER_CONFIG
+// or ~/.docker.
+func ConfigDir() string {
+	if dir := os.Getenv("DOCKER_CONFIG"); dir != "" {
+		return dir
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ".docker"
+	}
+
+	return filepath.Join(home, ".docker")
+}
+
+// CurrentContext returns the context selected through the environment or the
+// CLI config in configDir, following the Docker CLI's precedence: DOCKER_HOST
+// forces the default context, then DOCKER_CONTEXT, then the currentContext
+// of config.json.
+func CurrentContext(configDir string) (string, error) {
+	if os.Getenv(client.EnvOverrideHost) != "" {
+		return DefaultContext, nil
+	}
+
+	if name := os.Getenv("DOCKER_CONTEXT"); name != "" {
+		return name, nil
+	}
+
+	raw, err := os.ReadFile(filepath.Join(configDir, "config.json"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return DefaultContext, nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read docker CLI config: %w", err)
+	}
+
+	var cfg struct {
+		CurrentContext string `json:"currentContext"`
+	}
+	if err := json.Unmarshal(raw, &cfg); err != nil {
+		return "", fmt.Errorf("failed to parse docker CLI config: %w", err)
+	}
+
+	if cfg.CurrentContext == "" {
+		return DefaultContext, nil
+	}
+
+	return cfg.CurrentContext, nil
+}
+
+// LoadContext reads the docker endpoint and TLS material of the named context
+// from the CLI's context store in configDir.
+func LoadContext(configDir, name string) (Config, error) {
+	id := contextID(name)
+
+	raw, err := os.ReadFile(filepath.Join(configDir, "contexts", "meta", id, "meta.json"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return Config{}, fmt.Errorf("docker context %q does not exist", name)
+	}
+	if err != nil {
+		return Config{}, fmt.Errorf("failed to read docker context %q: %w", name, err)
+	}
+
+	var meta contextMeta
+	if err := json.Unmarshal(raw, &meta); err != nil {
+		return Config{}, fmt.Errorf("failed to parse docker context %q: %w", name, err)
+	}
+
+	endpoint, ok := meta.Endpoints["docker"]
+	if !ok || endpoint.Host == "" {
+		return Config{}, fmt.Errorf("docker context %q has no docker endpoint", name)
+	}
+
+	cfg := Config{Host: endpoint.Host}
+
+	tlsDir := filepath.Join(configDir, "contexts", "tls", id, "docker")
+	tlsConfig := &TLSConfig{
+		CAFile:             existingFile(filepath.Join(tlsDir, "ca.pem")),
+		CertFile:           existingFile(filepath.Join(tlsDir, "cert.pem")),
+		KeyFile:            existingFile(filepath.Join(tlsDir, "key.pem")),
+		InsecureSkipVerify: endpoint.SkipTLSVerify,
+	}
+
+	if *tlsConfig != (TLSConfig{}) {
+		cfg.TLS = tlsConfig
+	}
+
+	return cfg, nil
+}
+
+// contextID returns the directory name the Docker CLI stores a context under.
+func contextID(name string) string {
+	sum := sha256.Sum256([]byte(name))
+	return hex.EncodeToString(sum[:])
+}
+
+// existingFile returns path if it exists, or an empty string otherwise.
+func existingFile(path string) string {
+	if _, err := os.Stat(path); err != nil {
+		return ""
+	}
+
+	return path
+}
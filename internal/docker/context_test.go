@@ -0,0 +1,191 @@
+package docker_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/davidborzek/docker-exporter/internal/docker"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeContext stores a context the way `docker context create` does.
+func writeContext(t *testing.T, configDir, name, meta string, tlsFiles map[string]string) {
+	t.Helper()
+
+	sum := sha256.Sum256([]byte(name))
+	id := hex.EncodeToString(sum[:])
+
+	metaDir := filepath.Join(configDir, "contexts", "meta", id)
+	require.NoError(t, os.MkdirAll(metaDir, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(metaDir, "meta.json"), []byte(meta), 0o600))
+
+	if len(tlsFiles) == 0 {
+		return
+	}
+
+	tlsDir := filepath.Join(configDir, "contexts", "tls", id, "docker")
+	require.NoError(t, os.MkdirAll(tlsDir, 0o755))
+	for file, src := range tlsFiles {
+		content, err := os.ReadFile(src)
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(filepath.Join(tlsDir, file), content, 0o600))
+	}
+}
+
+func TestLoadContextWithTLS(t *testing.T) {
+	c := newCerts(t)
+	configDir := t.TempDir()
+
+	writeContext(t, configDir, "prod", `{
+		"Name": "prod",
+		"Metadata": {"Description": "production"},
+		"Endpoints": {"docker": {"Host": "tcp://docker-prod:2376", "SkipTLSVerify": false}}
+	}`, map[string]string{
+		"ca.pem":   c.caFile,
+		"cert.pem": c.clientCert,
+		"key.pem":  c.clientKey,
+	})
+
+	cfg, err := docker.LoadContext(configDir, "prod")
+	require.NoError(t, err)
+
+	assert.Equal(t, "tcp://docker-prod:2376", cfg.Host)
+	require.NotNil(t, cfg.TLS)
+	assert.Equal(t, filepath.Base(c.caFile), filepath.Base(cfg.TLS.CAFile))
+	assert.NotEmpty(t, cfg.TLS.CertFile)
+	assert.NotEmpty(t, cfg.TLS.KeyFile)
+	assert.False(t, cfg.TLS.InsecureSkipVerify)
+	assert.NoError(t, cfg.Validate())
+}
+
+func TestLoadContextConnectsToTLSDaemon(t *testing.T) {
+	c := newCerts(t)
+	srv, _ := newDockerServer(t, c)
+	configDir := t.TempDir()
+
+	writeContext(t, configDir, "remote", `{
+		"Name": "remote",
+		"Endpoints": {"docker": {"Host": "`+tcpHost(srv)+`"}}
+	}`, map[string]string{
+		"ca.pem":   c.caFile,
+		"cert.pem": c.clientCert,
+		"key.pem":  c.clientKey,
+	})
+
+	cfg, err := docker.LoadContext(configDir, "remote")
+	require.NoError(t, err)
+
+	cli, err := docker.NewClient(cfg)
+	require.NoError(t, err)
+	defer func() { _ = cli.Close() }()
+
+	_, err = cli.Ping(t.Context())
+	assert.NoError(t, err)
+}
+
+func TestLoadContextWithoutTLS(t *testing.T) {
+	configDir := t.TempDir()
+
+	writeContext(t, configDir, "ssh", `{
+		"Name": "ssh",
+		"Endpoints": {"docker": {"Host": "ssh://monitor@docker-1"}}
+	}`, nil)
+
+	cfg, err := docker.LoadContext(configDir, "ssh")
+	require.NoError(t, err)
+
+	assert.Equal(t, "ssh://monitor@docker-1", cfg.Host)
+	assert.Nil(t, cfg.TLS)
+}
+
+func TestLoadContextSkipTLSVerifyEnablesTLS(t *testing.T) {
+	configDir := t.TempDir()
+
+	writeContext(t, configDir, "insecure", `{
+		"Name": "insecure",
+		"Endpoints": {"docker": {"Host": "tcp://docker:2376", "SkipTLSVerify": true}}
+	}`, nil)
+
+	cfg, err := docker.LoadContext(configDir, "insecure")
+	require.NoError(t, err)
+
+	require.NotNil(t, cfg.TLS)
+	assert.True(t, cfg.TLS.InsecureSkipVerify)
+}
+
+func TestLoadContextErrors(t *testing.T) {
+	configDir := t.TempDir()
+
+	writeContext(t, configDir, "kube", `{
+		"Name": "kube",
+		"Endpoints": {"kubernetes": {"Host": "https://kube:6443"}}
+	}`, nil)
+	writeContext(t, configDir, "broken", `{`, nil)
+
+	_, err := docker.LoadContext(configDir, "missing")
+	assert.ErrorContains(t, err, `docker context "missing" does not exist`)
+
+	_, err = docker.LoadContext(configDir, "kube")
+	assert.ErrorContains(t, err, `docker context "kube" has no docker endpoint`)
+
+	_, err = docker.LoadContext(configDir, "broken")
+	assert.ErrorContains(t, err, `failed to parse docker context "broken"`)
+}
+
+func TestCurrentContext(t *testing.T) {
+	configDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(configDir, "config.json"),
+		[]byte(`{"auths": {}, "currentContext": "staging"}`), 0o600))
+
+	tests := []struct {
+		name          string
+		dockerHost    string
+		dockerContext string
+		configDir     string
+		want          string
+	}{
+		{
+			name:      "current context from config.json",
+			configDir: configDir,
+			want:      "staging",
+		},
+		{
+			name:          "DOCKER_CONTEXT overrides config.json",
+			dockerContext: "prod",
+			configDir:     configDir,
+			want:          "prod",
+		},
+		{
+			name:          "DOCKER_HOST forces the default context",
+			dockerHost:    "tcp://docker:2375",
+			dockerContext: "prod",
+			configDir:     configDir,
+			want:          docker.DefaultContext,
+		},
+		{
+			name:      "missing config.json",
+			configDir: t.TempDir(),
+			want:      docker.DefaultContext,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("DOCKER_HOST", tt.dockerHost)
+			t.Setenv("DOCKER_CONTEXT", tt.dockerContext)
+
+			got, err := docker.CurrentContext(tt.configDir)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestConfigDir(t *testing.T) {
+	t.Setenv("DOCKER_CONFIG", "/etc/docker-cli")
+	assert.Equal(t, "/etc/docker-cli", docker.ConfigDir())
+}
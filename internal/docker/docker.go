@@ -0,0 +1,178 @@
+package docker
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+
+	"github.com/docker/docker/client"
+)
+
+var apiVersionPattern = regexp.MustCompile(`^[0-9]+\.[0-9]+$`)
+
+// TLSConfig holds the TLS material used to reach a TLS-protected Docker daemon.
+type TLSConfig struct {
+	CAFile             string
+	CertFile           string
+	KeyFile            string
+	InsecureSkipVerify bool
+}
+
+// Config describes how to connect to a Docker daemon.
+type Config struct {
+	// Host is the daemon address, e.g. "unix:///var/run/docker.sock",
+	// "tcp://host:2376" or "ssh://user@host". When empty the DOCKER_HOST
+	// environment variable or the platform default is used.
+	Host string
+	// APIVersion pins the Docker API version (e.g. "1.43") instead of
+	// negotiating it with the daemon.
+	APIVersion string
+	// TLS enables TLS for the connection when set.
+	TLS *TLSConfig
+	// SSH configures the connection to ssh:// hosts.
+	SSH SSHConfig
+}
+
+// Validate checks the configuration without connecting to the daemon, so
+// misconfiguration is reported at startup rather than on the first scrape.
+func (c Config) Validate() error {
+	if isSSHHost(c.Host) {
+		if c.TLS != nil {
+			return fmt.Errorf("docker TLS cannot be used with the ssh host %q", c.Host)
+		}
+
+		if _, err := newSSHDialer(c.Host, c.SSH); err != nil {
+			return err
+		}
+	} else if c.Host != "" {
+		u, err := client.ParseHostURL(c.Host)
+		if err != nil {
+			return fmt.Errorf("invalid docker host %q: %w", c.Host, err)
+		}
+
+		if c.TLS != nil && u.Scheme != "tcp" && u.Scheme != "https" {
+			return fmt.Errorf("docker TLS requires a tcp:// host, got %q", c.Host)
+		}
+	}
+
+	if c.APIVersion != "" && !apiVersionPattern.MatchString(c.APIVersion) {
+		return fmt.Errorf("invalid docker API version %q: expected <major>.<minor>, e.g. 1.43", c.APIVersion)
+	}
+
+	if c.TLS != nil {
+		if _, err := c.TLS.build(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Scheme returns the URL scheme the Docker client uses to reach the daemon
+// for cfg, for requests the client does not cover itself.
+func (c Config) Scheme() string {
+	if c.TLS != nil {
+		return "https"
+	}
+
+	if !isSSHHost(c.Host) && os.Getenv(client.EnvOverrideCertPath) != "" {
+		return "https"
+	}
+
+	return "http"
+}
+
+// NewClient creates a Docker API client for cfg. An empty config behaves like
+// the Docker CLI and configures the client from the environment.
+func NewClient(cfg Config) (*client.Client, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	if cfg.Host == "" && cfg.TLS == nil && cfg.APIVersion == "" {
+		return client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	}
+
+	var opts []client.Opt
+
+	if isSSHHost(cfg.Host) {
+		dialer, err := newSSHDialer(cfg.Host, cfg.SSH)
+		if err != nil {
+			return nil, err
+		}
+
+		opts = append(opts,
+			client.WithHTTPClient(&http.Client{
+				Transport:     &http.Transport{DialContext: dialer.DialContext},
+				CheckRedirect: client.CheckRedirect,
+			}),
+			client.WithHost(sshDummyHost),
+			client.WithDialContext(dialer.DialContext),
+		)
+	} else if cfg.TLS != nil {
+		tlsConfig, err := cfg.TLS.build()
+		if err != nil {
+			return nil, err
+		}
+
+		opts = append(opts, client.WithHTTPClient(&http.Client{
+			Transport:     &http.Transport{TLSClientConfig: tlsConfig},
+			CheckRedirect: client.CheckRedirect,
+		}))
+	} else {
+		opts = append(opts, client.WithTLSClientConfigFromEnv())
+	}
+
+	if cfg.Host == "" {
+		opts = append(opts, client.WithHostFromEnv())
+	} else if !isSSHHost(cfg.Host) {
+		opts = append(opts, client.WithHost(cfg.Host))
+	}
+
+	if cfg.APIVersion != "" {
+		opts = append(opts, client.WithVersion(cfg.APIVersion))
+	} else {
+		opts = append(opts, client.WithVersionFromEnv(), client.WithAPIVersionNegotiation())
+	}
+
+	return client.NewClientWithOpts(opts...)
+}
+
+// build loads the configured certificates into a *tls.Config.
+func (c *TLSConfig) build() (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: c.InsecureSkipVerify, //nolint:gosec // explicitly requested by the user
+	}
+
+	if c.CAFile != "" {
+		pem, err := os.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read docker TLS CA file: %w", err)
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in docker TLS CA file %q", c.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if (c.CertFile == "") != (c.KeyFile == "") {
+		return nil, errors.New("docker TLS client certificate and key must be set together")
+	}
+
+	if c.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load docker TLS client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
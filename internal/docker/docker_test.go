@@ -0,0 +1,335 @@
+package docker_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/davidborzek/docker-exporter/internal/docker"
+	"github.com/docker/docker/api/types/container"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// certs are the PEM files of a throwaway CA with a server and a client
+// certificate issued by it.
+type certs struct {
+	ca         *x509.Certificate
+	caFile     string
+	serverCert tls.Certificate
+	clientCert string
+	clientKey  string
+}
+
+func newCerts(t *testing.T) certs {
+	t.Helper()
+
+	dir := t.TempDir()
+
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	require.NoError(t, err)
+	ca, err := x509.ParseCertificate(caDER)
+	require.NoError(t, err)
+
+	issue := func(serial int64, usage x509.ExtKeyUsage) ([]byte, []byte) {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		require.NoError(t, err)
+
+		template := &x509.Certificate{
+			SerialNumber: big.NewInt(serial),
+			Subject:      pkix.Name{CommonName: "127.0.0.1"},
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     time.Now().Add(time.Hour),
+			KeyUsage:     x509.KeyUsageDigitalSignature,
+			ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+			IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		}
+		der, err := x509.CreateCertificate(rand.Reader, template, ca, &key.PublicKey, caKey)
+		require.NoError(t, err)
+
+		keyDER, err := x509.MarshalECPrivateKey(key)
+		require.NoError(t, err)
+
+		return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+			pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	}
+
+	write := func(name string, content []byte) string {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, content, 0o600))
+		return path
+	}
+
+	serverCertPEM, serverKeyPEM := issue(2, x509.ExtKeyUsageServerAuth)
+	serverCert, err := tls.X509KeyPair(serverCertPEM, serverKeyPEM)
+	require.NoError(t, err)
+
+	clientCertPEM, clientKeyPEM := issue(3, x509.ExtKeyUsageClientAuth)
+
+	return certs{
+		ca:         ca,
+		caFile:     write("ca.pem", pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER})),
+		serverCert: serverCert,
+		clientCert: write("cert.pem", clientCertPEM),
+		clientKey:  write("key.pem", clientKeyPEM),
+	}
+}
+
+// newDockerServer starts a TLS server acting as the Docker API which requires
+// a client certificate issued by the test CA. It records the request paths.
+func newDockerServer(t *testing.T, c certs) (*httptest.Server, func() []string) {
+	t.Helper()
+
+	var (
+		mu    sync.Mutex
+		paths []string
+	)
+
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		paths = append(paths, r.URL.Path)
+		mu.Unlock()
+
+		w.Header().Set("Api-Version", "1.45")
+		w.Header().Set("Content-Type", "application/json")
+
+		if strings.HasSuffix(r.URL.Path, "/containers/json") {
+			_, _ = w.Write([]byte(`[]`))
+			return
+		}
+
+		_, _ = w.Write([]byte(`OK`))
+	}))
+
+	pool := x509.NewCertPool()
+	pool.AddCert(c.ca)
+
+	srv.TLS = &tls.Config{
+		Certificates: []tls.Certificate{c.serverCert},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    pool,
+	}
+	srv.Config.ErrorLog = log.New(io.Discard, "", 0)
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+
+	return srv, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), paths...)
+	}
+}
+
+func tcpHost(srv *httptest.Server) string {
+	return "tcp://" + srv.Listener.Addr().String()
+}
+
+func TestNewClientConnectsWithMutualTLS(t *testing.T) {
+	c := newCerts(t)
+	srv, _ := newDockerServer(t, c)
+
+	cli, err := docker.NewClient(docker.Config{
+		Host: tcpHost(srv),
+		TLS: &docker.TLSConfig{
+			CAFile:   c.caFile,
+			CertFile: c.clientCert,
+			KeyFile:  c.clientKey,
+		},
+	})
+	require.NoError(t, err)
+	defer func() { _ = cli.Close() }()
+
+	_, err = cli.Ping(context.Background())
+	assert.NoError(t, err)
+}
+
+func TestNewClientRejectsUnknownServerCertificate(t *testing.T) {
+	c := newCerts(t)
+	other := newCerts(t)
+	srv, _ := newDockerServer(t, c)
+
+	cli, err := docker.NewClient(docker.Config{
+		Host: tcpHost(srv),
+		TLS: &docker.TLSConfig{
+			CAFile:   other.caFile,
+			CertFile: c.clientCert,
+			KeyFile:  c.clientKey,
+		},
+	})
+	require.NoError(t, err)
+	defer func() { _ = cli.Close() }()
+
+	_, err = cli.Ping(context.Background())
+	assert.ErrorContains(t, err, "certificate signed by unknown authority")
+}
+
+func TestNewClientSkipsVerificationWhenRequested(t *testing.T) {
+	c := newCerts(t)
+	srv, _ := newDockerServer(t, c)
+
+	cli, err := docker.NewClient(docker.Config{
+		Host: tcpHost(srv),
+		TLS: &docker.TLSConfig{
+			CertFile:           c.clientCert,
+			KeyFile:            c.clientKey,
+			InsecureSkipVerify: true,
+		},
+	})
+	require.NoError(t, err)
+	defer func() { _ = cli.Close() }()
+
+	_, err = cli.Ping(context.Background())
+	assert.NoError(t, err)
+}
+
+func TestNewClientWithoutClientCertificateIsRejected(t *testing.T) {
+	c := newCerts(t)
+	srv, _ := newDockerServer(t, c)
+
+	cli, err := docker.NewClient(docker.Config{
+		Host: tcpHost(srv),
+		TLS:  &docker.TLSConfig{CAFile: c.caFile},
+	})
+	require.NoError(t, err)
+	defer func() { _ = cli.Close() }()
+
+	_, err = cli.Ping(context.Background())
+	assert.Error(t, err)
+}
+
+func TestNewClientPinsAPIVersion(t *testing.T) {
+	c := newCerts(t)
+	srv, paths := newDockerServer(t, c)
+
+	cli, err := docker.NewClient(docker.Config{
+		Host:       tcpHost(srv),
+		APIVersion: "1.41",
+		TLS: &docker.TLSConfig{
+			CAFile:   c.caFile,
+			CertFile: c.clientCert,
+			KeyFile:  c.clientKey,
+		},
+	})
+	require.NoError(t, err)
+	defer func() { _ = cli.Close() }()
+
+	assert.Equal(t, "1.41", cli.ClientVersion())
+
+	_, err = cli.ContainerList(context.Background(), container.ListOptions{})
+	require.NoError(t, err)
+	assert.Contains(t, paths(), "/v1.41/containers/json")
+}
+
+func TestValidate(t *testing.T) {
+	c := newCerts(t)
+
+	tests := []struct {
+		name    string
+		config  docker.Config
+		wantErr string
+	}{
+		{
+			name:   "empty config uses the environment",
+			config: docker.Config{},
+		},
+		{
+			name: "valid tls config",
+			config: docker.Config{
+				Host:       "tcp://docker:2376",
+				APIVersion: "1.43",
+				TLS: &docker.TLSConfig{
+					CAFile:   c.caFile,
+					CertFile: c.clientCert,
+					KeyFile:  c.clientKey,
+				},
+			},
+		},
+		{
+			name:    "invalid host",
+			config:  docker.Config{Host: "docker:2376"},
+			wantErr: `invalid docker host "docker:2376"`,
+		},
+		{
+			name: "tls over unix socket",
+			config: docker.Config{
+				Host: "unix:///var/run/docker.sock",
+				TLS:  &docker.TLSConfig{},
+			},
+			wantErr: "docker TLS requires a tcp:// host",
+		},
+		{
+			name:    "invalid api version",
+			config:  docker.Config{APIVersion: "v1"},
+			wantErr: `invalid docker API version "v1"`,
+		},
+		{
+			name: "missing ca file",
+			config: docker.Config{
+				TLS: &docker.TLSConfig{CAFile: filepath.Join(t.TempDir(), "missing.pem")},
+			},
+			wantErr: "failed to read docker TLS CA file",
+		},
+		{
+			name: "ca file without certificates",
+			config: docker.Config{
+				TLS: &docker.TLSConfig{CAFile: c.clientKey},
+			},
+			wantErr: "no certificates found in docker TLS CA file",
+		},
+		{
+			name: "certificate without key",
+			config: docker.Config{
+				TLS: &docker.TLSConfig{CertFile: c.clientCert},
+			},
+			wantErr: "docker TLS client certificate and key must be set together",
+		},
+		{
+			name: "mismatched certificate and key",
+			config: docker.Config{
+				TLS: &docker.TLSConfig{CertFile: c.clientCert, KeyFile: c.caFile},
+			},
+			wantErr: "failed to load docker TLS client certificate",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.config.Validate()
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
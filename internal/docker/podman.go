@@ -0,0 +1,34 @@
+package docker
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// defaultDockerSocket is the socket the Docker client uses when no host is
+// configured.
+const defaultDockerSocket = "/var/run/docker.sock"
+
+// PodmanHost returns the Docker-compatible socket of a local Podman service
+// when the Docker socket does not exist, preferring the rootless socket of
+// the current user over the system one. It returns an empty string when no
+// Podman socket is found.
+func PodmanHost() string {
+	if _, err := os.Stat(defaultDockerSocket); err == nil {
+		return ""
+	}
+
+	var candidates []string
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		candidates = append(candidates, filepath.Join(dir, "podman", "podman.sock"))
+	}
+	candidates = append(candidates, "/run/podman/podman.sock")
+
+	for _, socket := range candidates {
+		if _, err := os.Stat(socket); err == nil {
+			return "unix://" + socket
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,334 @@
+package docker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"os/user"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// sshDialStdio is the command the Docker CLI runs on the remote host to
+// proxy the Docker API over the SSH session's stdin and stdout.
+const sshDialStdio = "docker system dial-stdio"
+
+// sshDummyHost is the HTTP host used for requests tunneled through SSH. The
+// actual daemon is reached through the dialer, so it is never resolved.
+const sshDummyHost = "http://docker.example.com"
+
+// SSHConfig configures the connection to ssh:// hosts.
+type SSHConfig struct {
+	// KeyFile is the private key used to authenticate. When empty the agent
+	// listening on SSH_AUTH_SOCK is used.
+	KeyFile string
+	// KnownHostsFile is used to verify the host key. Defaults to
+	// ~/.ssh/known_hosts.
+	KnownHostsFile string
+}
+
+// isSSHHost reports whether host uses the ssh:// scheme.
+func isSSHHost(host string) bool {
+	u, err := url.Parse(host)
+	return err == nil && u.Scheme == "ssh"
+}
+
+// sshDialer opens a "docker system dial-stdio" session per connection, the
+// way the Docker CLI's SSH connection helper does, but over a native SSH
+// client. SSH connections are shared between sessions and closed once the
+// last session is closed; a broken connection is replaced on the next dial.
+type sshDialer struct {
+	addr   string
+	config *ssh.ClientConfig
+
+	mu      sync.Mutex
+	current *sshClient
+}
+
+// sshClient is an SSH connection with the number of sessions using it.
+type sshClient struct {
+	*ssh.Client
+	sessions int
+}
+
+// newSSHDialer parses an ssh://[user@]host[:port] host and loads the
+// credentials and known hosts to connect to it.
+func newSSHDialer(host string, cfg SSHConfig) (*sshDialer, error) {
+	u, err := url.Parse(host)
+	if err != nil {
+		return nil, fmt.Errorf("invalid docker host %q: %w", host, err)
+	}
+
+	if (u.Path != "" && u.Path != "/") || u.RawQuery != "" || u.Fragment != "" {
+		return nil, fmt.Errorf("invalid docker host %q: ssh hosts must not contain a path or query", host)
+	}
+
+	if u.Hostname() == "" {
+		return nil, fmt.Errorf("invalid docker host %q: missing hostname", host)
+	}
+
+	username := u.User.Username()
+	if username == "" {
+		current, err := user.Current()
+		if err != nil {
+			return nil, fmt.Errorf("failed to determine ssh user: %w", err)
+		}
+		username = current.Username
+	}
+
+	port := u.Port()
+	if port == "" {
+		port = "22"
+	}
+
+	auth, err := sshAuth(cfg.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	knownHostsFile := cfg.KnownHostsFile
+	if knownHostsFile == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, fmt.Errorf("failed to locate ssh known_hosts file: %w", err)
+		}
+		knownHostsFile = filepath.Join(home, ".ssh", "known_hosts")
+	}
+
+	hostKeyCallback, err := knownhosts.New(knownHostsFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ssh known_hosts file: %w", err)
+	}
+
+	return &sshDialer{
+		addr: net.JoinHostPort(u.Hostname(), port),
+		config: &ssh.ClientConfig{
+			User:            username,
+			Auth:            []ssh.AuthMethod{auth},
+			HostKeyCallback: hostKeyCallback,
+			Timeout:         30 * time.Second,
+		},
+	}, nil
+}
+
+// sshAuth returns the public key authentication method for keyFile, or the
+// SSH agent when no key file is configured.
+func sshAuth(keyFile string) (ssh.AuthMethod, error) {
+	if keyFile != "" {
+		raw, err := os.ReadFile(keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read ssh key file: %w", err)
+		}
+
+		signer, err := ssh.ParsePrivateKey(raw)
+		if err != nil {
+			var passphraseErr *ssh.PassphraseMissingError
+			if errors.As(err, &passphraseErr) {
+				return nil, fmt.Errorf("ssh key file %q is passphrase protected; load it into ssh-agent instead", keyFile)
+			}
+			return nil, fmt.Errorf("failed to parse ssh key file: %w", err)
+		}
+
+		return ssh.PublicKeys(signer), nil
+	}
+
+	socket := os.Getenv("SSH_AUTH_SOCK")
+	if socket == "" {
+		return nil, errors.New("no ssh key file configured and SSH_AUTH_SOCK is not set")
+	}
+
+	return ssh.PublicKeysCallback(func() ([]ssh.Signer, error) {
+		conn, err := net.Dial("unix", socket)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to ssh agent: %w", err)
+		}
+		defer func() { _ = conn.Close() }()
+
+		return agent.NewClient(conn).Signers()
+	}), nil
+}
+
+// DialContext opens a new dial-stdio session. The network and address are
+// ignored as the daemon is always reached through the SSH host.
+func (d *sshDialer) DialContext(ctx context.Context, _, _ string) (net.Conn, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	reused := d.current != nil
+
+	conn, err := d.openSession(ctx)
+	if err != nil && reused {
+		// The shared connection may have been dropped since it was last
+		// used, so reconnect once before giving up.
+		d.current = nil
+		conn, err = d.openSession(ctx)
+	}
+
+	return conn, err
+}
+
+// openSession starts dial-stdio on the current SSH connection, connecting
+// first if there is none. d.mu must be held.
+func (d *sshDialer) openSession(ctx context.Context) (net.Conn, error) {
+	if d.current == nil {
+		client, err := d.connect(ctx)
+		if err != nil {
+			return nil, err
+		}
+		d.current = &sshClient{Client: client}
+	}
+
+	c := d.current
+
+	session, err := c.NewSession()
+	if err != nil {
+		d.closeIfUnused(c)
+		return nil, fmt.Errorf("failed to open ssh session to %s: %w", d.addr, err)
+	}
+
+	stdin, err := session.StdinPipe()
+	if err != nil {
+		_ = session.Close()
+		return nil, err
+	}
+
+	stdout, err := session.StdoutPipe()
+	if err != nil {
+		_ = session.Close()
+		return nil, err
+	}
+
+	if err := session.Start(sshDialStdio); err != nil {
+		_ = session.Close()
+		d.closeIfUnused(c)
+		return nil, fmt.Errorf("failed to run %q on %s: %w", sshDialStdio, d.addr, err)
+	}
+
+	c.sessions++
+
+	return &sshConn{
+		dialer:  d,
+		client:  c,
+		session: session,
+		stdin:   stdin,
+		stdout:  stdout,
+	}, nil
+}
+
+// connect establishes a new SSH connection. The handshake is bounded by the
+// deadline of ctx or the configured timeout, whichever is earlier, and is
+// aborted once ctx is done, so a host that accepts connections but never
+// answers does not block the dialer.
+func (d *sshDialer) connect(ctx context.Context) (*ssh.Client, error) {
+	dialer := net.Dialer{Timeout: d.config.Timeout}
+
+	conn, err := dialer.DialContext(ctx, "tcp", d.addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to ssh host %s: %w", d.addr, err)
+	}
+
+	deadline := time.Now().Add(d.config.Timeout)
+	ctxDeadline, bounded := ctx.Deadline()
+	bounded = bounded && ctxDeadline.Before(deadline)
+	if bounded {
+		deadline = ctxDeadline
+	}
+	_ = conn.SetDeadline(deadline)
+
+	stop := context.AfterFunc(ctx, func() { _ = conn.Close() })
+
+	c, chans, reqs, err := ssh.NewClientConn(conn, d.addr, d.config)
+	if !stop() {
+		// ctx is done and the connection was closed.
+		if err == nil {
+			_ = c.Close()
+		}
+		return nil, fmt.Errorf("ssh handshake with %s failed: %w", d.addr, context.Cause(ctx))
+	}
+	if err != nil {
+		_ = conn.Close()
+		// The deadline of the connection may pass just before ctx is done.
+		if bounded && errors.Is(err, os.ErrDeadlineExceeded) {
+			err = context.DeadlineExceeded
+		}
+		return nil, fmt.Errorf("ssh handshake with %s failed: %w", d.addr, err)
+	}
+
+	// The sessions are bounded by the request contexts instead.
+	_ = conn.SetDeadline(time.Time{})
+
+	return ssh.NewClient(c, chans, reqs), nil
+}
+
+// release is called when a session is closed.
+func (d *sshDialer) release(c *sshClient) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	c.sessions--
+	d.closeIfUnused(c)
+}
+
+// closeIfUnused closes c once no session uses it anymore. d.mu must be held.
+func (d *sshDialer) closeIfUnused(c *sshClient) {
+	if c.sessions > 0 {
+		return
+	}
+
+	_ = c.Close()
+	if d.current == c {
+		d.current = nil
+	}
+}
+
+// sshConn adapts a dial-stdio session to a net.Conn.
+type sshConn struct {
+	dialer  *sshDialer
+	client  *sshClient
+	session *ssh.Session
+	stdin   io.WriteCloser
+	stdout  io.Reader
+
+	closeOnce sync.Once
+}
+
+func (c *sshConn) Read(b []byte) (int, error) {
+	return c.stdout.Read(b)
+}
+
+func (c *sshConn) Write(b []byte) (int, error) {
+	return c.stdin.Write(b)
+}
+
+func (c *sshConn) Close() error {
+	c.closeOnce.Do(func() {
+		_ = c.stdin.Close()
+		_ = c.session.Close()
+		c.dialer.release(c.client)
+	})
+
+	return nil
+}
+
+func (c *sshConn) LocalAddr() net.Addr {
+	return c.client.LocalAddr()
+}
+
+func (c *sshConn) RemoteAddr() net.Addr {
+	return c.client.RemoteAddr()
+}
+
+// Deadlines are not supported by SSH sessions; timeouts are enforced by the
+// request context instead.
+func (c *sshConn) SetDeadline(time.Time) error      { return nil }
+func (c *sshConn) SetReadDeadline(time.Time) error  { return nil }
+func (c *sshConn) SetWriteDeadline(time.Time) error { return nil }
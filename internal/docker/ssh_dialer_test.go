@@ -0,0 +1,105 @@
+package docker
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+)
+
+// newStalledSSHDialer returns a dialer of a host accepting connections but
+// never speaking SSH, and a channel receiving a value whenever the dialer
+// closes one of the connections.
+func newStalledSSHDialer(t *testing.T) (*sshDialer, <-chan struct{}) {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = ln.Close() })
+
+	closed := make(chan struct{}, 8)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer func() { _ = conn.Close() }()
+				_, _ = io.Copy(io.Discard, conn)
+				closed <- struct{}{}
+			}()
+		}
+	}()
+
+	dir := t.TempDir()
+
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	block, err := ssh.MarshalPrivateKey(priv, "")
+	require.NoError(t, err)
+	keyFile := filepath.Join(dir, "id_ed25519")
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(block), 0o600))
+
+	knownHostsFile := filepath.Join(dir, "known_hosts")
+	require.NoError(t, os.WriteFile(knownHostsFile, nil, 0o600))
+
+	d, err := newSSHDialer("ssh://tester@"+ln.Addr().String(), SSHConfig{
+		KeyFile:        keyFile,
+		KnownHostsFile: knownHostsFile,
+	})
+	require.NoError(t, err)
+
+	return d, closed
+}
+
+func waitClosed(t *testing.T, closed <-chan struct{}) {
+	t.Helper()
+
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("stalled ssh handshake was not aborted")
+	}
+}
+
+func TestSSHDialerAbortsStalledHandshake(t *testing.T) {
+	d, closed := newStalledSSHDialer(t)
+
+	// The dialer's lock is released once a dial gives up, so a later dial
+	// is not blocked by the stalled one.
+	for range 2 {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		_, err := d.DialContext(ctx, "tcp", "")
+		cancel()
+
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		waitClosed(t, closed)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+	_, err := d.DialContext(ctx, "tcp", "")
+	assert.ErrorIs(t, err, context.Canceled)
+	waitClosed(t, closed)
+}
+
+func TestSSHDialerBoundsHandshakeByTimeout(t *testing.T) {
+	d, closed := newStalledSSHDialer(t)
+	d.config.Timeout = 100 * time.Millisecond
+
+	// The dials of the Docker client are not bounded by the request context.
+	_, err := d.DialContext(context.Background(), "tcp", "")
+	assert.ErrorIs(t, err, os.ErrDeadlineExceeded)
+	waitClosed(t, closed)
+}
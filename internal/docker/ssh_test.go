@@ -0,0 +1,384 @@
+package docker_test
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/davidborzek/docker-exporter/internal/docker"
+	"github.com/docker/docker/api/types/container"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// sshServer is an in-process stand-in for a remote host running sshd and
+// Docker: "docker system dial-stdio" sessions are served by an HTTP handler
+// acting as the Docker API.
+type sshServer struct {
+	listener net.Listener
+	conns    *connListener
+	config   *ssh.ServerConfig
+
+	mu       sync.Mutex
+	accepted int
+	users    []string
+	commands []string
+	open     []*ssh.ServerConn
+}
+
+func newSSHServer(t *testing.T, authorized ssh.PublicKey) (*sshServer, ssh.PublicKey) {
+	t.Helper()
+
+	_, hostKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	hostSigner, err := ssh.NewSignerFromKey(hostKey)
+	require.NoError(t, err)
+
+	s := &sshServer{conns: newConnListener()}
+	s.config = &ssh.ServerConfig{
+		PublicKeyCallback: func(meta ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if string(key.Marshal()) != string(authorized.Marshal()) {
+				return nil, errors.New("unauthorized key")
+			}
+			return nil, nil
+		},
+	}
+	s.config.AddHostKey(hostSigner)
+
+	s.listener, err = net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	api := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Api-Version", "1.45")
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`[]`))
+	})}
+	go func() { _ = api.Serve(s.conns) }()
+
+	go s.serve()
+
+	t.Cleanup(func() {
+		_ = s.listener.Close()
+		_ = api.Close()
+		s.closeConnections()
+	})
+
+	return s, hostSigner.PublicKey()
+}
+
+func (s *sshServer) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		go s.handle(conn)
+	}
+}
+
+func (s *sshServer) handle(conn net.Conn) {
+	sc, chans, reqs, err := ssh.NewServerConn(conn, s.config)
+	if err != nil {
+		_ = conn.Close()
+		return
+	}
+
+	s.mu.Lock()
+	s.accepted++
+	s.users = append(s.users, sc.User())
+	s.open = append(s.open, sc)
+	s.mu.Unlock()
+
+	go ssh.DiscardRequests(reqs)
+
+	for newChannel := range chans {
+		if newChannel.ChannelType() != "session" {
+			_ = newChannel.Reject(ssh.UnknownChannelType, "unsupported channel type")
+			continue
+		}
+
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			continue
+		}
+
+		go func() {
+			for req := range requests {
+				var exec struct{ Command string }
+				if req.Type != "exec" || ssh.Unmarshal(req.Payload, &exec) != nil {
+					_ = req.Reply(false, nil)
+					continue
+				}
+
+				s.mu.Lock()
+				s.commands = append(s.commands, exec.Command)
+				s.mu.Unlock()
+
+				if exec.Command != "docker system dial-stdio" {
+					_ = req.Reply(false, nil)
+					continue
+				}
+
+				_ = req.Reply(true, nil)
+				s.conns.ch <- &channelConn{Channel: channel}
+			}
+		}()
+	}
+}
+
+// closeConnections drops every SSH connection, as a restarted sshd or a
+// network interruption would.
+func (s *sshServer) closeConnections() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, c := range s.open {
+		_ = c.Close()
+	}
+	s.open = nil
+}
+
+func (s *sshServer) stats() (int, []string, []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.accepted, append([]string(nil), s.users...), append([]string(nil), s.commands...)
+}
+
+// connListener hands dial-stdio sessions to the HTTP server.
+type connListener struct {
+	ch     chan net.Conn
+	closed chan struct{}
+	once   sync.Once
+}
+
+func newConnListener() *connListener {
+	return &connListener{ch: make(chan net.Conn), closed: make(chan struct{})}
+}
+
+func (l *connListener) Accept() (net.Conn, error) {
+	select {
+	case c := <-l.ch:
+		return c, nil
+	case <-l.closed:
+		return nil, net.ErrClosed
+	}
+}
+
+func (l *connListener) Close() error {
+	l.once.Do(func() { close(l.closed) })
+	return nil
+}
+
+func (l *connListener) Addr() net.Addr {
+	return &net.UnixAddr{Name: "dial-stdio", Net: "unix"}
+}
+
+// channelConn adapts a server-side SSH channel to a net.Conn.
+type channelConn struct {
+	ssh.Channel
+}
+
+func (c *channelConn) LocalAddr() net.Addr              { return &net.UnixAddr{Name: "dial-stdio", Net: "unix"} }
+func (c *channelConn) RemoteAddr() net.Addr             { return &net.UnixAddr{Name: "dial-stdio", Net: "unix"} }
+func (c *channelConn) SetDeadline(time.Time) error      { return nil }
+func (c *channelConn) SetReadDeadline(time.Time) error  { return nil }
+func (c *channelConn) SetWriteDeadline(time.Time) error { return nil }
+
+// writeSSHClientKey writes a fresh client key to dir.
+func writeSSHClientKey(t *testing.T, dir string) (string, ssh.PublicKey) {
+	t.Helper()
+
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	block, err := ssh.MarshalPrivateKey(priv, "")
+	require.NoError(t, err)
+
+	keyFile := filepath.Join(dir, "id_ed25519")
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(block), 0o600))
+
+	sshPub, err := ssh.NewPublicKey(pub)
+	require.NoError(t, err)
+
+	return keyFile, sshPub
+}
+
+// writeKnownHosts writes a known_hosts file trusting hostKey for addr.
+func writeKnownHosts(t *testing.T, path, addr string, hostKey ssh.PublicKey) {
+	t.Helper()
+
+	line := knownhosts.Line([]string{knownhosts.Normalize(addr)}, hostKey)
+	require.NoError(t, os.WriteFile(path, []byte(line+"\n"), 0o600))
+}
+
+// newSSHSetup starts an SSH server and returns a client config for it.
+func newSSHSetup(t *testing.T) (*sshServer, docker.Config) {
+	t.Helper()
+
+	dir := t.TempDir()
+	keyFile, clientKey := writeSSHClientKey(t, dir)
+	srv, hostKey := newSSHServer(t, clientKey)
+
+	addr := srv.listener.Addr().String()
+	knownHostsFile := filepath.Join(dir, "known_hosts")
+	writeKnownHosts(t, knownHostsFile, addr, hostKey)
+
+	return srv, docker.Config{
+		Host: "ssh://tester@" + addr,
+		SSH: docker.SSHConfig{
+			KeyFile:        keyFile,
+			KnownHostsFile: knownHostsFile,
+		},
+	}
+}
+
+func randomHostKey(t *testing.T) ssh.PublicKey {
+	t.Helper()
+
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	key, err := ssh.NewPublicKey(pub)
+	require.NoError(t, err)
+
+	return key
+}
+
+func TestNewClientConnectsOverSSH(t *testing.T) {
+	srv, cfg := newSSHSetup(t)
+
+	cli, err := docker.NewClient(cfg)
+	require.NoError(t, err)
+	defer func() { _ = cli.Close() }()
+
+	containers, err := cli.ContainerList(context.Background(), container.ListOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, containers)
+
+	accepted, users, commands := srv.stats()
+	assert.Equal(t, 1, accepted)
+	assert.Equal(t, []string{"tester"}, users)
+	assert.Contains(t, commands, "docker system dial-stdio")
+}
+
+func TestNewClientReconnectsOverSSH(t *testing.T) {
+	srv, cfg := newSSHSetup(t)
+
+	cli, err := docker.NewClient(cfg)
+	require.NoError(t, err)
+	defer func() { _ = cli.Close() }()
+
+	_, err = cli.ContainerList(context.Background(), container.ListOptions{})
+	require.NoError(t, err)
+
+	srv.closeConnections()
+
+	require.Eventually(t, func() bool {
+		_, err := cli.ContainerList(context.Background(), container.ListOptions{})
+		return err == nil
+	}, 5*time.Second, 50*time.Millisecond)
+
+	accepted, _, _ := srv.stats()
+	assert.Equal(t, 2, accepted)
+}
+
+func TestNewClientRejectsUnknownSSHHostKey(t *testing.T) {
+	srv, cfg := newSSHSetup(t)
+
+	writeKnownHosts(t, cfg.SSH.KnownHostsFile, srv.listener.Addr().String(), randomHostKey(t))
+
+	cli, err := docker.NewClient(cfg)
+	require.NoError(t, err)
+	defer func() { _ = cli.Close() }()
+
+	_, err = cli.ContainerList(context.Background(), container.ListOptions{})
+	assert.ErrorContains(t, err, "knownhosts: key mismatch")
+}
+
+func TestValidateSSH(t *testing.T) {
+	t.Setenv("SSH_AUTH_SOCK", "")
+
+	_, cfg := newSSHSetup(t)
+
+	tests := []struct {
+		name    string
+		config  docker.Config
+		wantErr string
+	}{
+		{
+			name:   "valid ssh config",
+			config: cfg,
+		},
+		{
+			name: "tls with ssh",
+			config: docker.Config{
+				Host: cfg.Host,
+				SSH:  cfg.SSH,
+				TLS:  &docker.TLSConfig{},
+			},
+			wantErr: "docker TLS cannot be used with the ssh host",
+		},
+		{
+			name: "path in host",
+			config: docker.Config{
+				Host: "ssh://tester@docker/var/run/docker.sock",
+				SSH:  cfg.SSH,
+			},
+			wantErr: "ssh hosts must not contain a path or query",
+		},
+		{
+			name: "missing known_hosts",
+			config: docker.Config{
+				Host: cfg.Host,
+				SSH: docker.SSHConfig{
+					KeyFile:        cfg.SSH.KeyFile,
+					KnownHostsFile: filepath.Join(t.TempDir(), "missing"),
+				},
+			},
+			wantErr: "failed to read ssh known_hosts file",
+		},
+		{
+			name: "no key and no agent",
+			config: docker.Config{
+				Host: cfg.Host,
+				SSH:  docker.SSHConfig{KnownHostsFile: cfg.SSH.KnownHostsFile},
+			},
+			wantErr: "no ssh key file configured and SSH_AUTH_SOCK is not set",
+		},
+		{
+			name: "invalid key file",
+			config: docker.Config{
+				Host: cfg.Host,
+				SSH: docker.SSHConfig{
+					KeyFile:        cfg.SSH.KnownHostsFile,
+					KnownHostsFile: cfg.SSH.KnownHostsFile,
+				},
+			},
+			wantErr: "failed to parse ssh key file",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.config.Validate()
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
@@ -0,0 +1,38 @@
+// Package filestate detects changes of files which are read again once they
+// change on disk, e.g. rotated certificates or token files.
+package filestate
+
+import (
+	"os"
+	"slices"
+	"time"
+)
+
+// State identifies the version of a set of files.
+type State []file
+
+type file struct {
+	modTime time.Time
+	size    int64
+}
+
+// Of returns the current state of the files at paths.
+func Of(paths ...string) (State, error) {
+	state := make(State, 0, len(paths))
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		state = append(state, file{modTime: info.ModTime(), size: info.Size()})
+	}
+
+	return state, nil
+}
+
+// Equal reports whether none of the files changed between s and other.
+func (s State) Equal(other State) bool {
+	return slices.EqualFunc(s, other, func(a, b file) bool {
+		return a.modTime.Equal(b.modTime) && a.size == b.size
+	})
+}
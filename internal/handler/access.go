@@ -0,0 +1,161 @@
+package handler
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// AccessConfig restricts the clients allowed to reach the exporter by their
+// IP address.
+type AccessConfig struct {
+	// Allow lists the networks, e.g. "10.0.0.0/8", allowed to connect. Empty
+	// allows all networks not denied.
+	Allow []string
+	// Deny lists the networks which are rejected, even when allowed.
+	Deny []string
+	// TrustedProxies lists the networks of reverse proxies whose
+	// X-Forwarded-For header names the client.
+	TrustedProxies []string
+}
+
+// AccessList decides whether clients are allowed by their IP address.
+type AccessList struct {
+	allow          []netip.Prefix
+	deny           []netip.Prefix
+	trustedProxies []netip.Prefix
+}
+
+// NewAccessList parses the networks of c. Single addresses are accepted as
+// networks of one address.
+func NewAccessList(c AccessConfig) (*AccessList, error) {
+	var a AccessList
+	var err error
+
+	if a.allow, err = parsePrefixes(c.Allow); err != nil {
+		return nil, fmt.Errorf("allow: %w", err)
+	}
+	if a.deny, err = parsePrefixes(c.Deny); err != nil {
+		return nil, fmt.Errorf("deny: %w", err)
+	}
+	if a.trustedProxies, err = parsePrefixes(c.TrustedProxies); err != nil {
+		return nil, fmt.Errorf("trusted proxies: %w", err)
+	}
+
+	return &a, nil
+}
+
+func parsePrefixes(networks []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(networks))
+	for _, network := range networks {
+		network = strings.TrimSpace(network)
+
+		if !strings.Contains(network, "/") {
+			addr, err := netip.ParseAddr(network)
+			if err != nil {
+				return nil, fmt.Errorf("invalid network %q: %w", network, err)
+			}
+			prefixes = append(prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+
+		prefix, err := netip.ParsePrefix(network)
+		if err != nil {
+			return nil, fmt.Errorf("invalid network %q: %w", network, err)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+
+	return prefixes, nil
+}
+
+func containsAddr(prefixes []netip.Prefix, addr netip.Addr) bool {
+	for _, prefix := range prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// allowed reports whether the client at addr may connect.
+func (a *AccessList) allowed(addr netip.Addr) bool {
+	if a == nil {
+		return true
+	}
+
+	if containsAddr(a.deny, addr) {
+		return false
+	}
+
+	return len(a.allow) == 0 || containsAddr(a.allow, addr)
+}
+
+// clientAddr returns the address of the client of r. The X-Forwarded-For
+// header is only honored when the request comes from a trusted proxy: the
+// client is the last address of the header which is not a trusted proxy
+// itself, as the addresses before it may be forged by the client.
+func (a *AccessList) clientAddr(r *http.Request) (netip.Addr, bool) {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	addr = addr.Unmap()
+
+	if a == nil || !containsAddr(a.trustedProxies, addr) {
+		return addr, true
+	}
+
+	var forwarded []string
+	for _, header := range r.Header.Values("X-Forwarded-For") {
+		forwarded = append(forwarded, strings.Split(header, ",")...)
+	}
+
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		hop, err := netip.ParseAddr(strings.TrimSpace(forwarded[i]))
+		if err != nil {
+			// The proxy forwarded garbage, so the hops before it cannot
+			// be trusted either.
+			return addr, true
+		}
+		addr = hop.Unmap()
+
+		if !containsAddr(a.trustedProxies, addr) {
+			break
+		}
+	}
+
+	return addr, true
+}
+
+// allowClient responds with 403 Forbidden when the client of r is not
+// allowed. It reports whether the request may be served. Clients without an
+// IP address, e.g. of a unix socket, are only allowed without an allow list.
+func (s *handler) allowClient(w http.ResponseWriter, r *http.Request) bool {
+	if s.access == nil {
+		return true
+	}
+
+	addr, _ := s.access.clientAddr(r)
+	if s.access.allowed(addr) {
+		return true
+	}
+
+	s.rejected.WithLabelValues(rejectedDenied).Inc()
+
+	log.WithField("remote", r.RemoteAddr).WithField("client", addr).WithField("path", r.URL.Path).
+		Debug("rejected request of a denied client")
+	http.Error(w, "forbidden", http.StatusForbidden)
+
+	return false
+}
@@ -0,0 +1,106 @@
+package handler_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/davidborzek/docker-exporter/internal/handler"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccessList(t *testing.T) {
+	access, err := handler.NewAccessList(handler.AccessConfig{
+		Allow:          []string{"10.0.0.0/8", "192.168.1.10"},
+		Deny:           []string{"10.0.66.0/24"},
+		TrustedProxies: []string{"172.16.0.0/12"},
+	})
+	require.NoError(t, err)
+
+	registry := prometheus.NewRegistry()
+	h := handler.New(prometheus.NewRegistry(), "",
+		handler.WithAccessList(access), handler.WithRegisterer(registry))
+
+	tests := []struct {
+		name         string
+		remoteAddr   string
+		forwardedFor string
+		want         int
+	}{
+		{name: "allowed network", remoteAddr: "10.1.2.3:5000", want: http.StatusOK},
+		{name: "allowed address", remoteAddr: "192.168.1.10:5000", want: http.StatusOK},
+		{name: "IPv4-mapped address", remoteAddr: "[::ffff:10.1.2.3]:5000", want: http.StatusOK},
+		{name: "other network", remoteAddr: "192.168.1.11:5000", want: http.StatusForbidden},
+		{name: "denied network", remoteAddr: "10.0.66.1:5000", want: http.StatusForbidden},
+		{
+			name:         "forwarded by an untrusted proxy",
+			remoteAddr:   "192.168.1.11:5000",
+			forwardedFor: "10.1.2.3",
+			want:         http.StatusForbidden,
+		},
+		{
+			name:         "forwarded by a trusted proxy",
+			remoteAddr:   "172.16.0.1:5000",
+			forwardedFor: "10.1.2.3",
+			want:         http.StatusOK,
+		},
+		{
+			name:         "forwarded through trusted proxies",
+			remoteAddr:   "172.16.0.1:5000",
+			forwardedFor: "10.1.2.3, 172.16.0.2",
+			want:         http.StatusOK,
+		},
+		{
+			name:         "forged by the client",
+			remoteAddr:   "172.16.0.1:5000",
+			forwardedFor: "10.1.2.3, 192.168.1.11",
+			want:         http.StatusForbidden,
+		},
+		{
+			name:       "trusted proxy without header",
+			remoteAddr: "172.16.0.1:5000",
+			want:       http.StatusForbidden,
+		},
+		{name: "unix socket", remoteAddr: "@", want: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, serve(h, "/metrics", withRemoteAddr(tt.remoteAddr), withForwardedFor(tt.forwardedFor)).Code)
+		})
+	}
+
+	// Health checks are not restricted.
+	assert.Equal(t, http.StatusOK, serve(h, "/health", withRemoteAddr("192.168.1.11:5000")).Code)
+
+	const expected = `
+	# HELP docker_exporter_http_requests_rejected_total Total number of HTTP requests rejected because of the access list or the limits
+	# TYPE docker_exporter_http_requests_rejected_total counter
+	docker_exporter_http_requests_rejected_total{reason="denied"} 6
+	`
+
+	assert.NoError(t, testutil.GatherAndCompare(registry, strings.NewReader(expected),
+		"docker_exporter_http_requests_rejected_total"))
+}
+
+func TestAccessListDenyOnly(t *testing.T) {
+	access, err := handler.NewAccessList(handler.AccessConfig{Deny: []string{"2001:db8::/32"}})
+	require.NoError(t, err)
+
+	h := handler.New(prometheus.NewRegistry(), "", handler.WithAccessList(access))
+
+	assert.Equal(t, http.StatusForbidden, serve(h, "/metrics", withRemoteAddr("[2001:db8::1]:5000")).Code)
+	assert.Equal(t, http.StatusOK, serve(h, "/metrics", withRemoteAddr("[2001:db9::1]:5000")).Code)
+	assert.Equal(t, http.StatusOK, serve(h, "/metrics", withRemoteAddr("@")).Code)
+}
+
+func TestNewAccessListErrors(t *testing.T) {
+	_, err := handler.NewAccessList(handler.AccessConfig{Allow: []string{"10.0.0.0/33"}})
+	assert.ErrorContains(t, err, `allow: invalid network "10.0.0.0/33"`)
+
+	_, err = handler.NewAccessList(handler.AccessConfig{TrustedProxies: []string{"proxy"}})
+	assert.ErrorContains(t, err, `trusted proxies: invalid network "proxy"`)
+}
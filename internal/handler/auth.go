@@ -0,0 +1,348 @@
+package handler
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/davidborzek/docker-exporter/internal/collector"
+	"github.com/davidborzek/docker-exporter/internal/filestate"
+	log "github.com/sirupsen/logrus"
+	"go.yaml.in/yaml/v3"
+)
+
+// authRealm is the realm announced in the WWW-Authenticate header.
+const authRealm = "docker-exporter"
+
+// StaticTokenName is the name of the token configured with the auth token
+// setting, next to the named tokens of a token file.
+const StaticTokenName = "auth-token"
+
+// Reasons of failed authentication attempts, as reported in the reason label
+// of the failed authentication counter.
+const (
+	authMissing   = "missing"
+	authMalformed = "malformed"
+	authInvalid   = "invalid"
+)
+
+// authError is returned for requests without valid credentials. err
+// optionally tells why the credentials are invalid.
+type authError struct {
+	reason string
+	err    error
+}
+
+func (e *authError) Error() string {
+	msg := "authentication failed: " + e.reason + " credentials"
+	if e.err != nil {
+		msg += ": " + e.err.Error()
+	}
+
+	return msg
+}
+
+func (e *authError) Unwrap() error {
+	return e.err
+}
+
+// Token is a named bearer token.
+type Token struct {
+	Name  string `yaml:"name"`
+	Token string `yaml:"token"`
+	// Scope restricts the containers whose metrics the token grants access
+	// to. An empty scope grants access to all containers.
+	Scope TokenScope `yaml:"scope"`
+
+	filter *collector.Filter
+}
+
+// TokenScope selects the containers visible to a token, like the filters of
+// the collector.
+type TokenScope struct {
+	ComposeProjects []string `yaml:"compose_projects"`
+	LabelSelector   string   `yaml:"label_selector"`
+}
+
+// identity is the authenticated client of a request.
+type identity struct {
+	name string
+	// scope restricts the visible containers. Nil grants access to all of
+	// them.
+	scope *collector.Filter
+}
+
+// TokenFile holds the named bearer tokens of a YAML file. The file is read
+// again once it changes, so tokens can be rotated without a restart.
+type TokenFile struct {
+	path string
+
+	mu     sync.Mutex
+	state  filestate.State
+	tokens []Token
+	// failed is set while the file cannot be read.
+	failed bool
+}
+
+// LoadTokenFile reads the tokens of the file at path.
+func LoadTokenFile(path string) (*TokenFile, error) {
+	f := &TokenFile{path: path}
+	if err := f.reload(); err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// Tokens returns the current tokens. When the changed file cannot be read,
+// e.g. because it was removed, emptied or is invalid, none of its tokens are
+// accepted until it is fixed, so removing a token cannot fail silently.
+func (f *TokenFile) Tokens() []Token {
+	if err := f.reload(); err != nil {
+		log.WithError(err).WithField("path", f.path).
+			Error("failed to reload token file - rejecting its tokens")
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.tokens
+}
+
+// reload reads the file again if it changed. On error the tokens are
+// dropped; the error is only returned once until the file was read again.
+func (f *TokenFile) reload() error {
+	state, err := filestate.Of(f.path)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err != nil {
+		return f.fail(nil, err)
+	}
+
+	if f.state != nil && state.Equal(f.state) {
+		return nil
+	}
+
+	raw, err := os.ReadFile(f.path)
+	if err != nil {
+		return f.fail(nil, err)
+	}
+
+	tokens, err := parseTokens(raw)
+	if err != nil {
+		// The invalid file is not read again until it changes.
+		return f.fail(state, fmt.Errorf("invalid token file %q: %w", f.path, err))
+	}
+
+	if f.state != nil || f.failed {
+		log.WithField("path", f.path).
+			Info("token file reloaded")
+	}
+
+	f.state, f.tokens, f.failed = state, tokens, false
+
+	return nil
+}
+
+// fail drops the tokens after the file at state could not be read. f.mu must
+// be held.
+func (f *TokenFile) fail(state filestate.State, err error) error {
+	reported := f.failed
+	f.state, f.tokens, f.failed = state, nil, true
+
+	if reported {
+		return nil
+	}
+
+	return err
+}
+
+func parseTokens(raw []byte) ([]Token, error) {
+	var file struct {
+		Tokens []Token `yaml:"tokens"`
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(raw))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&file); err != nil {
+		return nil, err
+	}
+
+	names := make(map[string]bool, len(file.Tokens))
+	for i, token := range file.Tokens {
+		if token.Name == "" {
+			return nil, fmt.Errorf("token %d: name is required", i)
+		}
+		if token.Token == "" {
+			return nil, fmt.Errorf("token %q: token is required", token.Name)
+		}
+		if names[token.Name] {
+			return nil, fmt.Errorf("token %q: duplicate name", token.Name)
+		}
+		names[token.Name] = true
+
+		filter, err := collector.NewFilter(collector.FilterConfig{
+			ComposeProjects: token.Scope.ComposeProjects,
+			LabelSelector:   token.Scope.LabelSelector,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("token %q: invalid scope: %w", token.Name, err)
+		}
+		file.Tokens[i].filter = filter
+	}
+
+	return file.Tokens, nil
+}
+
+// authEnabled reports whether requests must carry credentials.
+func (s *handler) authEnabled() bool {
+	return s.bearerEnabled() || s.basicAuth != nil
+}
+
+// bearerEnabled reports whether bearer tokens are accepted.
+func (s *handler) bearerEnabled() bool {
+	return s.expectedToken != "" || s.tokenFile != nil || s.jwt != nil
+}
+
+// tokens returns the accepted bearer tokens.
+func (s *handler) tokens() []Token {
+	var tokens []Token
+	if s.expectedToken != "" {
+		tokens = append(tokens, Token{Name: StaticTokenName, Token: s.expectedToken})
+	}
+	if s.tokenFile != nil {
+		tokens = append(tokens, s.tokenFile.Tokens()...)
+	}
+
+	return tokens
+}
+
+// authenticate authenticates a request when authentication is enabled. The
+// identity is named after the token or the user the request was
+// authenticated with, or the subject of a JWT.
+func (s *handler) authenticate(r *http.Request) (identity, error) {
+	if !s.authEnabled() {
+		return identity{}, nil
+	}
+
+	header := r.Header.Get("Authorization")
+	if header == "" {
+		return identity{}, &authError{reason: authMissing}
+	}
+
+	scheme, credentials, ok := strings.Cut(header, " ")
+	if !ok || credentials == "" || strings.ContainsAny(credentials, " \t") {
+		return identity{}, &authError{reason: authMalformed}
+	}
+
+	switch {
+	case strings.EqualFold(scheme, "Bearer") && s.bearerEnabled():
+		if token, ok := matchToken(s.tokens(), credentials); ok {
+			return identity{name: token.Name, scope: token.filter}, nil
+		}
+
+		if s.jwt != nil && looksLikeJWT(credentials) {
+			claims, err := s.jwt.Validate(credentials)
+			if err != nil {
+				return identity{}, &authError{reason: authInvalid, err: err}
+			}
+
+			return identity{name: claims.Subject}, nil
+		}
+	case strings.EqualFold(scheme, "Basic") && s.basicAuth != nil:
+		user, password, ok := r.BasicAuth()
+		if !ok {
+			return identity{}, &authError{reason: authMalformed}
+		}
+
+		if s.basicAuth.verify(user, password) {
+			return identity{name: user}, nil
+		}
+	default:
+		return identity{}, &authError{reason: authMalformed}
+	}
+
+	return identity{}, &authError{reason: authInvalid}
+}
+
+// matchToken returns the token matching credentials. The comparison takes
+// the same time for every token, whether it matches or not.
+func matchToken(tokens []Token, credentials string) (Token, bool) {
+	presented := sha256.Sum256([]byte(credentials))
+
+	var match Token
+	var matched bool
+	for _, token := range tokens {
+		expected := sha256.Sum256([]byte(token.Token))
+		if subtle.ConstantTimeCompare(presented[:], expected[:]) == 1 && !matched {
+			match, matched = token, true
+		}
+	}
+
+	return match, matched
+}
+
+// authorize authenticates a request, responding with 401 Unauthorized when
+// it fails. It reports whether the request may be served by the identity it
+// returns.
+func (s *handler) authorize(w http.ResponseWriter, r *http.Request) (identity, bool) {
+	id, err := s.authenticate(r)
+	if err == nil {
+		return id, true
+	}
+
+	bearer := fmt.Sprintf("Bearer realm=%q", authRealm)
+
+	var authErr *authError
+	if errors.As(err, &authErr) {
+		s.authFailures.WithLabelValues(authErr.reason).Inc()
+
+		switch authErr.reason {
+		case authMalformed:
+			bearer += `, error="invalid_request"`
+		case authInvalid:
+			bearer += `, error="invalid_token"`
+		}
+	}
+
+	log.WithError(err).WithField("remote", r.RemoteAddr).WithField("path", r.URL.Path).
+		Debug("rejected unauthenticated request")
+
+	if s.bearerEnabled() {
+		w.Header().Add("WWW-Authenticate", bearer)
+	}
+	if s.basicAuth != nil {
+		w.Header().Add("WWW-Authenticate", fmt.Sprintf("Basic realm=%q, charset=\"UTF-8\"", authRealm))
+	}
+	w.WriteHeader(http.StatusUnauthorized)
+
+	return identity{}, false
+}
+
+// authorizeUnscoped is authorize for endpoints exposing all containers or
+// the exporter itself. Identities with a scope are answered with 403
+// Forbidden.
+func (s *handler) authorizeUnscoped(w http.ResponseWriter, r *http.Request) bool {
+	id, ok := s.authorize(w, r)
+	if !ok {
+		return false
+	}
+
+	if id.scope != nil {
+		log.WithField("remote", r.RemoteAddr).WithField("path", r.URL.Path).WithField("identity", id.name).
+			Debug("rejected request of a scoped identity")
+		http.Error(w, "forbidden for scoped credentials", http.StatusForbidden)
+
+		return false
+	}
+
+	return true
+}
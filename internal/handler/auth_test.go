@@ -0,0 +1,229 @@
+package handler_test
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidborzek/docker-exporter/internal/handler"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeTokenFile writes a token file and moves its modification time, so a
+// rewrite within the resolution of the file system is noticed.
+func writeTokenFile(t *testing.T, path, content string, modTime time.Time) {
+	t.Helper()
+
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	require.NoError(t, os.Chtimes(path, modTime, modTime))
+}
+
+func TestAuthenticationParsesAuthorizationHeader(t *testing.T) {
+	h := handler.New(prometheus.NewRegistry(), authToken)
+
+	tests := []struct {
+		name          string
+		authorization string
+		wantCode      int
+		wantChallenge string
+	}{
+		{
+			name:          "valid",
+			authorization: "Bearer " + authToken,
+			wantCode:      http.StatusOK,
+		},
+		{
+			name:          "case-insensitive scheme",
+			authorization: "bearer " + authToken,
+			wantCode:      http.StatusOK,
+		},
+		{
+			name:          "missing",
+			wantCode:      http.StatusUnauthorized,
+			wantChallenge: `Bearer realm="docker-exporter"`,
+		},
+		{
+			name:          "without scheme",
+			authorization: authToken,
+			wantCode:      http.StatusUnauthorized,
+			wantChallenge: `Bearer realm="docker-exporter", error="invalid_request"`,
+		},
+		{
+			name:          "other scheme",
+			authorization: "Token " + authToken,
+			wantCode:      http.StatusUnauthorized,
+			wantChallenge: `Bearer realm="docker-exporter", error="invalid_request"`,
+		},
+		{
+			name:          "scheme repeated",
+			authorization: "Bearer Bearer " + authToken,
+			wantCode:      http.StatusUnauthorized,
+			wantChallenge: `Bearer realm="docker-exporter", error="invalid_request"`,
+		},
+		{
+			name:          "token with suffix",
+			authorization: "Bearer " + authToken + "x",
+			wantCode:      http.StatusUnauthorized,
+			wantChallenge: `Bearer realm="docker-exporter", error="invalid_token"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := serve(h, "/metrics", withAuthorization(tt.authorization))
+
+			assert.Equal(t, tt.wantCode, rr.Code)
+			assert.Equal(t, tt.wantChallenge, rr.Header().Get("WWW-Authenticate"))
+		})
+	}
+}
+
+func TestAuthenticationCountsFailures(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	h := handler.New(prometheus.NewRegistry(), authToken, handler.WithRegisterer(registry))
+
+	serve(h, "/metrics")
+	serve(h, "/metrics", withAuthorization("Basic dXNlcjpwYXNz"))
+	serve(h, "/metrics", withAuthorization("Bearer invalidToken"))
+	serve(h, "/metrics", withAuthorization("Bearer invalidToken"))
+	serve(h, "/metrics", withAuthorization("Bearer "+authToken))
+
+	const expected = `
+	# HELP docker_exporter_http_auth_failures_total Total number of HTTP requests rejected because of missing or invalid credentials
+	# TYPE docker_exporter_http_auth_failures_total counter
+	docker_exporter_http_auth_failures_total{reason="invalid"} 2
+	docker_exporter_http_auth_failures_total{reason="malformed"} 1
+	docker_exporter_http_auth_failures_total{reason="missing"} 1
+	`
+
+	assert.NoError(t, testutil.GatherAndCompare(registry, strings.NewReader(expected)))
+}
+
+func TestAuthenticationWithTokenFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tokens.yaml")
+	now := time.Now()
+	writeTokenFile(t, path, `
+tokens:
+  - name: prometheus
+    token: first
+  - name: grafana
+    token: second
+`, now)
+
+	tokens, err := handler.LoadTokenFile(path)
+	require.NoError(t, err)
+
+	h := handler.New(prometheus.NewRegistry(), authToken, handler.WithTokenFile(tokens))
+
+	assert.Equal(t, http.StatusOK, serve(h, "/metrics", withAuthorization("Bearer "+authToken)).Code)
+	assert.Equal(t, http.StatusOK, serve(h, "/metrics", withAuthorization("Bearer first")).Code)
+	assert.Equal(t, http.StatusOK, serve(h, "/metrics", withAuthorization("Bearer second")).Code)
+
+	// Rotate the token of prometheus.
+	writeTokenFile(t, path, `
+tokens:
+  - name: prometheus
+    token: rotated
+  - name: grafana
+    token: second
+`, now.Add(time.Minute))
+
+	assert.Equal(t, http.StatusUnauthorized, serve(h, "/metrics", withAuthorization("Bearer first")).Code)
+	assert.Equal(t, http.StatusOK, serve(h, "/metrics", withAuthorization("Bearer rotated")).Code)
+
+	// A broken file revokes the previous tokens, but not the static one.
+	writeTokenFile(t, path, "tokens: [", now.Add(2*time.Minute))
+
+	assert.Equal(t, http.StatusUnauthorized, serve(h, "/metrics", withAuthorization("Bearer rotated")).Code)
+	assert.Equal(t, http.StatusOK, serve(h, "/metrics", withAuthorization("Bearer "+authToken)).Code)
+}
+
+func TestTokenFileRevokesTokensWhenUnreadable(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tokens.yaml")
+	now := time.Now()
+	writeTokenFile(t, path, "tokens:\n  - name: prometheus\n    token: first\n", now)
+
+	tokens, err := handler.LoadTokenFile(path)
+	require.NoError(t, err)
+
+	h := handler.New(prometheus.NewRegistry(), "", handler.WithTokenFile(tokens))
+	assert.Equal(t, http.StatusOK, serve(h, "/metrics", withAuthorization("Bearer first")).Code)
+
+	// A removed file revokes its tokens, without disabling authentication.
+	require.NoError(t, os.Remove(path))
+	assert.Equal(t, http.StatusUnauthorized, serve(h, "/metrics", withAuthorization("Bearer first")).Code)
+	assert.Equal(t, http.StatusUnauthorized, serve(h, "/metrics").Code)
+
+	writeTokenFile(t, path, "tokens:\n  - name: prometheus\n    token: first\n", now.Add(time.Minute))
+	assert.Equal(t, http.StatusOK, serve(h, "/metrics", withAuthorization("Bearer first")).Code)
+
+	// So does an emptied one.
+	writeTokenFile(t, path, "", now.Add(2*time.Minute))
+	assert.Equal(t, http.StatusUnauthorized, serve(h, "/metrics", withAuthorization("Bearer first")).Code)
+}
+
+func TestTokenFileEnablesAuthentication(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tokens.yaml")
+	writeTokenFile(t, path, "tokens:\n  - name: prometheus\n    token: first\n", time.Now())
+
+	tokens, err := handler.LoadTokenFile(path)
+	require.NoError(t, err)
+
+	h := handler.New(prometheus.NewRegistry(), "", handler.WithTokenFile(tokens))
+
+	assert.Equal(t, http.StatusUnauthorized, serve(h, "/metrics").Code)
+	assert.Equal(t, http.StatusOK, serve(h, "/metrics", withAuthorization("Bearer first")).Code)
+}
+
+func TestLoadTokenFileErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{
+			name:    "unknown key",
+			content: "tokens:\n  - name: prometheus\n    secret: first\n",
+			wantErr: "field secret not found",
+		},
+		{
+			name:    "missing name",
+			content: "tokens:\n  - token: first\n",
+			wantErr: "token 0: name is required",
+		},
+		{
+			name:    "missing token",
+			content: "tokens:\n  - name: prometheus\n",
+			wantErr: `token "prometheus": token is required`,
+		},
+		{
+			name:    "duplicate name",
+			content: "tokens:\n  - name: prometheus\n    token: first\n  - name: prometheus\n    token: second\n",
+			wantErr: `token "prometheus": duplicate name`,
+		},
+		{
+			name:    "invalid scope",
+			content: "tokens:\n  - name: prometheus\n    token: first\n    scope:\n      label_selector: '=prod'\n",
+			wantErr: `token "prometheus": invalid scope`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "tokens.yaml")
+			writeTokenFile(t, path, tt.content, time.Now())
+
+			_, err := handler.LoadTokenFile(path)
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+
+	_, err := handler.LoadTokenFile(filepath.Join(t.TempDir(), "missing.yaml"))
+	assert.ErrorContains(t, err, "no such file or directory")
+}
@@ -0,0 +1,91 @@
+package handler
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// maxBasicAuthCache bounds the number of verified credentials kept, so
+// repeated scrapes do not pay for a bcrypt comparison every time.
+const maxBasicAuthCache = 100
+
+// dummyHash is compared against for unknown users, so the response time
+// does not tell whether a user exists.
+var dummyHash = sync.OnceValue(func() []byte {
+	hash, err := bcrypt.GenerateFromPassword([]byte("docker-exporter"), bcrypt.DefaultCost)
+	if err != nil {
+		panic(err)
+	}
+
+	return hash
+})
+
+// ValidateBasicAuthUsers returns an error if the password hash of a user is
+// not a bcrypt hash.
+func ValidateBasicAuthUsers(users map[string]string) error {
+	for _, name := range slices.Sorted(maps.Keys(users)) {
+		if name == "" {
+			return errors.New("user name must not be empty")
+		}
+
+		if _, err := bcrypt.Cost([]byte(users[name])); err != nil {
+			return fmt.Errorf("user %q: invalid bcrypt hash: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+// basicAuth verifies HTTP basic auth credentials against bcrypt hashes.
+type basicAuth struct {
+	users map[string]string
+
+	mu       sync.Mutex
+	verified map[[sha256.Size]byte]bool
+}
+
+func newBasicAuth(users map[string]string) *basicAuth {
+	return &basicAuth{
+		users:    users,
+		verified: make(map[[sha256.Size]byte]bool),
+	}
+}
+
+// verify reports whether password is the password of user.
+func (b *basicAuth) verify(user, password string) bool {
+	hash, ok := b.users[user]
+	if !ok {
+		_ = bcrypt.CompareHashAndPassword(dummyHash(), []byte(password))
+		return false
+	}
+
+	// The hash is part of the key, so changed users are verified again.
+	key := sha256.Sum256([]byte(user + "\x00" + password + "\x00" + hash))
+
+	b.mu.Lock()
+	verified := b.verified[key]
+	b.mu.Unlock()
+
+	if verified {
+		return true
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) != nil {
+		return false
+	}
+
+	b.mu.Lock()
+	if len(b.verified) >= maxBasicAuthCache {
+		clear(b.verified)
+	}
+	b.verified[key] = true
+	b.mu.Unlock()
+
+	return true
+}
@@ -0,0 +1,110 @@
+package handler_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/davidborzek/docker-exporter/internal/handler"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func basicAuthUsers(t *testing.T) map[string]string {
+	t.Helper()
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	require.NoError(t, err)
+
+	return map[string]string{"prometheus": string(hash)}
+}
+
+func TestBasicAuth(t *testing.T) {
+	h := handler.New(prometheus.NewRegistry(), "", handler.WithBasicAuthUsers(basicAuthUsers(t)))
+
+	tests := []struct {
+		name          string
+		user          string
+		password      string
+		authorization string
+		wantCode      int
+	}{
+		{
+			name:     "valid",
+			user:     "prometheus",
+			password: "secret",
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "wrong password",
+			user:     "prometheus",
+			password: "wrong",
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			name:     "unknown user",
+			user:     "grafana",
+			password: "secret",
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			name:          "bearer token",
+			authorization: "Bearer secret",
+			wantCode:      http.StatusUnauthorized,
+		},
+		{
+			name:          "not base64",
+			authorization: "Basic secret!",
+			wantCode:      http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", "/metrics", nil)
+			if tt.user != "" {
+				req.SetBasicAuth(tt.user, tt.password)
+			}
+
+			authorization := tt.authorization
+			if authorization == "" {
+				authorization = req.Header.Get("Authorization")
+			}
+
+			rr := serve(h, "/metrics", withAuthorization(authorization))
+
+			assert.Equal(t, tt.wantCode, rr.Code)
+			if tt.wantCode == http.StatusUnauthorized {
+				assert.Equal(t, []string{`Basic realm="docker-exporter", charset="UTF-8"`},
+					rr.Header().Values("WWW-Authenticate"))
+			}
+		})
+	}
+}
+
+func TestBasicAuthAlongsideBearerToken(t *testing.T) {
+	h := handler.New(prometheus.NewRegistry(), authToken, handler.WithBasicAuthUsers(basicAuthUsers(t)))
+
+	req, _ := http.NewRequest("GET", "/metrics", nil)
+	req.SetBasicAuth("prometheus", "secret")
+
+	assert.Equal(t, http.StatusOK, serve(h, "/metrics", withAuthorization(req.Header.Get("Authorization"))).Code)
+	assert.Equal(t, http.StatusOK, serve(h, "/metrics", withAuthorization("Bearer "+authToken)).Code)
+
+	rr := serve(h, "/metrics")
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	assert.Equal(t, []string{
+		`Bearer realm="docker-exporter"`,
+		`Basic realm="docker-exporter", charset="UTF-8"`,
+	}, rr.Header().Values("WWW-Authenticate"))
+}
+
+func TestValidateBasicAuthUsers(t *testing.T) {
+	assert.NoError(t, handler.ValidateBasicAuthUsers(basicAuthUsers(t)))
+
+	assert.EqualError(t, handler.ValidateBasicAuthUsers(map[string]string{"": "x"}),
+		"user name must not be empty")
+	assert.ErrorContains(t, handler.ValidateBasicAuthUsers(map[string]string{"prometheus": "secret"}),
+		`user "prometheus": invalid bcrypt hash`)
+}
@@ -1,26 +1,256 @@
 package handler
 
 import (
+	"context"
 	"net/http"
+
+	"github.com/davidborzek/docker-exporter/internal/clock"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Reasons of requests rejected before they are served, as reported in the
+// reason label of the rejected requests counter.
+const (
+	rejectedDenied         = "denied"
+	rejectedRateLimited    = "rate_limited"
+	rejectedTooManyScrapes = "too_many_scrapes"
 )
 
 type handler struct {
 	expectedToken string
+	tokenFile     *TokenFile
+	basicAuth     *basicAuth
+	jwt           *JWTValidator
+	authFailures  *prometheus.CounterVec
+	access        *AccessList
+	clock         clock.Clock
+	limits        LimitConfig
+	rateLimiter   *rateLimiter
+	scrapes       chan struct{}
+	rejected      *prometheus.CounterVec
+	gatherer      prometheus.Gatherer
+	collector     func(ctx context.Context) prometheus.Collector
+	exporter      prometheus.Gatherer
+	prober        Prober
+	scoper        Scoper
+	reload        func() error
+	checks        []Check
+	readiness     *readiness
+	constLabels   prometheus.Labels
+	routePrefix   string
+	metricsPath   string
+	version       string
 	mux           *http.ServeMux
 }
 
-func New(authToken string) *handler {
+// Option configures optional handler features.
+type Option func(*handler)
+
+// WithProber enables the /probe endpoint backed by p.
+func WithProber(p Prober) Option {
+	return func(s *handler) {
+		s.prober = p
+	}
+}
+
+// WithCollector serves the metrics of the collector returned by collect on
+// /metrics next to those of the gatherer. It is built for each scrape with
+// the context of its request, so the scrape stops once the client goes away.
+func WithCollector(collect func(ctx context.Context) prometheus.Collector) Option {
+	return func(s *handler) {
+		s.collector = collect
+	}
+}
+
+// WithScoper serves identities with a scope, e.g. tokens of a token file
+// with a scope, the metrics of the containers in their scope on /metrics.
+// Without a scoper, they are rejected.
+func WithScoper(sc Scoper) Option {
+	return func(s *handler) {
+		s.scoper = sc
+	}
+}
+
+// WithTokenFile accepts the bearer tokens of f next to the auth token.
+func WithTokenFile(f *TokenFile) Option {
+	return func(s *handler) {
+		s.tokenFile = f
+	}
+}
+
+// WithJWTValidator accepts bearer tokens which are JWTs valid according to v,
+// next to the auth token and the tokens of a token file.
+func WithJWTValidator(v *JWTValidator) Option {
+	return func(s *handler) {
+		s.jwt = v
+	}
+}
+
+// WithBasicAuthUsers accepts HTTP basic auth for users, which maps the user
+// names to bcrypt hashes of their passwords like the web config of the
+// Prometheus exporter toolkit. The hashes must be valid, see
+// ValidateBasicAuthUsers.
+func WithBasicAuthUsers(users map[string]string) Option {
+	return func(s *handler) {
+		if len(users) > 0 {
+			s.basicAuth = newBasicAuth(users)
+		}
+	}
+}
+
+// WithAccessList rejects clients not allowed by a with 403 Forbidden on
+// every endpoint but /health and /ready.
+func WithAccessList(a *AccessList) Option {
+	return func(s *handler) {
+		s.access = a
+	}
+}
+
+// WithLimits limits the rate of scrapes of /metrics per client and the
+// number of concurrent scrapes. Exceeding them is answered with 429 Too Many
+// Requests and 503 Service Unavailable.
+func WithLimits(c LimitConfig) Option {
+	return func(s *handler) {
+		s.limits = c
+	}
+}
+
+// WithClock sets the clock of the rate limits and of the cached results of
+// the readiness checks. It defaults to the system clock.
+func WithClock(clk clock.Clock) Option {
+	return func(s *handler) {
+		s.clock = clk
+	}
+}
+
+// WithReadinessChecks adds checks to /ready and to the details of
+// /health?format=json.
+func WithReadinessChecks(checks ...Check) Option {
+	return func(s *handler) {
+		s.checks = append(s.checks, checks...)
+	}
+}
+
+// WithRegisterer registers the metrics of the handler itself, e.g. the
+// failed authentication attempts, with r.
+func WithRegisterer(r prometheus.Registerer) Option {
+	return func(s *handler) {
+		r.MustRegister(s.authFailures, s.rejected)
+	}
+}
+
+// WithExporterMetrics enables the /metrics/exporter endpoint serving the
+// metrics of g, so the exporter's own metrics can be scraped apart from the
+// container metrics.
+func WithExporterMetrics(g prometheus.Gatherer) Option {
+	return func(s *handler) {
+		s.exporter = g
+	}
+}
+
+// WithConstLabels adds labels to every metric served by /probe and to the
+// scoped metrics of /metrics. The other metrics of /metrics are labelled by
+// wrapping the registerer instead.
+func WithConstLabels(labels map[string]string) Option {
+	return func(s *handler) {
+		s.constLabels = labels
+	}
+}
+
+// WithRoutePrefix serves every endpoint below prefix, e.g. /metrics on
+// /docker-exporter/metrics, for reverse proxies routing by path. The prefix
+// is normalized by NormalizeRoutePrefix.
+func WithRoutePrefix(prefix string) Option {
+	return func(s *handler) {
+		s.routePrefix = NormalizeRoutePrefix(prefix)
+	}
+}
+
+// WithMetricsPath serves the container metrics on path, below the route
+// prefix, instead of DefaultMetricsPath. The path must be valid, see
+// ValidateMetricsPath.
+func WithMetricsPath(path string) Option {
+	return func(s *handler) {
+		if path != "" {
+			s.metricsPath = path
+		}
+	}
+}
+
+// WithVersion names the version of the exporter on the landing page and
+// /version.
+func WithVersion(version string) Option {
+	return func(s *handler) {
+		s.version = version
+	}
+}
+
+// WithReloader enables the /-/reload endpoint, which calls reload.
+func WithReloader(reload func() error) Option {
+	return func(s *handler) {
+		s.reload = reload
+	}
+}
+
+// New creates the exporter's HTTP handler serving the metrics of gatherer on
+// /metrics.
+func New(gatherer prometheus.Gatherer, authToken string, opts ...Option) *handler {
 	s := &handler{
 		expectedToken: authToken,
-		mux:           http.NewServeMux(),
+		gatherer:      gatherer,
+		authFailures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "docker_exporter_http_auth_failures_total",
+			Help: "Total number of HTTP requests rejected because of missing or invalid credentials",
+		}, []string{"reason"}),
+		rejected: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "docker_exporter_http_requests_rejected_total",
+			Help: "Total number of HTTP requests rejected because of the access list or the limits",
+		}, []string{"reason"}),
+		clock:       clock.NewClock(),
+		metricsPath: DefaultMetricsPath,
+		mux:         http.NewServeMux(),
+	}
+
+	for _, opt := range opts {
+		opt(s)
 	}
 
-	s.mux.HandleFunc("/metrics", s.handleMetrics())
-	s.mux.HandleFunc("/health", s.handleHealth())
+	if s.limits.RateLimit > 0 {
+		s.rateLimiter = newRateLimiter(s.clock, s.limits.RateLimit, s.limits.RateBurst)
+	}
+	if s.limits.MaxConcurrentScrapes > 0 {
+		s.scrapes = make(chan struct{}, s.limits.MaxConcurrentScrapes)
+	}
+
+	s.readiness = newReadiness(s.clock, s.checks)
+
+	s.mux.HandleFunc(s.route("/"), s.handleLanding())
+	s.mux.HandleFunc(s.route(s.metricsPath), s.handleMetrics(s.gatherer))
+	s.mux.HandleFunc(s.route("/health"), s.handleHealth())
+	s.mux.HandleFunc(s.route("/ready"), s.handleReady())
+	s.mux.HandleFunc(s.route("/version"), s.handleVersion())
+
+	if s.exporter != nil {
+		s.mux.HandleFunc(s.route("/metrics/exporter"), s.handleExporterMetrics(s.exporter))
+	}
+
+	if s.prober != nil {
+		s.mux.HandleFunc(s.route("/probe"), s.handleProbe())
+	}
+
+	if s.reload != nil {
+		s.mux.HandleFunc(s.route("/-/reload"), s.handleReload())
+	}
 
 	return s
 }
 
 func (s *handler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	// Probes of the orchestrator are not restricted. The details of
+	// /health?format=json check the client themselves.
+	if r.URL.Path != s.route("/health") && r.URL.Path != s.route("/ready") && !s.allowClient(rw, r) {
+		return
+	}
+
 	s.mux.ServeHTTP(rw, r)
 }
@@ -5,14 +5,29 @@ import (
 	"time"
 )
 
-// handleHealth is a basic health route handler.
-func (*handler) handleHealth() func(w http.ResponseWriter, r *http.Request) {
+// disableCaching tells clients and proxies not to cache the response.
+func disableCaching(w http.ResponseWriter) {
+	w.Header().Set("Expires", time.Unix(0, 0).Format(time.RFC1123))
+	w.Header().Set("Cache-Control", "no-cache, private, max-age=0")
+	w.Header().Set("Pragma", "no-cache")
+	w.Header().Set("X-Accel-Expires", "0")
+}
+
+// handleHealth is a basic health route handler. With format=json, it serves
+// the results of the readiness checks, which requires credentials when
+// authentication is enabled.
+func (s *handler) handleHealth() func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
-		// Disable caching
-		w.Header().Set("Expires", time.Unix(0, 0).Format(time.RFC1123))
-		w.Header().Set("Cache-Control", "no-cache, private, max-age=0")
-		w.Header().Set("Pragma", "no-cache")
-		w.Header().Set("X-Accel-Expires", "0")
+		disableCaching(w)
+
+		if r.URL.Query().Get("format") == "json" {
+			if !s.allowClient(w, r) || !s.authorizeUnscoped(w, r) {
+				return
+			}
+
+			s.writeHealthDetails(w, r)
+			return
+		}
 
 		w.WriteHeader(http.StatusOK)
 	}
@@ -6,6 +6,7 @@ import (
 	"testing"
 
 	"github.com/davidborzek/docker-exporter/internal/handler"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -16,7 +17,7 @@ func TestHealthHandler(t *testing.T) {
 	}
 
 	rr := httptest.NewRecorder()
-	h := handler.New("")
+	h := handler.New(prometheus.NewRegistry(), "")
 
 	h.ServeHTTP(rr, req)
 
@@ -0,0 +1,218 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/davidborzek/docker-exporter/internal/filestate"
+	"github.com/go-jose/go-jose/v4"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	// jwksRefreshInterval is how long keys fetched from a JWKS URL are used
+	// before they are fetched again.
+	jwksRefreshInterval = 5 * time.Minute
+	// jwksMinRefreshInterval limits how often a token signed by an unknown
+	// key triggers fetching the keys again.
+	jwksMinRefreshInterval = 10 * time.Second
+	// maxJWKSSize bounds the size of a JWKS response.
+	maxJWKSSize = 1 << 20
+)
+
+// keySource provides the keys verifying JWT signatures.
+type keySource interface {
+	// keys returns the current keys. refresh asks for the keys to be fetched
+	// again if possible, because a token was signed by an unknown key.
+	keys(refresh bool) ([]jose.JSONWebKey, error)
+}
+
+// parseJWKS parses a JSON Web Key Set. Keys not used for signatures and keys
+// of unsupported types are skipped; of private keys, the public key is used.
+func parseJWKS(raw []byte) ([]jose.JSONWebKey, error) {
+	var set jose.JSONWebKeySet
+	if err := json.Unmarshal(raw, &set); err != nil {
+		return nil, err
+	}
+
+	var keys []jose.JSONWebKey
+	for _, key := range set.Keys {
+		if key.Use != "" && key.Use != "sig" {
+			continue
+		}
+
+		// Symmetric keys have no public key.
+		if public := key.Public(); public.Valid() {
+			keys = append(keys, public)
+		}
+	}
+
+	if len(keys) == 0 {
+		return nil, errors.New("no signing keys found")
+	}
+
+	return keys, nil
+}
+
+// jwksFile holds the keys of a JWKS file, which is read again once it
+// changes.
+type jwksFile struct {
+	path string
+
+	mu    sync.Mutex
+	state filestate.State
+	set   []jose.JSONWebKey
+}
+
+func (f *jwksFile) keys(bool) ([]jose.JSONWebKey, error) {
+	if err := f.reload(); err != nil {
+		log.WithError(err).WithField("path", f.path).
+			Warn("failed to reload JWKS file - keeping the previous keys")
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.set, nil
+}
+
+func (f *jwksFile) reload() error {
+	state, err := filestate.Of(f.path)
+	if err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.state != nil && state.Equal(f.state) {
+		return nil
+	}
+
+	raw, err := os.ReadFile(f.path)
+	if err != nil {
+		return err
+	}
+
+	set, err := parseJWKS(raw)
+	if err != nil {
+		return fmt.Errorf("invalid JWKS file %q: %w", f.path, err)
+	}
+
+	if f.state != nil {
+		log.WithField("path", f.path).
+			Info("JWKS file reloaded")
+	}
+
+	f.state, f.set = state, set
+
+	return nil
+}
+
+// jwksURL holds the keys fetched from a JWKS URL. They are fetched when
+// first needed and again after jwksRefreshInterval, or earlier when a token
+// is signed by an unknown key. When fetching fails, the previous keys stay in
+// use. Failed fetches are retried after jwksMinRefreshInterval at the
+// earliest.
+//
+// Only one fetch runs at a time, without holding the lock. Tokens of known
+// keys are validated with the previous keys while the keys are fetched again.
+type jwksURL struct {
+	url    string
+	client *http.Client
+	fetch  singleflight.Group
+
+	mu      sync.Mutex
+	fetched time.Time
+	set     []jose.JSONWebKey
+	err     error
+}
+
+func (u *jwksURL) keys(refresh bool) ([]jose.JSONWebKey, error) {
+	u.mu.Lock()
+	set, fetched, err := u.set, u.fetched, u.err
+	u.mu.Unlock()
+
+	// Without keys, fetching is retried like for an unknown key.
+	refresh = refresh || set == nil
+
+	age := time.Since(fetched)
+	if !fetched.IsZero() && age < jwksRefreshInterval && (!refresh || age < jwksMinRefreshInterval) {
+		if set == nil {
+			return nil, err
+		}
+
+		return set, nil
+	}
+
+	done := u.fetch.DoChan("", func() (any, error) {
+		u.refresh()
+		return nil, nil
+	})
+	// The expired keys are used while the keys are fetched again, unless the
+	// token is signed by a key missing from them.
+	if !refresh {
+		return set, nil
+	}
+	<-done
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if u.set == nil {
+		return nil, u.err
+	}
+
+	return u.set, nil
+}
+
+// refresh fetches the keys, keeping the previous ones on error.
+func (u *jwksURL) refresh() {
+	set, err := u.get()
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	u.fetched = time.Now()
+	if err != nil {
+		u.err = err
+		if u.set != nil {
+			log.WithError(err).WithField("url", u.url).
+				Warn("failed to fetch JWKS - keeping the previous keys")
+		}
+		return
+	}
+
+	u.set, u.err = set, nil
+}
+
+func (u *jwksURL) get() ([]jose.JSONWebKey, error) {
+	resp, err := u.client.Get(u.url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch JWKS: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch JWKS: unexpected status %s", resp.Status)
+	}
+
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, maxJWKSSize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch JWKS: %w", err)
+	}
+
+	set, err := parseJWKS(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid JWKS from %q: %w", u.url, err)
+	}
+
+	return set, nil
+}
@@ -0,0 +1,196 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+)
+
+// jwtLeeway is the clock skew tolerated when checking the expiry and the
+// not-before time of a JWT.
+const jwtLeeway = time.Minute
+
+// jwksFetchTimeout bounds fetching the keys from a JWKS URL.
+const jwksFetchTimeout = 10 * time.Second
+
+// jwtAlgorithms are the supported signature algorithms. Symmetric algorithms
+// are not supported, as the keys of a JWKS are public.
+var jwtAlgorithms = []jose.SignatureAlgorithm{
+	jose.RS256, jose.RS384, jose.RS512,
+	jose.PS256, jose.PS384, jose.PS512,
+	jose.ES256, jose.ES384, jose.ES512,
+	jose.EdDSA,
+}
+
+// JWTAlgorithms returns the names of the supported signature algorithms.
+func JWTAlgorithms() []string {
+	names := make([]string, 0, len(jwtAlgorithms))
+	for _, alg := range jwtAlgorithms {
+		names = append(names, string(alg))
+	}
+	slices.Sort(names)
+
+	return names
+}
+
+// JWTConfig configures the validation of signed JWTs presented as bearer
+// tokens.
+type JWTConfig struct {
+	// JWKSFile and JWKSURL are the JSON Web Key Set with the keys verifying
+	// the signatures. Exactly one of them must be set. The file is read again
+	// when it changes; the keys of the URL are fetched again periodically and
+	// when a token is signed by an unknown key.
+	JWKSFile string
+	JWKSURL  string
+	// Issuer, if set, must match the iss claim.
+	Issuer string
+	// Audiences, if set, must contain one of the values of the aud claim.
+	Audiences []string
+	// Algorithms restricts the accepted signature algorithms. Defaults to
+	// all supported algorithms, see JWTAlgorithms.
+	Algorithms []string
+}
+
+// JWTValidator validates signed JWTs against the keys of a JWKS.
+type JWTValidator struct {
+	config     JWTConfig
+	algorithms []jose.SignatureAlgorithm
+	keys       keySource
+}
+
+// NewJWTValidator creates a validator for config. A JWKS file is read
+// immediately, while the keys of a JWKS URL are fetched when the first token
+// is validated.
+func NewJWTValidator(config JWTConfig) (*JWTValidator, error) {
+	v := &JWTValidator{config: config, algorithms: jwtAlgorithms}
+
+	if len(config.Algorithms) > 0 {
+		v.algorithms = nil
+		for _, alg := range config.Algorithms {
+			if !slices.Contains(jwtAlgorithms, jose.SignatureAlgorithm(alg)) {
+				return nil, fmt.Errorf("unknown algorithm %q (valid: %s)", alg, strings.Join(JWTAlgorithms(), ", "))
+			}
+			v.algorithms = append(v.algorithms, jose.SignatureAlgorithm(alg))
+		}
+	}
+
+	switch {
+	case config.JWKSFile != "" && config.JWKSURL != "":
+		return nil, errors.New("JWKS file and JWKS URL cannot be set together")
+	case config.JWKSFile != "":
+		f := &jwksFile{path: config.JWKSFile}
+		if err := f.reload(); err != nil {
+			return nil, err
+		}
+		v.keys = f
+	case config.JWKSURL != "":
+		u, err := url.Parse(config.JWKSURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid JWKS URL: %w", err)
+		}
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return nil, fmt.Errorf("invalid JWKS URL %q: scheme must be http or https", config.JWKSURL)
+		}
+		v.keys = &jwksURL{url: config.JWKSURL, client: &http.Client{Timeout: jwksFetchTimeout}}
+	default:
+		return nil, errors.New("JWKS file or JWKS URL is required")
+	}
+
+	return v, nil
+}
+
+// Claims are the validated claims of a JWT.
+type Claims struct {
+	Subject   string
+	Issuer    string
+	Audience  []string
+	ExpiresAt time.Time
+}
+
+// looksLikeJWT reports whether a bearer token has the form of a signed JWT.
+func looksLikeJWT(token string) bool {
+	return strings.Count(token, ".") == 2
+}
+
+// Validate verifies the signature of token and checks its claims.
+func (v *JWTValidator) Validate(token string) (*Claims, error) {
+	tok, err := jwt.ParseSigned(token, v.algorithms)
+	if err != nil {
+		return nil, fmt.Errorf("invalid JWT: %w", err)
+	}
+
+	claims, err := v.verify(tok)
+	if err != nil {
+		return nil, err
+	}
+
+	if claims.Expiry == nil {
+		return nil, errors.New("JWT has no expiry")
+	}
+
+	if err := claims.ValidateWithLeeway(jwt.Expected{
+		Issuer:      v.config.Issuer,
+		AnyAudience: v.config.Audiences,
+		Time:        time.Now(),
+	}, jwtLeeway); err != nil {
+		return nil, err
+	}
+
+	return &Claims{
+		Subject:   claims.Subject,
+		Issuer:    claims.Issuer,
+		Audience:  claims.Audience,
+		ExpiresAt: claims.Expiry.Time(),
+	}, nil
+}
+
+// verify verifies the signature with the key named by the token's kid, or
+// with any key if the token names none, and returns its claims. A key with an
+// alg parameter only verifies signatures of that algorithm.
+func (v *JWTValidator) verify(tok *jwt.JSONWebToken) (*jwt.Claims, error) {
+	// ParseSigned only accepts tokens with a single signature.
+	header := tok.Headers[0]
+
+	for _, refresh := range []bool{false, true} {
+		keys, err := v.keys.keys(refresh)
+		if err != nil {
+			return nil, err
+		}
+
+		known := false
+		for _, key := range keys {
+			if (header.KeyID != "" && key.KeyID != header.KeyID) ||
+				(key.Algorithm != "" && key.Algorithm != header.Algorithm) {
+				continue
+			}
+			known = true
+
+			if err := tok.Claims(key.Key); err != nil {
+				continue
+			}
+
+			// The signature was verified above.
+			var claims jwt.Claims
+			if err := tok.UnsafeClaimsWithoutVerification(&claims); err != nil {
+				return nil, fmt.Errorf("invalid JWT claims: %w", err)
+			}
+
+			return &claims, nil
+		}
+
+		// Only a token signed by an unknown key may be signed by a key added
+		// to the JWKS since it was read.
+		if known {
+			break
+		}
+	}
+
+	return nil, errors.New("invalid JWT signature")
+}
@@ -0,0 +1,362 @@
+package handler_test
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/davidborzek/docker-exporter/internal/handler"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// signingKey is a locally generated key signing test JWTs.
+type signingKey struct {
+	kid string
+	alg string
+	key crypto.Signer
+}
+
+func newRSAKey(t *testing.T, kid string) signingKey {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	return signingKey{kid: kid, alg: "RS256", key: key}
+}
+
+func newECKey(t *testing.T, kid string) signingKey {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	return signingKey{kid: kid, alg: "ES256", key: key}
+}
+
+func newEd25519Key(t *testing.T, kid string) signingKey {
+	t.Helper()
+
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	return signingKey{kid: kid, alg: "EdDSA", key: key}
+}
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// jwk returns the public key in the JWK format.
+func (k signingKey) jwk() map[string]string {
+	switch pub := k.key.Public().(type) {
+	case *rsa.PublicKey:
+		return map[string]string{"kty": "RSA", "kid": k.kid, "n": b64(pub.N.Bytes()), "e": b64(big.NewInt(int64(pub.E)).Bytes())}
+	case *ecdsa.PublicKey:
+		raw, _ := pub.Bytes()
+		return map[string]string{"kty": "EC", "kid": k.kid, "crv": "P-256", "x": b64(raw[1:33]), "y": b64(raw[33:])}
+	case ed25519.PublicKey:
+		return map[string]string{"kty": "OKP", "kid": k.kid, "crv": "Ed25519", "x": b64(pub)}
+	}
+
+	panic("unsupported key")
+}
+
+// sign returns a JWT with the claims signed by the key.
+func (k signingKey) sign(t *testing.T, claims map[string]any) string {
+	t.Helper()
+
+	header, err := json.Marshal(map[string]string{"alg": k.alg, "kid": k.kid, "typ": "JWT"})
+	require.NoError(t, err)
+	payload, err := json.Marshal(claims)
+	require.NoError(t, err)
+
+	signed := b64(header) + "." + b64(payload)
+
+	var signature []byte
+	switch key := k.key.(type) {
+	case *rsa.PrivateKey:
+		digest := sha256.Sum256([]byte(signed))
+		signature, err = rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	case *ecdsa.PrivateKey:
+		digest := sha256.Sum256([]byte(signed))
+		var r, s *big.Int
+		r, s, err = ecdsa.Sign(rand.Reader, key, digest[:])
+		signature = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	case ed25519.PrivateKey:
+		signature = ed25519.Sign(key, []byte(signed))
+	}
+	require.NoError(t, err)
+
+	return signed + "." + b64(signature)
+}
+
+func jwksOf(t *testing.T, keys ...signingKey) []byte {
+	t.Helper()
+
+	set := struct {
+		Keys []map[string]string `json:"keys"`
+	}{}
+	for _, key := range keys {
+		set.Keys = append(set.Keys, key.jwk())
+	}
+
+	raw, err := json.Marshal(set)
+	require.NoError(t, err)
+
+	return raw
+}
+
+func writeJWKS(t *testing.T, keys ...signingKey) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	require.NoError(t, os.WriteFile(path, jwksOf(t, keys...), 0o600))
+
+	return path
+}
+
+func validClaims() map[string]any {
+	return map[string]any{
+		"sub": "prometheus",
+		"iss": "https://auth.example.com",
+		"aud": "docker-exporter",
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}
+}
+
+func withClaim(name string, value any) map[string]any {
+	claims := validClaims()
+	if value == nil {
+		delete(claims, name)
+	} else {
+		claims[name] = value
+	}
+
+	return claims
+}
+
+func TestJWTValidation(t *testing.T) {
+	rsaKey := newRSAKey(t, "rsa")
+	ecKey := newECKey(t, "ec")
+	edKey := newEd25519Key(t, "ed")
+	unknownKey := newECKey(t, "unknown")
+	// A key with the kid of a published key, but a different secret.
+	forgedKey := newRSAKey(t, "rsa")
+
+	validator, err := handler.NewJWTValidator(handler.JWTConfig{
+		JWKSFile:   writeJWKS(t, rsaKey, ecKey, edKey),
+		Issuer:     "https://auth.example.com",
+		Audiences:  []string{"docker-exporter", "metrics"},
+		Algorithms: []string{"RS256", "ES256", "EdDSA"},
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr string
+	}{
+		{name: "RS256", token: rsaKey.sign(t, validClaims())},
+		{name: "ES256", token: ecKey.sign(t, validClaims())},
+		{name: "EdDSA", token: edKey.sign(t, validClaims())},
+		{name: "audience list", token: rsaKey.sign(t, withClaim("aud", []string{"grafana", "metrics"}))},
+		{name: "expired within leeway", token: rsaKey.sign(t, withClaim("exp", time.Now().Add(-30*time.Second).Unix()))},
+		{
+			name:    "expired",
+			token:   rsaKey.sign(t, withClaim("exp", time.Now().Add(-time.Hour).Unix())),
+			wantErr: "token is expired (exp)",
+		},
+		{
+			name:    "without expiry",
+			token:   rsaKey.sign(t, withClaim("exp", nil)),
+			wantErr: "JWT has no expiry",
+		},
+		{
+			name:    "not yet valid",
+			token:   rsaKey.sign(t, withClaim("nbf", time.Now().Add(time.Hour).Unix())),
+			wantErr: "token not valid yet (nbf)",
+		},
+		{
+			name:    "other issuer",
+			token:   rsaKey.sign(t, withClaim("iss", "https://evil.example.com")),
+			wantErr: "invalid issuer claim (iss)",
+		},
+		{
+			name:    "other audience",
+			token:   rsaKey.sign(t, withClaim("aud", "grafana")),
+			wantErr: "invalid audience claim (aud)",
+		},
+		{
+			name:    "unknown key",
+			token:   unknownKey.sign(t, validClaims()),
+			wantErr: "invalid JWT signature",
+		},
+		{
+			name:    "forged key",
+			token:   forgedKey.sign(t, validClaims()),
+			wantErr: "invalid JWT signature",
+		},
+		{
+			name:    "algorithm not allowed",
+			token:   signingKey{kid: "rsa", alg: "none", key: rsaKey.key}.sign(t, validClaims()),
+			wantErr: `unexpected signature algorithm "none"`,
+		},
+		{
+			name:    "algorithm of another key type",
+			token:   signingKey{kid: "rsa", alg: "ES256", key: rsaKey.key}.sign(t, validClaims()),
+			wantErr: "invalid JWT signature",
+		},
+		{
+			name:    "malformed",
+			token:   "not.a-jwt",
+			wantErr: "invalid JWT",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := validator.Validate(tt.token)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, "prometheus", claims.Subject)
+		})
+	}
+}
+
+func TestJWTValidationRejectsTamperedClaims(t *testing.T) {
+	key := newRSAKey(t, "rsa")
+
+	validator, err := handler.NewJWTValidator(handler.JWTConfig{JWKSFile: writeJWKS(t, key)})
+	require.NoError(t, err)
+
+	token := key.sign(t, validClaims())
+	other := key.sign(t, withClaim("sub", "admin"))
+
+	// The claims of one token with the signature of another.
+	signed := strings.Split(other, ".")
+	signed[2] = strings.Split(token, ".")[2]
+
+	_, err = validator.Validate(strings.Join(signed, "."))
+	assert.ErrorContains(t, err, "invalid JWT signature")
+}
+
+func TestJWTAuthentication(t *testing.T) {
+	key := newECKey(t, "ec")
+
+	validator, err := handler.NewJWTValidator(handler.JWTConfig{
+		JWKSFile:  writeJWKS(t, key),
+		Audiences: []string{"docker-exporter"},
+	})
+	require.NoError(t, err)
+
+	registry := prometheus.NewRegistry()
+	h := handler.New(prometheus.NewRegistry(), authToken,
+		handler.WithJWTValidator(validator), handler.WithRegisterer(registry))
+
+	assert.Equal(t, http.StatusOK, serve(h, "/metrics", withAuthorization("Bearer "+key.sign(t, validClaims()))).Code)
+	assert.Equal(t, http.StatusOK, serve(h, "/metrics", withAuthorization("Bearer "+authToken)).Code)
+
+	rr := serve(h, "/metrics", withAuthorization("Bearer "+key.sign(t, withClaim("aud", "grafana"))))
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	assert.Equal(t, `Bearer realm="docker-exporter", error="invalid_token"`, rr.Header().Get("WWW-Authenticate"))
+}
+
+func TestJWTValidationFetchesJWKSURL(t *testing.T) {
+	first := newRSAKey(t, "first")
+	second := newRSAKey(t, "second")
+
+	var jwks atomic.Value
+	jwks.Store(jwksOf(t, first))
+
+	var fetches atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		fetches.Add(1)
+		_, _ = w.Write(jwks.Load().([]byte))
+	}))
+	t.Cleanup(srv.Close)
+
+	validator, err := handler.NewJWTValidator(handler.JWTConfig{JWKSURL: srv.URL})
+	require.NoError(t, err)
+	assert.Zero(t, fetches.Load(), "keys are fetched on first use")
+
+	_, err = validator.Validate(first.sign(t, validClaims()))
+	require.NoError(t, err)
+	_, err = validator.Validate(first.sign(t, validClaims()))
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), fetches.Load())
+
+	// A token of a key added since the keys were fetched is only accepted
+	// once the keys may be fetched again.
+	jwks.Store(jwksOf(t, first, second))
+
+	_, err = validator.Validate(second.sign(t, validClaims()))
+	assert.ErrorContains(t, err, "invalid JWT signature")
+	assert.Equal(t, int32(1), fetches.Load())
+}
+
+func TestNewJWTValidatorErrors(t *testing.T) {
+	jwksFile := writeJWKS(t, newECKey(t, "ec"))
+	emptyFile := filepath.Join(t.TempDir(), "empty.json")
+	require.NoError(t, os.WriteFile(emptyFile, []byte(`{"keys":[]}`), 0o600))
+
+	tests := []struct {
+		name    string
+		config  handler.JWTConfig
+		wantErr string
+	}{
+		{
+			name:    "no JWKS",
+			config:  handler.JWTConfig{Issuer: "https://auth.example.com"},
+			wantErr: "JWKS file or JWKS URL is required",
+		},
+		{
+			name:    "file and URL",
+			config:  handler.JWTConfig{JWKSFile: jwksFile, JWKSURL: "https://auth.example.com/jwks"},
+			wantErr: "JWKS file and JWKS URL cannot be set together",
+		},
+		{
+			name:    "URL scheme",
+			config:  handler.JWTConfig{JWKSURL: "file:///etc/jwks.json"},
+			wantErr: "scheme must be http or https",
+		},
+		{
+			name:    "unknown algorithm",
+			config:  handler.JWTConfig{JWKSFile: jwksFile, Algorithms: []string{"HS256"}},
+			wantErr: `unknown algorithm "HS256" (valid: ES256, ES384, ES512, EdDSA, PS256, PS384, PS512, RS256, RS384, RS512)`,
+		},
+		{
+			name:    "no keys",
+			config:  handler.JWTConfig{JWKSFile: emptyFile},
+			wantErr: "no signing keys found",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := handler.NewJWTValidator(tt.config)
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package handler
+
+import (
+	"math"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/davidborzek/docker-exporter/internal/clock"
+	"golang.org/x/time/rate"
+)
+
+// rateLimiterSweepInterval is how often the limiters of clients which have
+// not made requests for a while are dropped.
+const rateLimiterSweepInterval = time.Minute
+
+// LimitConfig limits the scrapes of /metrics, so a misconfigured scraper
+// cannot drive the container runtime hard.
+type LimitConfig struct {
+	// RateLimit is the number of requests per second a client may make on
+	// average. Zero disables rate limiting.
+	RateLimit float64
+	// RateBurst is the number of requests a client may make at once. It
+	// defaults to one.
+	RateBurst int
+	// MaxConcurrentScrapes is the number of scrapes served at the same time
+	// across all clients. Zero disables the limit.
+	MaxConcurrentScrapes int
+}
+
+// rateLimiter limits the rate of requests per client address.
+type rateLimiter struct {
+	clock clock.Clock
+	limit rate.Limit
+	burst int
+	// idle is how long a client must be idle until its limiter is full
+	// again, so it can be replaced by a new one.
+	idle time.Duration
+
+	mu        sync.Mutex
+	clients   map[netip.Addr]*clientLimiter
+	lastSweep time.Time
+}
+
+type clientLimiter struct {
+	limiter *rate.Limiter
+	seen    time.Time
+}
+
+func newRateLimiter(clk clock.Clock, perSecond float64, burst int) *rateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+
+	idle := time.Duration(float64(burst) / perSecond * float64(time.Second))
+
+	return &rateLimiter{
+		clock:   clk,
+		limit:   rate.Limit(perSecond),
+		burst:   burst,
+		idle:    max(idle, rateLimiterSweepInterval),
+		clients: make(map[netip.Addr]*clientLimiter),
+	}
+}
+
+// allow reports whether the client may make a request now, or else how long
+// it has to wait.
+func (l *rateLimiter) allow(client netip.Addr) (bool, time.Duration) {
+	now := l.clock.Now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Sub(l.lastSweep) >= rateLimiterSweepInterval {
+		for addr, c := range l.clients {
+			if now.Sub(c.seen) >= l.idle {
+				delete(l.clients, addr)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	c, ok := l.clients[client]
+	if !ok {
+		c = &clientLimiter{limiter: rate.NewLimiter(l.limit, l.burst)}
+		l.clients[client] = c
+	}
+	c.seen = now
+
+	reservation := c.limiter.ReserveN(now, 1)
+	if delay := reservation.DelayFrom(now); delay > 0 {
+		reservation.CancelAt(now)
+		return false, delay
+	}
+
+	return true, 0
+}
+
+// limitRate responds with 429 Too Many Requests when the client of r exceeds
+// the rate limit. It reports whether the request may be served.
+func (s *handler) limitRate(w http.ResponseWriter, r *http.Request) bool {
+	if s.rateLimiter == nil {
+		return true
+	}
+
+	// Clients without an IP address, e.g. of a unix socket, share a limit.
+	client, _ := s.access.clientAddr(r)
+
+	ok, retryAfter := s.rateLimiter.allow(client)
+	if ok {
+		return true
+	}
+
+	s.rejected.WithLabelValues(rejectedRateLimited).Inc()
+
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	http.Error(w, "rate limit exceeded", http.StatusTooManyRequests)
+
+	return false
+}
+
+// acquireScrape takes one of the concurrent scrapes, responding with 503
+// Service Unavailable when all are taken. It returns a function releasing
+// the scrape, or false when the request must not be served.
+func (s *handler) acquireScrape(w http.ResponseWriter) (func(), bool) {
+	if s.scrapes == nil {
+		return func() {}, true
+	}
+
+	select {
+	case s.scrapes <- struct{}{}:
+		return func() { <-s.scrapes }, true
+	default:
+		s.rejected.WithLabelValues(rejectedTooManyScrapes).Inc()
+
+		w.Header().Set("Retry-After", "1")
+		http.Error(w, "too many concurrent scrapes", http.StatusServiceUnavailable)
+
+		return nil, false
+	}
+}
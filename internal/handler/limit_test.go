@@ -0,0 +1,105 @@
+package handler_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidborzek/docker-exporter/internal/handler"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimitPerClient(t *testing.T) {
+	now := time.Now()
+
+	registry := prometheus.NewRegistry()
+	h := handler.New(prometheus.NewRegistry(), "",
+		handler.WithClock(newClock(t, &now)),
+		handler.WithLimits(handler.LimitConfig{RateLimit: 0.1, RateBurst: 2}),
+		handler.WithRegisterer(registry))
+
+	assert.Equal(t, http.StatusOK, serve(h, "/metrics", withRemoteAddr("10.0.0.1:5000")).Code)
+	assert.Equal(t, http.StatusOK, serve(h, "/metrics", withRemoteAddr("10.0.0.1:5001")).Code)
+
+	rr := serve(h, "/metrics", withRemoteAddr("10.0.0.1:5002"))
+
+	assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+	assert.Equal(t, "10", rr.Header().Get("Retry-After"))
+
+	// Other clients have a limit of their own, and other endpoints are not
+	// limited.
+	assert.Equal(t, http.StatusOK, serve(h, "/metrics", withRemoteAddr("10.0.0.2:5000")).Code)
+	assert.Equal(t, http.StatusOK, serve(h, "/health", withRemoteAddr("10.0.0.1:5000")).Code)
+
+	const expected = `
+	# HELP docker_exporter_http_requests_rejected_total Total number of HTTP requests rejected because of the access list or the limits
+	# TYPE docker_exporter_http_requests_rejected_total counter
+	docker_exporter_http_requests_rejected_total{reason="rate_limited"} 1
+	`
+
+	assert.NoError(t, testutil.GatherAndCompare(registry, strings.NewReader(expected),
+		"docker_exporter_http_requests_rejected_total"))
+
+	// The client may make another request once its limit refilled.
+	now = now.Add(9 * time.Second)
+	assert.Equal(t, http.StatusTooManyRequests, serve(h, "/metrics", withRemoteAddr("10.0.0.1:5000")).Code)
+
+	now = now.Add(time.Second)
+	assert.Equal(t, http.StatusOK, serve(h, "/metrics", withRemoteAddr("10.0.0.1:5000")).Code)
+	assert.Equal(t, http.StatusTooManyRequests, serve(h, "/metrics", withRemoteAddr("10.0.0.1:5000")).Code)
+}
+
+func TestRateLimitUsesForwardedClient(t *testing.T) {
+	access, err := handler.NewAccessList(handler.AccessConfig{TrustedProxies: []string{"172.16.0.1"}})
+	assert.NoError(t, err)
+
+	now := time.Now()
+	h := handler.New(prometheus.NewRegistry(), "",
+		handler.WithClock(newClock(t, &now)),
+		handler.WithAccessList(access),
+		handler.WithLimits(handler.LimitConfig{RateLimit: 0.1}))
+
+	assert.Equal(t, http.StatusOK, serve(h, "/metrics", withRemoteAddr("172.16.0.1:5000"), withForwardedFor("10.0.0.1")).Code)
+	assert.Equal(t, http.StatusOK, serve(h, "/metrics", withRemoteAddr("172.16.0.1:5000"), withForwardedFor("10.0.0.2")).Code)
+	assert.Equal(t, http.StatusTooManyRequests, serve(h, "/metrics", withRemoteAddr("172.16.0.1:5000"), withForwardedFor("10.0.0.1")).Code)
+}
+
+// blockingGatherer blocks every gather until release is closed.
+type blockingGatherer struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (g blockingGatherer) Gather() ([]*dto.MetricFamily, error) {
+	g.started <- struct{}{}
+	<-g.release
+
+	return nil, nil
+}
+
+func TestMaxConcurrentScrapes(t *testing.T) {
+	g := blockingGatherer{started: make(chan struct{}), release: make(chan struct{})}
+	h := handler.New(g, "", handler.WithLimits(handler.LimitConfig{MaxConcurrentScrapes: 1}))
+
+	done := make(chan int)
+	go func() {
+		done <- serve(h, "/metrics", withRemoteAddr("10.0.0.1:5000")).Code
+	}()
+	<-g.started
+
+	rr := serve(h, "/metrics")
+
+	assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+	assert.Equal(t, "1", rr.Header().Get("Retry-After"))
+
+	close(g.release)
+	assert.Equal(t, http.StatusOK, <-done)
+
+	// The scrape is released once it is served.
+	go func() { <-g.started }()
+	assert.Equal(t, http.StatusOK, serve(h, "/metrics", withRemoteAddr("10.0.0.1:5000")).Code)
+}
@@ -1,38 +1,80 @@
 package handler
 
 import (
-	"errors"
+	"context"
 	"net/http"
-	"strings"
 
+	"github.com/davidborzek/docker-exporter/internal/collector"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
-// authenticate authenticates a request when a token is configured.
-func (s *handler) authenticate(r *http.Request) error {
-	if len(s.expectedToken) == 0 {
-		return nil
-	}
+// Scoper builds collectors of the containers visible to a scoped identity.
+type Scoper interface {
+	// Scoped returns a collector of the metrics of the containers matching
+	// scope, which stops collecting when ctx is done.
+	Scoped(ctx context.Context, scope *collector.Filter) prometheus.Collector
+}
 
-	token := strings.ReplaceAll(
-		r.Header.Get("Authorization"),
-		"Bearer ", "")
+// handleMetrics is a prometheus metrics handler serving the metrics of g and
+// those of the collector, collected for the request.
+// Identities with a scope are served the metrics of the containers in their
+// scope instead, collected for the request. Scrapes are subject to the
+// limits.
+func (s *handler) handleMetrics(g prometheus.Gatherer) func(http.ResponseWriter, *http.Request) {
+	metrics := promhttp.HandlerFor(g, promhttp.HandlerOpts{})
 
-	if token != s.expectedToken {
-		return errors.New("authentication failed")
-	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !s.limitRate(w, r) {
+			return
+		}
+
+		id, ok := s.authorize(w, r)
+		if !ok {
+			return
+		}
+
+		release, ok := s.acquireScrape(w)
+		if !ok {
+			return
+		}
+		defer release()
 
-	return nil
+		if id.scope == nil {
+			if s.collector == nil {
+				metrics.ServeHTTP(w, r)
+				return
+			}
+
+			registry := prometheus.NewRegistry()
+			prometheus.WrapRegistererWith(s.constLabels, registry).MustRegister(s.collector(r.Context()))
+
+			promhttp.HandlerFor(prometheus.Gatherers{g, registry}, promhttp.HandlerOpts{}).ServeHTTP(w, r)
+			return
+		}
+
+		if s.scoper == nil {
+			http.Error(w, "scoped credentials are not supported", http.StatusForbidden)
+			return
+		}
+
+		registry := prometheus.NewRegistry()
+		prometheus.WrapRegistererWith(s.constLabels, registry).MustRegister(s.scoper.Scoped(r.Context(), id.scope))
+
+		promhttp.HandlerFor(registry, promhttp.HandlerOpts{}).ServeHTTP(w, r)
+	}
 }
 
-// handleMetrics is a prometheus metrics handler.
-func (s *handler) handleMetrics() func(http.ResponseWriter, *http.Request) {
+// handleExporterMetrics serves the exporter's own metrics of g. They describe
+// all containers, so they are not served to identities with a scope.
+func (s *handler) handleExporterMetrics(g prometheus.Gatherer) func(http.ResponseWriter, *http.Request) {
+	metrics := promhttp.HandlerFor(g, promhttp.HandlerOpts{})
+
 	return func(w http.ResponseWriter, r *http.Request) {
-		if err := s.authenticate(r); err != nil {
-			w.WriteHeader(http.StatusUnauthorized)
+		if !s.authorizeUnscoped(w, r) {
 			return
 		}
 
-		promhttp.Handler().ServeHTTP(w, r)
+		metrics.ServeHTTP(w, r)
 	}
 }
@@ -1,12 +1,14 @@
 package handler_test
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
 	"github.com/davidborzek/docker-exporter/internal/handler"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -14,6 +16,44 @@ const (
 	authToken = "someToken"
 )
 
+// serve serves a GET request of path, modified by the options.
+func serve(h http.Handler, path string, opts ...func(*http.Request)) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	for _, opt := range opts {
+		opt(req)
+	}
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+
+	return rr
+}
+
+// withAuthorization sets the Authorization header, unless it is empty.
+func withAuthorization(authorization string) func(*http.Request) {
+	return func(req *http.Request) {
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+	}
+}
+
+// withRemoteAddr sets the address of the client.
+func withRemoteAddr(remoteAddr string) func(*http.Request) {
+	return func(req *http.Request) {
+		req.RemoteAddr = remoteAddr
+	}
+}
+
+// withForwardedFor sets the X-Forwarded-For header, unless it is empty.
+func withForwardedFor(forwardedFor string) func(*http.Request) {
+	return func(req *http.Request) {
+		if forwardedFor != "" {
+			req.Header.Set("X-Forwarded-For", forwardedFor)
+		}
+	}
+}
+
 func TestMetricsHandlerReturnsOK(t *testing.T) {
 	req, err := http.NewRequest("GET", "/metrics", nil)
 	if err != nil {
@@ -21,7 +61,7 @@ func TestMetricsHandlerReturnsOK(t *testing.T) {
 	}
 
 	rr := httptest.NewRecorder()
-	h := handler.New("")
+	h := handler.New(prometheus.NewRegistry(), "")
 
 	h.ServeHTTP(rr, req)
 
@@ -35,7 +75,7 @@ func TestMetricsHandlerReturnsUnauthorizedForEmptyAuthorizationHeader(t *testing
 	}
 
 	rr := httptest.NewRecorder()
-	h := handler.New(authToken)
+	h := handler.New(prometheus.NewRegistry(), authToken)
 
 	h.ServeHTTP(rr, req)
 
@@ -50,7 +90,7 @@ func TestMetricsHandlerReturnsUnauthorizedForInvalidToken(t *testing.T) {
 	}
 
 	rr := httptest.NewRecorder()
-	h := handler.New(authToken)
+	h := handler.New(prometheus.NewRegistry(), authToken)
 
 	h.ServeHTTP(rr, req)
 
@@ -65,9 +105,100 @@ func TestMetricsHandlerReturnsOKForValidToken(t *testing.T) {
 	}
 
 	rr := httptest.NewRecorder()
-	h := handler.New(authToken)
+	h := handler.New(prometheus.NewRegistry(), authToken)
+
+	h.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+}
+
+func TestMetricsHandlerServesRegistry(t *testing.T) {
+	req, err := http.NewRequest("GET", "/metrics", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(newGauge("container_test_gauge"))
+
+	rr := httptest.NewRecorder()
+	h := handler.New(registry, "")
+
+	h.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), "container_test_gauge 1")
+	assert.NotContains(t, rr.Body.String(), "go_goroutines")
+}
+
+func TestMetricsHandlerServesCollectorWithRequestContext(t *testing.T) {
+	type key struct{}
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(newGauge("exporter_test_gauge"))
+
+	var values []any
+	h := handler.New(registry, "",
+		handler.WithConstLabels(map[string]string{"host": "a"}),
+		handler.WithCollector(func(ctx context.Context) prometheus.Collector {
+			values = append(values, ctx.Value(key{}))
+			return newGauge("container_test_gauge")
+		}))
+
+	rr := serve(h, "/metrics", func(req *http.Request) {
+		*req = *req.WithContext(context.WithValue(req.Context(), key{}, "request"))
+	})
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), `container_test_gauge{host="a"} 1`)
+	assert.Contains(t, rr.Body.String(), "exporter_test_gauge 1")
+	assert.Equal(t, []any{"request"}, values)
+}
+
+func TestExporterMetricsHandlerServesExporterRegistry(t *testing.T) {
+	exporter := prometheus.NewRegistry()
+	exporter.MustRegister(newGauge("exporter_test_gauge"))
+
+	h := handler.New(prometheus.NewRegistry(), authToken, handler.WithExporterMetrics(exporter))
+
+	req, err := http.NewRequest("GET", "/metrics/exporter", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
 
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+
+	req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", authToken))
+	rr = httptest.NewRecorder()
 	h.ServeHTTP(rr, req)
 
 	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), "exporter_test_gauge 1")
+}
+
+func TestExporterMetricsHandlerIsDisabledByDefault(t *testing.T) {
+	req, err := http.NewRequest("GET", "/metrics/exporter", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	h := handler.New(prometheus.NewRegistry(), "")
+
+	h.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
+
+func newGauge(name string) prometheus.Gauge {
+	g := prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: name,
+		Help: "Test gauge",
+	})
+	g.Set(1)
+
+	return g
 }
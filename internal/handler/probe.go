@@ -0,0 +1,76 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	log "github.com/sirupsen/logrus"
+)
+
+// scrapeTimeoutOffset is subtracted from the scrape timeout of Prometheus, so
+// a probe running into it still answers before Prometheus gives up.
+const scrapeTimeoutOffset = 500 * time.Millisecond
+
+// Prober builds a short-lived collector for a single probe target.
+type Prober interface {
+	// Probe returns a collector for target using the named module (empty for
+	// the default one) and a function releasing its resources. The collector
+	// stops its requests to target when ctx is done.
+	Probe(ctx context.Context, target, module string) (prometheus.Collector, func(), error)
+}
+
+// handleProbe serves the metrics of the Docker daemon named by the target
+// query parameter, so Prometheus relabeling decides which daemons are scraped.
+func (s *handler) handleProbe() func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !s.authorizeUnscoped(w, r) {
+			return
+		}
+
+		params := r.URL.Query()
+
+		target := params.Get("target")
+		if target == "" {
+			http.Error(w, "target parameter is missing", http.StatusBadRequest)
+			return
+		}
+
+		ctx, cancel := probeContext(r)
+		defer cancel()
+
+		c, release, err := s.prober.Probe(ctx, target, params.Get("module"))
+		if err != nil {
+			log.WithError(err).WithField("target", target).
+				Warn("failed to probe target")
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer release()
+
+		registry := prometheus.NewRegistry()
+		prometheus.WrapRegistererWith(s.constLabels, registry).MustRegister(c)
+
+		promhttp.HandlerFor(registry, promhttp.HandlerOpts{}).ServeHTTP(w, r)
+	}
+}
+
+// probeContext returns the context of the probe requested by r, ending with
+// the scrape timeout Prometheus sends in the
+// X-Prometheus-Scrape-Timeout-Seconds header, if any.
+func probeContext(r *http.Request) (context.Context, context.CancelFunc) {
+	seconds, err := strconv.ParseFloat(r.Header.Get("X-Prometheus-Scrape-Timeout-Seconds"), 64)
+	if err != nil || seconds <= 0 {
+		return context.WithCancel(r.Context())
+	}
+
+	timeout := time.Duration(seconds * float64(time.Second))
+	if timeout > 2*scrapeTimeoutOffset {
+		timeout -= scrapeTimeoutOffset
+	}
+
+	return context.WithTimeout(r.Context(), timeout)
+}
@@ -0,0 +1,167 @@
+package handler_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/davidborzek/docker-exporter/internal/handler"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeProber struct {
+	target   string
+	module   string
+	deadline time.Time
+	released bool
+}
+
+func (p *fakeProber) Probe(ctx context.Context, target, module string) (prometheus.Collector, func(), error) {
+	if module == "unknown" {
+		return nil, nil, errors.New("unknown module")
+	}
+
+	p.target = target
+	p.module = module
+	p.deadline, _ = ctx.Deadline()
+
+	g := prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "probe_test_gauge",
+		Help: "Test gauge",
+	})
+	g.Set(1)
+
+	return g, func() { p.released = true }, nil
+}
+
+func TestProbeHandlerReturnsTargetMetrics(t *testing.T) {
+	req, err := http.NewRequest("GET", "/probe?target=tcp://docker:2376&module=tls", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	p := &fakeProber{}
+	h := handler.New(prometheus.NewRegistry(), "", handler.WithProber(p))
+
+	h.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), "probe_test_gauge 1")
+	assert.Equal(t, "tcp://docker:2376", p.target)
+	assert.Equal(t, "tls", p.module)
+	assert.True(t, p.released)
+}
+
+func TestProbeHandlerAddsConstLabels(t *testing.T) {
+	req, err := http.NewRequest("GET", "/probe?target=docker:2376", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	h := handler.New(prometheus.NewRegistry(), "",
+		handler.WithProber(&fakeProber{}),
+		handler.WithConstLabels(map[string]string{"datacenter": "fra1"}),
+	)
+
+	h.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), `probe_test_gauge{datacenter="fra1"} 1`)
+}
+
+func TestProbeHandlerUsesScrapeTimeout(t *testing.T) {
+	req, err := http.NewRequest("GET", "/probe?target=docker:2376", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("X-Prometheus-Scrape-Timeout-Seconds", "10")
+
+	rr := httptest.NewRecorder()
+	p := &fakeProber{}
+	h := handler.New(prometheus.NewRegistry(), "", handler.WithProber(p))
+
+	start := time.Now()
+	h.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.WithinDuration(t, start.Add(9500*time.Millisecond), p.deadline, time.Second)
+}
+
+func TestProbeHandlerReturnsBadRequestForMissingTarget(t *testing.T) {
+	req, err := http.NewRequest("GET", "/probe", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	h := handler.New(prometheus.NewRegistry(), "", handler.WithProber(&fakeProber{}))
+
+	h.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+func TestProbeHandlerReturnsBadRequestForUnknownModule(t *testing.T) {
+	req, err := http.NewRequest("GET", "/probe?target=docker:2376&module=unknown", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	h := handler.New(prometheus.NewRegistry(), "", handler.WithProber(&fakeProber{}))
+
+	h.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+func TestProbeHandlerReturnsUnauthorizedForInvalidToken(t *testing.T) {
+	req, err := http.NewRequest("GET", "/probe?target=docker:2376", nil)
+	req.Header.Add("Authorization", "Bearer invalidToken")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	h := handler.New(prometheus.NewRegistry(), authToken, handler.WithProber(&fakeProber{}))
+
+	h.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+}
+
+func TestProbeHandlerReturnsOKForValidToken(t *testing.T) {
+	req, err := http.NewRequest("GET", "/probe?target=docker:2376", nil)
+	req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", authToken))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	h := handler.New(prometheus.NewRegistry(), authToken, handler.WithProber(&fakeProber{}))
+
+	h.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+}
+
+func TestProbeHandlerIsNotRegisteredWithoutProber(t *testing.T) {
+	req, err := http.NewRequest("GET", "/probe?target=docker:2376", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	h := handler.New(prometheus.NewRegistry(), "")
+
+	h.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
@@ -0,0 +1,165 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/davidborzek/docker-exporter/internal/clock"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// readinessTTL is how long the results of the readiness checks are
+	// reused, so frequent probes do not hit the container runtime.
+	readinessTTL = 5 * time.Second
+	// readinessTimeout is how long a single readiness check may take.
+	readinessTimeout = 3 * time.Second
+)
+
+// Statuses of a readiness check.
+const (
+	checkOK      = "ok"
+	checkFailing = "failing"
+)
+
+// Check is a readiness check, e.g. whether the container runtime is
+// reachable. It fails by returning an error.
+type Check struct {
+	Name  string
+	Check func(ctx context.Context) error
+}
+
+// checkResult is the outcome of a check as served by /health?format=json.
+type checkResult struct {
+	Name           string     `json:"name"`
+	Status         string     `json:"status"`
+	LatencySeconds float64    `json:"latency_seconds"`
+	LastError      string     `json:"last_error,omitempty"`
+	LastErrorTime  *time.Time `json:"last_error_time,omitempty"`
+}
+
+// readiness runs the readiness checks and caches their results for
+// readinessTTL.
+type readiness struct {
+	clock  clock.Clock
+	checks []Check
+
+	mu      sync.Mutex
+	checked time.Time
+	results []checkResult
+}
+
+func newReadiness(clk clock.Clock, checks []Check) *readiness {
+	results := make([]checkResult, len(checks))
+	for i, c := range checks {
+		results[i] = checkResult{Name: c.Name}
+	}
+
+	return &readiness{clock: clk, checks: checks, results: results}
+}
+
+// check returns the results of the checks, running them again when the
+// cached ones are older than readinessTTL. The last error of a check is kept
+// after it succeeds again. The checks run without holding the lock, so a slow
+// check does not hold up probes answered from the cache.
+func (r *readiness) check(ctx context.Context) []checkResult {
+	r.mu.Lock()
+	if !r.checked.IsZero() && r.clock.Since(r.checked) < readinessTTL {
+		defer r.mu.Unlock()
+		return slices.Clone(r.results)
+	}
+	results := slices.Clone(r.results)
+	r.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for i, c := range r.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			// The results are shared, so a client going away must not
+			// fail them.
+			ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), readinessTimeout)
+			defer cancel()
+
+			start := r.clock.Now()
+			err := c.Check(ctx)
+
+			result := &results[i]
+			result.LatencySeconds = r.clock.Since(start).Seconds()
+			result.Status = checkOK
+			if err != nil {
+				now := r.clock.Now()
+				result.Status = checkFailing
+				result.LastError = err.Error()
+				result.LastErrorTime = &now
+
+				log.WithError(err).WithField("check", c.Name).
+					Debug("readiness check failed")
+			}
+		}()
+	}
+	wg.Wait()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.checked = r.clock.Now()
+	r.results = results
+
+	return slices.Clone(results)
+}
+
+// failing returns the names of the failing checks of results.
+func failing(results []checkResult) []string {
+	var names []string
+	for _, result := range results {
+		if result.Status != checkOK {
+			names = append(names, result.Name)
+		}
+	}
+
+	return names
+}
+
+// handleReady responds with 200 when every readiness check passes, and with
+// 503 Service Unavailable naming the failing checks otherwise.
+func (s *handler) handleReady() func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		disableCaching(w)
+
+		if names := failing(s.readiness.check(r.Context())); len(names) > 0 {
+			http.Error(w, "not ready: "+strings.Join(names, ", "), http.StatusServiceUnavailable)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		_, _ = w.Write([]byte("ready\n"))
+	}
+}
+
+// writeHealthDetails serves the results of the readiness checks as JSON. The
+// status is "degraded" when a check fails, but the exporter itself is alive,
+// so the response is 200 either way.
+func (s *handler) writeHealthDetails(w http.ResponseWriter, r *http.Request) {
+	results := s.readiness.check(r.Context())
+
+	status := checkOK
+	if len(failing(results)) > 0 {
+		status = "degraded"
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(struct {
+		Status string        `json:"status"`
+		Checks []checkResult `json:"checks"`
+	}{status, results}); err != nil {
+		log.WithError(err).
+			Debug("failed to write health details")
+	}
+}
@@ -0,0 +1,187 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/davidborzek/docker-exporter/internal/clock"
+	"github.com/davidborzek/docker-exporter/internal/handler"
+	"github.com/davidborzek/docker-exporter/internal/mock"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestReady(t *testing.T) {
+	h := handler.New(prometheus.NewRegistry(), "secret", handler.WithReadinessChecks(
+		handler.Check{Name: "runtime", Check: func(context.Context) error { return nil }},
+	))
+
+	rr := serve(h, "/ready")
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "ready\n", rr.Body.String())
+}
+
+func TestReadyFailsWithFailingChecks(t *testing.T) {
+	h := handler.New(prometheus.NewRegistry(), "", handler.WithReadinessChecks(
+		handler.Check{Name: "runtime", Check: func(context.Context) error { return errors.New("connection refused") }},
+		handler.Check{Name: "engine", Check: func(context.Context) error { return nil }},
+	))
+
+	rr := serve(h, "/ready")
+	assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+	assert.Equal(t, "not ready: runtime\n", rr.Body.String())
+
+	// Liveness does not depend on the checks.
+	assert.Equal(t, http.StatusOK, serve(h, "/health").Code)
+}
+
+// newClock returns a clock reading *now.
+func newClock(t *testing.T, now *time.Time) clock.Clock {
+	t.Helper()
+
+	mockClock := mock.NewMockClock(gomock.NewController(t))
+	mockClock.EXPECT().Now().DoAndReturn(func() time.Time { return *now }).AnyTimes()
+	mockClock.EXPECT().
+		Since(gomock.Any()).
+		DoAndReturn(func(t time.Time) time.Duration { return now.Sub(t) }).
+		AnyTimes()
+
+	return mockClock
+}
+
+func TestReadyCachesResults(t *testing.T) {
+	now := time.Now()
+
+	var calls atomic.Int32
+	h := handler.New(prometheus.NewRegistry(), "",
+		handler.WithClock(newClock(t, &now)),
+		handler.WithReadinessChecks(
+			handler.Check{Name: "runtime", Check: func(context.Context) error {
+				calls.Add(1)
+				return nil
+			}},
+		))
+
+	for range 3 {
+		assert.Equal(t, http.StatusOK, serve(h, "/ready").Code)
+	}
+	assert.Equal(t, int32(1), calls.Load())
+
+	now = now.Add(4 * time.Second)
+	assert.Equal(t, http.StatusOK, serve(h, "/ready").Code)
+	assert.Equal(t, int32(1), calls.Load())
+
+	// The results are checked again once they are older than 5s.
+	now = now.Add(time.Second)
+	assert.Equal(t, http.StatusOK, serve(h, "/ready").Code)
+	assert.Equal(t, int32(2), calls.Load())
+}
+
+func TestReadyRunsChecksConcurrently(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	h := handler.New(prometheus.NewRegistry(), "", handler.WithReadinessChecks(
+		handler.Check{Name: "runtime", Check: func(context.Context) error {
+			started <- struct{}{}
+			<-release
+			return nil
+		}},
+	))
+
+	done := make(chan int)
+	for range 2 {
+		go func() { done <- serve(h, "/ready").Code }()
+	}
+
+	// A probe running the checks does not hold up another one.
+	for range 2 {
+		select {
+		case <-started:
+		case <-time.After(5 * time.Second):
+			t.Fatal("probes are serialized")
+		}
+	}
+
+	close(release)
+	assert.Equal(t, http.StatusOK, <-done)
+	assert.Equal(t, http.StatusOK, <-done)
+}
+
+func TestReadyTimesOutChecks(t *testing.T) {
+	h := handler.New(prometheus.NewRegistry(), "", handler.WithReadinessChecks(
+		handler.Check{Name: "runtime", Check: func(ctx context.Context) error {
+			deadline, ok := ctx.Deadline()
+			if !ok || time.Until(deadline) > 3*time.Second {
+				return errors.New("no timeout")
+			}
+			return nil
+		}},
+	))
+
+	assert.Equal(t, http.StatusOK, serve(h, "/ready").Code)
+}
+
+type healthDetails struct {
+	Status string `json:"status"`
+	Checks []struct {
+		Name           string     `json:"name"`
+		Status         string     `json:"status"`
+		LatencySeconds float64    `json:"latency_seconds"`
+		LastError      string     `json:"last_error"`
+		LastErrorTime  *time.Time `json:"last_error_time"`
+	} `json:"checks"`
+}
+
+func TestHealthDetails(t *testing.T) {
+	h := handler.New(prometheus.NewRegistry(), "secret", handler.WithReadinessChecks(
+		handler.Check{Name: "runtime", Check: func(context.Context) error { return errors.New("connection refused") }},
+		handler.Check{Name: "engine", Check: func(context.Context) error { return nil }},
+	))
+
+	assert.Equal(t, http.StatusUnauthorized, serve(h, "/health?format=json").Code)
+
+	rr := serve(h, "/health?format=json", withAuthorization("Bearer secret"))
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "application/json", rr.Header().Get("Content-Type"))
+
+	var details healthDetails
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &details))
+
+	assert.Equal(t, "degraded", details.Status)
+	require.Len(t, details.Checks, 2)
+
+	assert.Equal(t, "runtime", details.Checks[0].Name)
+	assert.Equal(t, "failing", details.Checks[0].Status)
+	assert.Equal(t, "connection refused", details.Checks[0].LastError)
+	assert.NotNil(t, details.Checks[0].LastErrorTime)
+
+	assert.Equal(t, "engine", details.Checks[1].Name)
+	assert.Equal(t, "ok", details.Checks[1].Status)
+	assert.Empty(t, details.Checks[1].LastError)
+	assert.Nil(t, details.Checks[1].LastErrorTime)
+}
+
+func TestHealthDetailsWithoutChecks(t *testing.T) {
+	h := handler.New(prometheus.NewRegistry(), "")
+
+	rr := serve(h, "/health?format=json")
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `{"status": "ok", "checks": []}`, rr.Body.String())
+}
+
+func TestReadyIsNotRestricted(t *testing.T) {
+	access, err := handler.NewAccessList(handler.AccessConfig{Allow: []string{"10.0.0.0/8"}})
+	require.NoError(t, err)
+
+	h := handler.New(prometheus.NewRegistry(), "", handler.WithAccessList(access))
+
+	assert.Equal(t, http.StatusOK, serve(h, "/ready", withRemoteAddr("192.168.1.1:5000")).Code)
+	assert.Equal(t, http.StatusForbidden, serve(h, "/health?format=json", withRemoteAddr("192.168.1.1:5000")).Code)
+}
@@ -0,0 +1,32 @@
+package handler
+
+import (
+	"net/http"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// handleReload reloads the configuration, like Prometheus' /-/reload
+// endpoint. Only POST requests are accepted.
+func (s *handler) handleReload() func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !s.authorizeUnscoped(w, r) {
+			return
+		}
+
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "only POST requests allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		if err := s.reload(); err != nil {
+			log.WithError(err).
+				Error("failed to reload configuration")
+			http.Error(w, "failed to reload configuration: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}
+}
@@ -0,0 +1,99 @@
+package handler_test
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/davidborzek/docker-exporter/internal/handler"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReloadHandlerReloads(t *testing.T) {
+	reloads := 0
+	h := handler.New(prometheus.NewRegistry(), "", handler.WithReloader(func() error {
+		reloads++
+		return nil
+	}))
+
+	req, err := http.NewRequest(http.MethodPost, "/-/reload", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, 1, reloads)
+}
+
+func TestReloadHandlerRejectsOtherMethods(t *testing.T) {
+	h := handler.New(prometheus.NewRegistry(), "", handler.WithReloader(func() error {
+		t.Error("unexpected reload")
+		return nil
+	}))
+
+	for _, method := range []string{http.MethodGet, http.MethodPut} {
+		req, err := http.NewRequest(method, "/-/reload", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusMethodNotAllowed, rr.Code, method)
+		assert.Equal(t, "POST", rr.Header().Get("Allow"))
+	}
+}
+
+func TestReloadHandlerReturnsReloadError(t *testing.T) {
+	req, err := http.NewRequest("POST", "/-/reload", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	h := handler.New(prometheus.NewRegistry(), "", handler.WithReloader(func() error {
+		return errors.New(`unknown engine "rkt"`)
+	}))
+
+	h.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusInternalServerError, rr.Code)
+	assert.Contains(t, rr.Body.String(), `failed to reload configuration: unknown engine "rkt"`)
+}
+
+func TestReloadHandlerReturnsUnauthorized(t *testing.T) {
+	req, err := http.NewRequest("POST", "/-/reload", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", "invalid"))
+
+	rr := httptest.NewRecorder()
+	h := handler.New(prometheus.NewRegistry(), authToken, handler.WithReloader(func() error {
+		t.Error("unexpected reload")
+		return nil
+	}))
+
+	h.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+}
+
+func TestReloadHandlerIsDisabledWithoutReloader(t *testing.T) {
+	req, err := http.NewRequest("POST", "/-/reload", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	handler.New(prometheus.NewRegistry(), "").ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
@@ -0,0 +1,143 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"runtime"
+	"slices"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultMetricsPath is the path serving the container metrics when none is
+// configured.
+const DefaultMetricsPath = "/metrics"
+
+// reservedPaths are the paths of the other endpoints, relative to the route
+// prefix.
+var reservedPaths = []string{"/", "/health", "/ready", "/version", "/probe", "/-/reload", "/metrics/exporter"}
+
+// NormalizeRoutePrefix returns prefix with a leading slash and without a
+// trailing one, e.g. "/docker-exporter" for "docker-exporter/". The empty
+// prefix serves the routes at the root.
+func NormalizeRoutePrefix(prefix string) string {
+	prefix = strings.Trim(prefix, "/")
+	if prefix == "" {
+		return ""
+	}
+
+	return "/" + prefix
+}
+
+// ValidateMetricsPath checks that path is an absolute path not used by
+// another endpoint.
+func ValidateMetricsPath(path string) error {
+	if !strings.HasPrefix(path, "/") {
+		return fmt.Errorf("metrics path %q must start with a slash", path)
+	}
+	if !isPlainPath(path) {
+		return fmt.Errorf("metrics path %q must be a plain path", path)
+	}
+	if slices.Contains(reservedPaths, path) {
+		return fmt.Errorf("metrics path %q is used by another endpoint", path)
+	}
+
+	return nil
+}
+
+// ValidateRoutePrefix checks that prefix is a plain path.
+func ValidateRoutePrefix(prefix string) error {
+	if !isPlainPath(prefix) {
+		return fmt.Errorf("route prefix %q must be a plain path", prefix)
+	}
+
+	return nil
+}
+
+// isPlainPath reports whether path has no query, fragment, whitespace or
+// wildcards of the patterns of http.ServeMux.
+func isPlainPath(path string) bool {
+	return !strings.ContainsAny(path, "?#{} \t\n")
+}
+
+// route returns the path of the endpoint at path below the route prefix.
+func (s *handler) route(path string) string {
+	return s.routePrefix + path
+}
+
+// landingPage lists the endpoints of the exporter. The links are relative to
+// the page, so they also work behind a reverse proxy stripping the prefix.
+var landingPage = template.Must(template.New("landing").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Docker Exporter</title>
+</head>
+<body>
+<h1>Docker Exporter</h1>
+{{- if .Version}}
+<p>Version {{.Version}}</p>
+{{- end}}
+<ul>
+<li><a href="{{.MetricsPath}}">Metrics</a></li>
+{{- if .ExporterMetrics}}
+<li><a href="metrics/exporter">Exporter metrics</a></li>
+{{- end}}
+<li><a href="health?format=json">Health</a></li>
+<li><a href="ready">Readiness</a></li>
+<li><a href="version">Version</a></li>
+</ul>
+</body>
+</html>
+`))
+
+// handleLanding serves the landing page on the root of the route prefix, and
+// 404 Not Found for the paths below it not served by another endpoint.
+func (s *handler) handleLanding() func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != s.route("/") {
+			http.NotFound(w, r)
+			return
+		}
+
+		if _, ok := s.authorize(w, r); !ok {
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := landingPage.Execute(w, struct {
+			Version         string
+			MetricsPath     string
+			ExporterMetrics bool
+		}{
+			Version:         s.version,
+			MetricsPath:     strings.TrimPrefix(s.metricsPath, "/"),
+			ExporterMetrics: s.exporter != nil,
+		}); err != nil {
+			log.WithError(err).
+				Debug("failed to write the landing page")
+		}
+	}
+}
+
+// handleVersion serves the version of the exporter and of Go it was built
+// with.
+func (s *handler) handleVersion() func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := s.authorize(w, r); !ok {
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(struct {
+			Version   string `json:"version"`
+			GoVersion string `json:"go_version"`
+		}{s.version, runtime.Version()}); err != nil {
+			log.WithError(err).
+				Debug("failed to write the version")
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"runtime"
+	"testing"
+
+	"github.com/davidborzek/docker-exporter/internal/handler"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLandingPage(t *testing.T) {
+	h := handler.New(prometheus.NewRegistry(), "",
+		handler.WithVersion("1.2.3"),
+		handler.WithExporterMetrics(prometheus.NewRegistry()))
+
+	rr := serve(h, "/")
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "text/html; charset=utf-8", rr.Header().Get("Content-Type"))
+
+	body := rr.Body.String()
+	assert.Contains(t, body, "Version 1.2.3")
+	for _, link := range []string{`href="metrics"`, `href="metrics/exporter"`, `href="health?format=json"`, `href="ready"`, `href="version"`} {
+		assert.Contains(t, body, link)
+	}
+
+	assert.Equal(t, http.StatusNotFound, serve(h, "/unknown").Code)
+}
+
+func TestLandingPageRequiresAuth(t *testing.T) {
+	h := handler.New(prometheus.NewRegistry(), "secret")
+
+	assert.Equal(t, http.StatusUnauthorized, serve(h, "/").Code)
+	assert.Equal(t, http.StatusOK, serve(h, "/", withAuthorization("Bearer secret")).Code)
+}
+
+func TestVersion(t *testing.T) {
+	h := handler.New(prometheus.NewRegistry(), "", handler.WithVersion("1.2.3"))
+
+	rr := serve(h, "/version")
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	var version map[string]string
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &version))
+	assert.Equal(t, map[string]string{"version": "1.2.3", "go_version": runtime.Version()}, version)
+}
+
+func TestRoutePrefixAndMetricsPath(t *testing.T) {
+	h := handler.New(prometheus.NewRegistry(), "",
+		handler.WithRoutePrefix("docker-exporter/"),
+		handler.WithMetricsPath("/container-metrics"))
+
+	for _, path := range []string{
+		"/docker-exporter/",
+		"/docker-exporter/container-metrics",
+		"/docker-exporter/health",
+		"/docker-exporter/ready",
+		"/docker-exporter/version",
+	} {
+		assert.Equal(t, http.StatusOK, serve(h, path).Code, path)
+	}
+
+	for _, path := range []string{"/", "/metrics", "/health", "/docker-exporter/metrics"} {
+		assert.Equal(t, http.StatusNotFound, serve(h, path).Code, path)
+	}
+
+	rr := serve(h, "/docker-exporter")
+	assert.Equal(t, http.StatusTemporaryRedirect, rr.Code)
+	assert.Equal(t, "/docker-exporter/", rr.Header().Get("Location"))
+
+	assert.Contains(t, serve(h, "/docker-exporter/").Body.String(), `href="container-metrics"`)
+}
+
+func TestRoutePrefixKeepsProbesUnrestricted(t *testing.T) {
+	access, err := handler.NewAccessList(handler.AccessConfig{Allow: []string{"10.0.0.0/8"}})
+	require.NoError(t, err)
+
+	h := handler.New(prometheus.NewRegistry(), "",
+		handler.WithRoutePrefix("/docker-exporter"), handler.WithAccessList(access))
+
+	assert.Equal(t, http.StatusOK, serve(h, "/docker-exporter/health", withRemoteAddr("192.168.1.1:5000")).Code)
+	assert.Equal(t, http.StatusOK, serve(h, "/docker-exporter/ready", withRemoteAddr("192.168.1.1:5000")).Code)
+	assert.Equal(t, http.StatusForbidden, serve(h, "/docker-exporter/metrics", withRemoteAddr("192.168.1.1:5000")).Code)
+}
+
+func TestNormalizeRoutePrefix(t *testing.T) {
+	for prefix, want := range map[string]string{
+		"":                  "",
+		"/":                 "",
+		"docker-exporter":   "/docker-exporter",
+		"/docker-exporter/": "/docker-exporter",
+		"/a/b":              "/a/b",
+	} {
+		assert.Equal(t, want, handler.NormalizeRoutePrefix(prefix), prefix)
+	}
+}
+
+func TestValidateRoutes(t *testing.T) {
+	assert.NoError(t, handler.ValidateMetricsPath("/container-metrics"))
+	assert.EqualError(t, handler.ValidateMetricsPath("metrics"), `metrics path "metrics" must start with a slash`)
+	assert.EqualError(t, handler.ValidateMetricsPath("/health"), `metrics path "/health" is used by another endpoint`)
+	assert.EqualError(t, handler.ValidateMetricsPath("/{path}"), `metrics path "/{path}" must be a plain path`)
+
+	assert.NoError(t, handler.ValidateRoutePrefix("/docker-exporter"))
+	assert.EqualError(t, handler.ValidateRoutePrefix("GET /x"), `route prefix "GET /x" must be a plain path`)
+}
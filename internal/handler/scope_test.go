@@ -0,0 +1,157 @@
+package handler_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidborzek/docker-exporter/internal/collector"
+	"github.com/davidborzek/docker-exporter/internal/handler"
+	"github.com/docker/docker/api/types"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeScoper serves a metric for each of its containers matching the scope.
+type fakeScoper struct {
+	containers []types.Container
+}
+
+func (f fakeScoper) Scoped(_ context.Context, scope *collector.Filter) prometheus.Collector {
+	registry := prometheus.NewRegistry()
+	for _, c := range f.containers {
+		if scope.Match(c) {
+			registry.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+				Name:        "docker_container_running",
+				Help:        "Whether the container is running",
+				ConstLabels: prometheus.Labels{"name": c.Names[0]},
+			}, func() float64 { return 1 }))
+		}
+	}
+
+	return registry
+}
+
+const scopedTokens = `
+tokens:
+  - name: admin
+    token: admin-token
+  - name: shop
+    token: shop-token
+    scope:
+      compose_projects: [shop]
+  - name: payments
+    token: payments-token
+    scope:
+      label_selector: team=payments
+`
+
+func newScopedHandler(t *testing.T, opts ...handler.Option) http.Handler {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "tokens.yaml")
+	writeTokenFile(t, path, scopedTokens, time.Now())
+
+	tokens, err := handler.LoadTokenFile(path)
+	require.NoError(t, err)
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "docker_container_running",
+		Help: "Whether the container is running",
+	}, func() float64 { return 3 }))
+
+	scoper := fakeScoper{containers: []types.Container{
+		{Names: []string{"web"}, Labels: map[string]string{"com.docker.compose.project": "shop"}},
+		{Names: []string{"db"}, Labels: map[string]string{"com.docker.compose.project": "shop", "team": "payments"}},
+		{Names: []string{"ledger"}, Labels: map[string]string{"team": "payments"}},
+	}}
+
+	return handler.New(registry, "", append([]handler.Option{
+		handler.WithTokenFile(tokens),
+		handler.WithScoper(scoper),
+	}, opts...)...)
+}
+
+func TestScopedTokensSeeTheirContainers(t *testing.T) {
+	h := newScopedHandler(t, handler.WithConstLabels(map[string]string{"host": "a"}))
+
+	tests := []struct {
+		token string
+		want  []string
+	}{
+		{
+			token: "admin-token",
+			want:  []string{`docker_container_running 3`},
+		},
+		{
+			token: "shop-token",
+			want: []string{
+				`docker_container_running{host="a",name="db"} 1`,
+				`docker_container_running{host="a",name="web"} 1`,
+			},
+		},
+		{
+			token: "payments-token",
+			want: []string{
+				`docker_container_running{host="a",name="db"} 1`,
+				`docker_container_running{host="a",name="ledger"} 1`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.token, func(t *testing.T) {
+			rr := serve(h, "/metrics", withAuthorization("Bearer "+tt.token))
+			require.Equal(t, http.StatusOK, rr.Code)
+
+			var samples []string
+			for _, line := range strings.Split(rr.Body.String(), "\n") {
+				if strings.HasPrefix(line, "docker_container_running") {
+					samples = append(samples, line)
+				}
+			}
+			assert.Equal(t, tt.want, samples)
+		})
+	}
+}
+
+func TestScopedTokensAreForbiddenElsewhere(t *testing.T) {
+	h := newScopedHandler(t,
+		handler.WithExporterMetrics(prometheus.NewRegistry()),
+		handler.WithReloader(func() error { return nil }),
+	)
+
+	for _, path := range []string{"/metrics/exporter", "/-/reload"} {
+		req := httptest.NewRequest(http.MethodPost, path, nil)
+		req.Header.Set("Authorization", "Bearer shop-token")
+
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusForbidden, rr.Code, path)
+
+		req.Header.Set("Authorization", "Bearer admin-token")
+
+		rr = httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusOK, rr.Code, path)
+	}
+}
+
+func TestScopedTokensWithoutScoperAreForbidden(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tokens.yaml")
+	writeTokenFile(t, path, scopedTokens, time.Now())
+
+	tokens, err := handler.LoadTokenFile(path)
+	require.NoError(t, err)
+
+	h := handler.New(prometheus.NewRegistry(), "", handler.WithTokenFile(tokens))
+
+	assert.Equal(t, http.StatusForbidden, serve(h, "/metrics", withAuthorization("Bearer shop-token")).Code)
+	assert.Equal(t, http.StatusOK, serve(h, "/metrics", withAuthorization("Bearer admin-token")).Code)
+}
@@ -0,0 +1,393 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: api.go
+//
+// Generated by this command:
+//
+//	mockgen -destination=../mock/docker.go -package mock -typed -source api.go
+//
+
+// Package mock is a generated GoMock package.
+package mock
+
+import (
+	context "context"
+	http "net/http"
+	reflect "reflect"
+
+	types "github.com/docker/docker/api/types"
+	container "github.com/docker/docker/api/types/container"
+	system "github.com/docker/docker/api/types/system"
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockAPI is a mock of API interface.
+type MockAPI struct {
+	ctrl     *gomock.Controller
+	recorder *MockAPIMockRecorder
+	isgomock struct{}
+}
+
+// MockAPIMockRecorder is the mock recorder for MockAPI.
+type MockAPIMockRecorder struct {
+	mock *MockAPI
+}
+
+// NewMockAPI creates a new mock instance.
+func NewMockAPI(ctrl *gomock.Controller) *MockAPI {
+	mock := &MockAPI{ctrl: ctrl}
+	mock.recorder = &MockAPIMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockAPI) EXPECT() *MockAPIMockRecorder {
+	return m.recorder
+}
+
+// Close mocks base method.
+func (m *MockAPI) Close() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Close")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Close indicates an expected call of Close.
+func (mr *MockAPIMockRecorder) Close() *MockAPICloseCall {
+	mr.mock.ctrl.T.Helper()
+	call := mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Close", reflect.TypeOf((*MockAPI)(nil).Close))
+	return &MockAPICloseCall{Call: call}
+}
+
+// MockAPICloseCall wrap *gomock.Call
+type MockAPICloseCall struct {
+	*gomock.Call
+}
+
+// Return rewrite *gomock.Call.Return
+func (c *MockAPICloseCall) Return(arg0 error) *MockAPICloseCall {
+	c.Call = c.Call.Return(arg0)
+	return c
+}
+
+// Do rewrite *gomock.Call.Do
+func (c *MockAPICloseCall) Do(f func() error) *MockAPICloseCall {
+	c.Call = c.Call.Do(f)
+	return c
+}
+
+// DoAndReturn rewrite *gomock.Call.DoAndReturn
+func (c *MockAPICloseCall) DoAndReturn(f func() error) *MockAPICloseCall {
+	c.Call = c.Call.DoAndReturn(f)
+	return c
+}
+
+// ContainerInspect mocks base method.
+func (m *MockAPI) ContainerInspect(ctx context.Context, containerID string) (types.ContainerJSON, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ContainerInspect", ctx, containerID)
+	ret0, _ := ret[0].(types.ContainerJSON)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ContainerInspect indicates an expected call of ContainerInspect.
+func (mr *MockAPIMockRecorder) ContainerInspect(ctx, containerID any) *MockAPIContainerInspectCall {
+	mr.mock.ctrl.T.Helper()
+	call := mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ContainerInspect", reflect.TypeOf((*MockAPI)(nil).ContainerInspect), ctx, containerID)
+	return &MockAPIContainerInspectCall{Call: call}
+}
+
+// MockAPIContainerInspectCall wrap *gomock.Call
+type MockAPIContainerInspectCall struct {
+	*gomock.Call
+}
+
+// Return rewrite *gomock.Call.Return
+func (c *MockAPIContainerInspectCall) Return(arg0 types.ContainerJSON, arg1 error) *MockAPIContainerInspectCall {
+	c.Call = c.Call.Return(arg0, arg1)
+	return c
+}
+
+// Do rewrite *gomock.Call.Do
+func (c *MockAPIContainerInspectCall) Do(f func(context.Context, string) (types.ContainerJSON, error)) *MockAPIContainerInspectCall {
+	c.Call = c.Call.Do(f)
+	return c
+}
+
+// DoAndReturn rewrite *gomock.Call.DoAndReturn
+func (c *MockAPIContainerInspectCall) DoAndReturn(f func(context.Context, string) (types.ContainerJSON, error)) *MockAPIContainerInspectCall {
+	c.Call = c.Call.DoAndReturn(f)
+	return c
+}
+
+// ContainerList mocks base method.
+func (m *MockAPI) ContainerList(ctx context.Context, options container.ListOptions) ([]types.Container, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ContainerList", ctx, options)
+	ret0, _ := ret[0].([]types.Container)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ContainerList indicates an expected call of ContainerList.
+func (mr *MockAPIMockRecorder) ContainerList(ctx, options any) *MockAPIContainerListCall {
+	mr.mock.ctrl.T.Helper()
+	call := mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ContainerList", reflect.TypeOf((*MockAPI)(nil).ContainerList), ctx, options)
+	return &MockAPIContainerListCall{Call: call}
+}
+
+// MockAPIContainerListCall wrap *gomock.Call
+type MockAPIContainerListCall struct {
+	*gomock.Call
+}
+
+// Return rewrite *gomock.Call.Return
+func (c *MockAPIContainerListCall) Return(arg0 []types.Container, arg1 error) *MockAPIContainerListCall {
+	c.Call = c.Call.Return(arg0, arg1)
+	return c
+}
+
+// Do rewrite *gomock.Call.Do
+func (c *MockAPIContainerListCall) Do(f func(context.Context, container.ListOptions) ([]types.Container, error)) *MockAPIContainerListCall {
+	c.Call = c.Call.Do(f)
+	return c
+}
+
+// DoAndReturn rewrite *gomock.Call.DoAndReturn
+func (c *MockAPIContainerListCall) DoAndReturn(f func(context.Context, container.ListOptions) ([]types.Container, error)) *MockAPIContainerListCall {
+	c.Call = c.Call.DoAndReturn(f)
+	return c
+}
+
+// ContainerStats mocks base method.
+func (m *MockAPI) ContainerStats(ctx context.Context, containerID string, stream bool) (container.StatsResponseReader, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ContainerStats", ctx, containerID, stream)
+	ret0, _ := ret[0].(container.StatsResponseReader)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ContainerStats indicates an expected call of ContainerStats.
+func (mr *MockAPIMockRecorder) ContainerStats(ctx, containerID, stream any) *MockAPIContainerStatsCall {
+	mr.mock.ctrl.T.Helper()
+	call := mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ContainerStats", reflect.TypeOf((*MockAPI)(nil).ContainerStats), ctx, containerID, stream)
+	return &MockAPIContainerStatsCall{Call: call}
+}
+
+// MockAPIContainerStatsCall wrap *gomock.Call
+type MockAPIContainerStatsCall struct {
+	*gomock.Call
+}
+
+// Return rewrite *gomock.Call.Return
+func (c *MockAPIContainerStatsCall) Return(arg0 container.StatsResponseReader, arg1 error) *MockAPIContainerStatsCall {
+	c.Call = c.Call.Return(arg0, arg1)
+	return c
+}
+
+// Do rewrite *gomock.Call.Do
+func (c *MockAPIContainerStatsCall) Do(f func(context.Context, string, bool) (container.StatsResponseReader, error)) *MockAPIContainerStatsCall {
+	c.Call = c.Call.Do(f)
+	return c
+}
+
+// DoAndReturn rewrite *gomock.Call.DoAndReturn
+func (c *MockAPIContainerStatsCall) DoAndReturn(f func(context.Context, string, bool) (container.StatsResponseReader, error)) *MockAPIContainerStatsCall {
+	c.Call = c.Call.DoAndReturn(f)
+	return c
+}
+
+// DaemonHost mocks base method.
+func (m *MockAPI) DaemonHost() string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DaemonHost")
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// DaemonHost indicates an expected call of DaemonHost.
+func (mr *MockAPIMockRecorder) DaemonHost() *MockAPIDaemonHostCall {
+	mr.mock.ctrl.T.Helper()
+	call := mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DaemonHost", reflect.TypeOf((*MockAPI)(nil).DaemonHost))
+	return &MockAPIDaemonHostCall{Call: call}
+}
+
+// MockAPIDaemonHostCall wrap *gomock.Call
+type MockAPIDaemonHostCall struct {
+	*gomock.Call
+}
+
+// Return rewrite *gomock.Call.Return
+func (c *MockAPIDaemonHostCall) Return(arg0 string) *MockAPIDaemonHostCall {
+	c.Call = c.Call.Return(arg0)
+	return c
+}
+
+// Do rewrite *gomock.Call.Do
+func (c *MockAPIDaemonHostCall) Do(f func() string) *MockAPIDaemonHostCall {
+	c.Call = c.Call.Do(f)
+	return c
+}
+
+// DoAndReturn rewrite *gomock.Call.DoAndReturn
+func (c *MockAPIDaemonHostCall) DoAndReturn(f func() string) *MockAPIDaemonHostCall {
+	c.Call = c.Call.DoAndReturn(f)
+	return c
+}
+
+// HTTPClient mocks base method.
+func (m *MockAPI) HTTPClient() *http.Client {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "HTTPClient")
+	ret0, _ := ret[0].(*http.Client)
+	return ret0
+}
+
+// HTTPClient indicates an expected call of HTTPClient.
+func (mr *MockAPIMockRecorder) HTTPClient() *MockAPIHTTPClientCall {
+	mr.mock.ctrl.T.Helper()
+	call := mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "HTTPClient", reflect.TypeOf((*MockAPI)(nil).HTTPClient))
+	return &MockAPIHTTPClientCall{Call: call}
+}
+
+// MockAPIHTTPClientCall wrap *gomock.Call
+type MockAPIHTTPClientCall struct {
+	*gomock.Call
+}
+
+// Return rewrite *gomock.Call.Return
+func (c *MockAPIHTTPClientCall) Return(arg0 *http.Client) *MockAPIHTTPClientCall {
+	c.Call = c.Call.Return(arg0)
+	return c
+}
+
+// Do rewrite *gomock.Call.Do
+func (c *MockAPIHTTPClientCall) Do(f func() *http.Client) *MockAPIHTTPClientCall {
+	c.Call = c.Call.Do(f)
+	return c
+}
+
+// DoAndReturn rewrite *gomock.Call.DoAndReturn
+func (c *MockAPIHTTPClientCall) DoAndReturn(f func() *http.Client) *MockAPIHTTPClientCall {
+	c.Call = c.Call.DoAndReturn(f)
+	return c
+}
+
+// Info mocks base method.
+func (m *MockAPI) Info(ctx context.Context) (system.Info, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Info", ctx)
+	ret0, _ := ret[0].(system.Info)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Info indicates an expected call of Info.
+func (mr *MockAPIMockRecorder) Info(ctx any) *MockAPIInfoCall {
+	mr.mock.ctrl.T.Helper()
+	call := mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Info", reflect.TypeOf((*MockAPI)(nil).Info), ctx)
+	return &MockAPIInfoCall{Call: call}
+}
+
+// MockAPIInfoCall wrap *gomock.Call
+type MockAPIInfoCall struct {
+	*gomock.Call
+}
+
+// Return rewrite *gomock.Call.Return
+func (c *MockAPIInfoCall) Return(arg0 system.Info, arg1 error) *MockAPIInfoCall {
+	c.Call = c.Call.Return(arg0, arg1)
+	return c
+}
+
+// Do rewrite *gomock.Call.Do
+func (c *MockAPIInfoCall) Do(f func(context.Context) (system.Info, error)) *MockAPIInfoCall {
+	c.Call = c.Call.Do(f)
+	return c
+}
+
+// DoAndReturn rewrite *gomock.Call.DoAndReturn
+func (c *MockAPIInfoCall) DoAndReturn(f func(context.Context) (system.Info, error)) *MockAPIInfoCall {
+	c.Call = c.Call.DoAndReturn(f)
+	return c
+}
+
+// Ping mocks base method.
+func (m *MockAPI) Ping(ctx context.Context) (types.Ping, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Ping", ctx)
+	ret0, _ := ret[0].(types.Ping)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Ping indicates an expected call of Ping.
+func (mr *MockAPIMockRecorder) Ping(ctx any) *MockAPIPingCall {
+	mr.mock.ctrl.T.Helper()
+	call := mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Ping", reflect.TypeOf((*MockAPI)(nil).Ping), ctx)
+	return &MockAPIPingCall{Call: call}
+}
+
+// MockAPIPingCall wrap *gomock.Call
+type MockAPIPingCall struct {
+	*gomock.Call
+}
+
+// Return rewrite *gomock.Call.Return
+func (c *MockAPIPingCall) Return(arg0 types.Ping, arg1 error) *MockAPIPingCall {
+	c.Call = c.Call.Return(arg0, arg1)
+	return c
+}
+
+// Do rewrite *gomock.Call.Do
+func (c *MockAPIPingCall) Do(f func(context.Context) (types.Ping, error)) *MockAPIPingCall {
+	c.Call = c.Call.Do(f)
+	return c
+}
+
+// DoAndReturn rewrite *gomock.Call.DoAndReturn
+func (c *MockAPIPingCall) DoAndReturn(f func(context.Context) (types.Ping, error)) *MockAPIPingCall {
+	c.Call = c.Call.DoAndReturn(f)
+	return c
+}
+
+// ServerVersion mocks base method.
+func (m *MockAPI) ServerVersion(ctx context.Context) (types.Version, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ServerVersion", ctx)
+	ret0, _ := ret[0].(types.Version)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ServerVersion indicates an expected call of ServerVersion.
+func (mr *MockAPIMockRecorder) ServerVersion(ctx any) *MockAPIServerVersionCall {
+	mr.mock.ctrl.T.Helper()
+	call := mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ServerVersion", reflect.TypeOf((*MockAPI)(nil).ServerVersion), ctx)
+	return &MockAPIServerVersionCall{Call: call}
+}
+
+// MockAPIServerVersionCall wrap *gomock.Call
+type MockAPIServerVersionCall struct {
+	*gomock.Call
+}
+
+// Return rewrite *gomock.Call.Return
+func (c *MockAPIServerVersionCall) Return(arg0 types.Version, arg1 error) *MockAPIServerVersionCall {
+	c.Call = c.Call.Return(arg0, arg1)
+	return c
+}
+
+// Do rewrite *gomock.Call.Do
+func (c *MockAPIServerVersionCall) Do(f func(context.Context) (types.Version, error)) *MockAPIServerVersionCall {
+	c.Call = c.Call.Do(f)
+	return c
+}
+
+// DoAndReturn rewrite *gomock.Call.DoAndReturn
+func (c *MockAPIServerVersionCall) DoAndReturn(f func(context.Context) (types.Version, error)) *MockAPIServerVersionCall {
+	c.Call = c.Call.DoAndReturn(f)
+	return c
+}
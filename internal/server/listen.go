@@ -0,0 +1,244 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+const (
+	// unixPrefix marks listen addresses of unix domain sockets, e.g.
+	// "unix:/run/docker-exporter.sock".
+	unixPrefix = "unix:"
+	// systemdAddress selects the sockets passed by systemd socket
+	// activation. "systemd:<name>" selects the sockets of the FileDescriptorName
+	// name only.
+	systemdAddress = "systemd"
+
+	// listenFDsStart is the first file descriptor passed by systemd.
+	listenFDsStart = 3
+)
+
+// DefaultUnixSocketMode is the file mode of unix domain sockets when none is
+// configured.
+const DefaultUnixSocketMode os.FileMode = 0o660
+
+// ValidateListenAddress checks that addr is a TCP address like ":8080", a
+// unix domain socket like "unix:/run/docker-exporter.sock", or "systemd" or
+// "systemd:<name>" for sockets passed by systemd socket activation.
+func ValidateListenAddress(addr string) error {
+	if path, ok := strings.CutPrefix(addr, unixPrefix); ok {
+		if path == "" {
+			return fmt.Errorf("listen address %q: unix socket path is required", addr)
+		}
+		return nil
+	}
+
+	if name, ok := strings.CutPrefix(addr, systemdAddress+":"); ok && name == "" {
+		return fmt.Errorf("listen address %q: socket name is required", addr)
+	}
+	if _, systemd := systemdSocketName(addr); !systemd {
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			return fmt.Errorf("listen address %q: %w", addr, err)
+		}
+	}
+
+	return nil
+}
+
+// ParseUnixSocketMode parses an octal file mode like "0660". Empty returns
+// DefaultUnixSocketMode.
+func ParseUnixSocketMode(mode string) (os.FileMode, error) {
+	if mode == "" {
+		return DefaultUnixSocketMode, nil
+	}
+
+	m, err := strconv.ParseUint(mode, 8, 32)
+	if err != nil || m > 0o777 {
+		return 0, fmt.Errorf("invalid unix socket mode %q: expected octal permissions like 0660", mode)
+	}
+
+	return os.FileMode(m), nil
+}
+
+// Listen opens a listener for each of addrs, see ValidateListenAddress. Unix
+// domain sockets are created with mode, replacing stale sockets of a previous
+// run. On error, the listeners opened so far are closed.
+func Listen(addrs []string, mode os.FileMode) (listeners []net.Listener, err error) {
+	// The sockets passed by systemd, read on first use.
+	var activated []net.Listener
+
+	defer func() {
+		if err == nil {
+			// Sockets passed by systemd but not selected are not served.
+			for _, ln := range activated {
+				if !slices.Contains(listeners, ln) {
+					_ = ln.Close()
+				}
+			}
+			return
+		}
+
+		for _, ln := range append(listeners, activated...) {
+			_ = ln.Close()
+		}
+		listeners = nil
+	}()
+
+	for _, addr := range addrs {
+		if err := ValidateListenAddress(addr); err != nil {
+			return listeners, err
+		}
+
+		name, systemd := systemdSocketName(addr)
+		if !systemd {
+			ln, err := listen(addr, mode)
+			if err != nil {
+				return listeners, err
+			}
+			listeners = append(listeners, ln)
+			continue
+		}
+
+		if activated == nil {
+			if activated, err = systemdListeners(); err != nil {
+				return listeners, err
+			}
+		}
+
+		selected := 0
+		for _, ln := range activated {
+			if name != "" && ln.(namedListener).name != name {
+				continue
+			}
+			selected++
+			if !slices.Contains(listeners, ln) {
+				listeners = append(listeners, ln)
+			}
+		}
+		if selected == 0 {
+			return listeners, fmt.Errorf("listen address %q: no socket named %q passed by systemd socket activation", addr, name)
+		}
+	}
+
+	return listeners, nil
+}
+
+// systemdSocketName reports whether addr selects sockets passed by systemd,
+// and the FileDescriptorName of the selected sockets. Empty selects all.
+func systemdSocketName(addr string) (string, bool) {
+	if addr == systemdAddress {
+		return "", true
+	}
+
+	return strings.CutPrefix(addr, systemdAddress+":")
+}
+
+func listen(addr string, mode os.FileMode) (net.Listener, error) {
+	path, ok := strings.CutPrefix(addr, unixPrefix)
+	if !ok {
+		return net.Listen("tcp", addr)
+	}
+
+	if err := removeStaleSocket(path); err != nil {
+		return nil, err
+	}
+
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.Chmod(path, mode); err != nil {
+		_ = ln.Close()
+		return nil, fmt.Errorf("set mode of unix socket %s: %w", path, err)
+	}
+
+	return ln, nil
+}
+
+// removeStaleSocket removes the socket at path when no one listens on it
+// anymore, e.g. after the exporter was killed. Other files are left alone, so
+// a wrong path does not delete them.
+func removeStaleSocket(path string) error {
+	info, err := os.Lstat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if info.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("unix socket %s: file exists and is not a socket", path)
+	}
+
+	if conn, err := net.Dial("unix", path); err == nil {
+		_ = conn.Close()
+		return fmt.Errorf("unix socket %s: address already in use", path)
+	}
+
+	return os.Remove(path)
+}
+
+// systemdListeners returns the sockets passed by systemd socket activation,
+// as described in sd_listen_fds(3). The environment variables are unset, so
+// they are not passed on to child processes.
+func systemdListeners() ([]net.Listener, error) {
+	defer func() {
+		_ = os.Unsetenv("LISTEN_PID")
+		_ = os.Unsetenv("LISTEN_FDS")
+		_ = os.Unsetenv("LISTEN_FDNAMES")
+	}()
+
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return nil, errors.New("no sockets passed by systemd socket activation")
+	}
+
+	count, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || count < 1 {
+		return nil, errors.New("no sockets passed by systemd socket activation")
+	}
+
+	var names []string
+	if fdNames := os.Getenv("LISTEN_FDNAMES"); fdNames != "" {
+		names = strings.Split(fdNames, ":")
+	}
+
+	listeners := make([]net.Listener, 0, count)
+	for fd := listenFDsStart; fd < listenFDsStart+count; fd++ {
+		syscall.CloseOnExec(fd)
+
+		name := "LISTEN_FD_" + strconv.Itoa(fd)
+		if i := fd - listenFDsStart; i < len(names) {
+			name = names[i]
+		}
+
+		f := os.NewFile(uintptr(fd), name)
+		ln, err := net.FileListener(f)
+		// FileListener duplicates the descriptor.
+		_ = f.Close()
+		if err != nil {
+			for _, ln := range listeners {
+				_ = ln.Close()
+			}
+			return nil, fmt.Errorf("socket %d passed by systemd: %w", fd, err)
+		}
+
+		listeners = append(listeners, namedListener{Listener: ln, name: name})
+	}
+
+	return listeners, nil
+}
+
+// namedListener is a socket passed by systemd with its FileDescriptorName.
+type namedListener struct {
+	net.Listener
+	name string
+}
@@ -0,0 +1,176 @@
+package server_test
+
+import (
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/davidborzek/docker-exporter/internal/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateListenAddress(t *testing.T) {
+	for _, addr := range []string{":8080", "127.0.0.1:9417", "[::1]:9417", "unix:/run/exporter.sock", "systemd", "systemd:metrics"} {
+		assert.NoError(t, server.ValidateListenAddress(addr), addr)
+	}
+
+	assert.EqualError(t, server.ValidateListenAddress("unix:"), `listen address "unix:": unix socket path is required`)
+	assert.EqualError(t, server.ValidateListenAddress("systemd:"), `listen address "systemd:": socket name is required`)
+	assert.EqualError(t, server.ValidateListenAddress("8080"), `listen address "8080": address 8080: missing port in address`)
+}
+
+func TestParseUnixSocketMode(t *testing.T) {
+	mode, err := server.ParseUnixSocketMode("")
+	require.NoError(t, err)
+	assert.Equal(t, server.DefaultUnixSocketMode, mode)
+
+	mode, err = server.ParseUnixSocketMode("0600")
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), mode)
+
+	_, err = server.ParseUnixSocketMode("rw-rw----")
+	assert.EqualError(t, err, `invalid unix socket mode "rw-rw----": expected octal permissions like 0660`)
+
+	_, err = server.ParseUnixSocketMode("1777")
+	assert.Error(t, err)
+}
+
+// shortTempDir returns a temporary directory whose paths fit the limit of
+// unix socket paths.
+func shortTempDir(t *testing.T) string {
+	t.Helper()
+
+	dir, err := os.MkdirTemp("", "exp")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+
+	return dir
+}
+
+func TestListenUnixSocket(t *testing.T) {
+	path := filepath.Join(shortTempDir(t), "exporter.sock")
+
+	listeners, err := server.Listen([]string{"unix:" + path, "127.0.0.1:0"}, 0o600)
+	require.NoError(t, err)
+	require.Len(t, listeners, 2)
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.ModeSocket|0o600, info.Mode()&(os.ModeSocket|os.ModePerm))
+
+	assert.Equal(t, "unix", listeners[0].Addr().Network())
+	assert.Equal(t, "tcp", listeners[1].Addr().Network())
+
+	// The socket is in use.
+	_, err = server.Listen([]string{"unix:" + path}, 0o600)
+	assert.ErrorContains(t, err, "address already in use")
+
+	for _, ln := range listeners {
+		require.NoError(t, ln.Close())
+	}
+
+	_, err = os.Stat(path)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestListenReplacesStaleUnixSocket(t *testing.T) {
+	path := filepath.Join(shortTempDir(t), "exporter.sock")
+
+	// A socket left behind by a killed exporter.
+	stale, err := net.ListenUnix("unix", &net.UnixAddr{Name: path, Net: "unix"})
+	require.NoError(t, err)
+	stale.SetUnlinkOnClose(false)
+	require.NoError(t, stale.Close())
+
+	listeners, err := server.Listen([]string{"unix:" + path}, 0o660)
+	require.NoError(t, err)
+	defer listeners[0].Close()
+
+	go func() { _ = http.Serve(listeners[0], http.NotFoundHandler()) }()
+
+	conn, err := net.Dial("unix", path)
+	require.NoError(t, err)
+	conn.Close()
+}
+
+func TestListenKeepsOtherFiles(t *testing.T) {
+	path := filepath.Join(shortTempDir(t), "exporter.sock")
+	require.NoError(t, os.WriteFile(path, []byte("data"), 0o600))
+
+	_, err := server.Listen([]string{"unix:" + path}, 0o660)
+	assert.ErrorContains(t, err, "file exists and is not a socket")
+
+	_, err = os.Stat(path)
+	assert.NoError(t, err)
+}
+
+func TestListenClosesListenersOnError(t *testing.T) {
+	path := filepath.Join(shortTempDir(t), "exporter.sock")
+
+	_, err := server.Listen([]string{"unix:" + path, "127.0.0.1:invalid"}, 0o660)
+	assert.Error(t, err)
+
+	_, err = os.Stat(path)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestListenWithoutSystemdSockets(t *testing.T) {
+	t.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()))
+	t.Setenv("LISTEN_FDS", "0")
+
+	_, err := server.Listen([]string{"systemd"}, 0o660)
+	assert.EqualError(t, err, "no sockets passed by systemd socket activation")
+}
+
+// TestListenSystemdSocketsHelper is run by TestListenSystemdSockets as the
+// process systemd passes the sockets to.
+func TestListenSystemdSocketsHelper(t *testing.T) {
+	if os.Getenv("SYSTEMD_HELPER") != "1" {
+		t.Skip("run by TestListenSystemdSockets")
+	}
+
+	t.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()))
+
+	listeners, err := server.Listen([]string{"systemd:metrics"}, 0o660)
+	require.NoError(t, err)
+	require.Len(t, listeners, 1)
+	assert.Equal(t, os.Getenv("METRICS_ADDR"), listeners[0].Addr().String())
+
+	// The variables are not passed on to child processes.
+	assert.Empty(t, os.Getenv("LISTEN_FDS"))
+}
+
+func TestListenSystemdSockets(t *testing.T) {
+	var files []*os.File
+	var addrs []string
+	for range 2 {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		defer ln.Close()
+
+		f, err := ln.(*net.TCPListener).File()
+		require.NoError(t, err)
+		defer f.Close()
+
+		files = append(files, f)
+		addrs = append(addrs, ln.Addr().String())
+	}
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestListenSystemdSocketsHelper$", "-test.v")
+	cmd.ExtraFiles = files
+	cmd.Env = append(os.Environ(),
+		"SYSTEMD_HELPER=1",
+		"LISTEN_FDS=2",
+		"LISTEN_FDNAMES=other:metrics",
+		"METRICS_ADDR="+addrs[1],
+	)
+
+	out, err := cmd.CombinedOutput()
+	assert.NoError(t, err, string(out))
+	assert.Contains(t, string(out), "--- PASS: TestListenSystemdSocketsHelper")
+}
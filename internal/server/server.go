@@ -0,0 +1,108 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Default timeouts of the exporter's HTTP server.
+const (
+	DefaultReadTimeout         = 30 * time.Second
+	DefaultWriteTimeout        = 60 * time.Second
+	DefaultIdleTimeout         = 120 * time.Second
+	DefaultShutdownGracePeriod = 15 * time.Second
+)
+
+// Timeouts bound the requests of the exporter's HTTP server and its
+// shutdown. Zero disables a timeout, i.e. a zero grace period waits for the
+// in-flight requests however long they take.
+type Timeouts struct {
+	// Read is the time to read a request, including its body.
+	Read time.Duration
+	// Write is the time to serve a request after its headers were read. It
+	// must cover the slowest scrape.
+	Write time.Duration
+	// Idle is how long a keep-alive connection waits for its next request.
+	Idle time.Duration
+	// ShutdownGracePeriod is how long in-flight requests may take to finish
+	// on shutdown before their connections are closed.
+	ShutdownGracePeriod time.Duration
+}
+
+// New creates the exporter's HTTP server. It is served on listeners by Run.
+func New(h http.Handler, t Timeouts) *http.Server {
+	return &http.Server{
+		Handler:      h,
+		ReadTimeout:  t.Read,
+		WriteTimeout: t.Write,
+		IdleTimeout:  t.Idle,
+	}
+}
+
+// Run serves srv on listeners until ctx is done, serving HTTPS when
+// srv.TLSConfig is set. It then stops accepting connections and waits up to
+// grace for the in-flight requests to finish. The connections of requests
+// still running after it are closed. Run returns nil after a graceful
+// shutdown. When serving a listener fails, the others are shut down the same
+// way and the error is returned.
+func Run(ctx context.Context, srv *http.Server, listeners []net.Listener, grace time.Duration) error {
+	// Serving modifies the server, so it must not be read concurrently.
+	useTLS := srv.TLSConfig != nil
+
+	served := make(chan error, len(listeners))
+	for _, ln := range listeners {
+		go func() {
+			if useTLS {
+				// The certificates are provided by the TLS config.
+				served <- srv.ServeTLS(ln, "", "")
+				return
+			}
+			served <- srv.Serve(ln)
+		}()
+	}
+
+	running := len(listeners)
+
+	var serveErr error
+	select {
+	case serveErr = <-served:
+		running--
+	case <-ctx.Done():
+	}
+
+	log.WithField("grace_period", grace).
+		Info("shutting down the http server")
+
+	shutdownCtx := context.Background()
+	if grace > 0 {
+		var cancel context.CancelFunc
+		shutdownCtx, cancel = context.WithTimeout(shutdownCtx, grace)
+		defer cancel()
+	}
+
+	shutdownErr := srv.Shutdown(shutdownCtx)
+	if shutdownErr != nil {
+		_ = srv.Close()
+	}
+
+	for ; running > 0; running-- {
+		if err := <-served; serveErr == nil && !errors.Is(err, http.ErrServerClosed) {
+			serveErr = err
+		}
+	}
+
+	if serveErr != nil {
+		return serveErr
+	}
+	if shutdownErr != nil {
+		return fmt.Errorf("requests still running after the grace period of %s: %w", grace, shutdownErr)
+	}
+
+	return nil
+}
@@ -0,0 +1,166 @@
+package server_test
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/davidborzek/docker-exporter/internal/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// slowServer serves requests which block until release is closed, and
+// announces each request on started.
+func slowServer(t *testing.T, timeouts server.Timeouts) (*http.Server, net.Listener, chan struct{}, chan struct{}) {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	srv := server.New(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		started <- struct{}{}
+		<-release
+		_, _ = w.Write([]byte("done"))
+	}), timeouts)
+
+	return srv, ln, started, release
+}
+
+type response struct {
+	body string
+	err  error
+}
+
+func get(url string) <-chan response {
+	responses := make(chan response, 1)
+	go func() {
+		resp, err := http.Get(url)
+		if err != nil {
+			responses <- response{err: err}
+			return
+		}
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(resp.Body)
+		responses <- response{body: string(body), err: err}
+	}()
+
+	return responses
+}
+
+func TestNewAppliesTimeouts(t *testing.T) {
+	srv := server.New(http.NotFoundHandler(), server.Timeouts{
+		Read:  time.Second,
+		Write: 2 * time.Second,
+		Idle:  3 * time.Second,
+	})
+
+	assert.Equal(t, time.Second, srv.ReadTimeout)
+	assert.Equal(t, 2*time.Second, srv.WriteTimeout)
+	assert.Equal(t, 3*time.Second, srv.IdleTimeout)
+}
+
+func TestRunDrainsInflightRequests(t *testing.T) {
+	srv, ln, started, release := slowServer(t, server.Timeouts{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan error, 1)
+	go func() {
+		stopped <- server.Run(ctx, srv, []net.Listener{ln}, 10*time.Second)
+	}()
+
+	responses := get("http://" + ln.Addr().String())
+	<-started
+
+	cancel()
+
+	// New connections are refused while the request is drained.
+	assert.Eventually(t, func() bool {
+		conn, err := net.Dial("tcp", ln.Addr().String())
+		if err == nil {
+			conn.Close()
+		}
+		return err != nil
+	}, 5*time.Second, 10*time.Millisecond)
+
+	select {
+	case err := <-stopped:
+		t.Fatalf("server stopped with a request in flight: %v", err)
+	default:
+	}
+
+	close(release)
+
+	resp := <-responses
+	require.NoError(t, resp.err)
+	assert.Equal(t, "done", resp.body)
+	assert.NoError(t, <-stopped)
+}
+
+func TestRunClosesRequestsAfterGracePeriod(t *testing.T) {
+	srv, ln, started, release := slowServer(t, server.Timeouts{})
+	defer close(release)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan error, 1)
+	go func() {
+		stopped <- server.Run(ctx, srv, []net.Listener{ln}, 50*time.Millisecond)
+	}()
+
+	responses := get("http://" + ln.Addr().String())
+	<-started
+
+	cancel()
+
+	assert.ErrorContains(t, <-stopped, "requests still running after the grace period of 50ms")
+	assert.Error(t, (<-responses).err)
+}
+
+func TestRunServesEveryListener(t *testing.T) {
+	var listeners []net.Listener
+	for range 2 {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		listeners = append(listeners, ln)
+	}
+
+	srv := server.New(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("done"))
+	}), server.Timeouts{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan error, 1)
+	go func() {
+		stopped <- server.Run(ctx, srv, listeners, time.Second)
+	}()
+
+	for _, ln := range listeners {
+		resp := <-get("http://" + ln.Addr().String())
+		require.NoError(t, resp.err)
+		assert.Equal(t, "done", resp.body)
+	}
+
+	cancel()
+	assert.NoError(t, <-stopped)
+}
+
+func TestRunReturnsServeErrors(t *testing.T) {
+	failing, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	require.NoError(t, failing.Close())
+
+	healthy, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	srv := server.New(http.NotFoundHandler(), server.Timeouts{})
+
+	// The other listeners are shut down as well.
+	err = server.Run(context.Background(), srv, []net.Listener{failing, healthy}, time.Second)
+	assert.ErrorIs(t, err, net.ErrClosed)
+}
@@ -0,0 +1,204 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/davidborzek/docker-exporter/internal/filestate"
+	log "github.com/sirupsen/logrus"
+)
+
+// tlsVersions maps the accepted minimum TLS versions to their constants.
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// DefaultMinTLSVersion is the minimum TLS version used when none is
+// configured.
+const DefaultMinTLSVersion = "1.2"
+
+// certCheckInterval limits how often the certificate files are checked for
+// changes, so handshakes do not stat them every time.
+const certCheckInterval = time.Second
+
+// nextProtos are the application protocols offered by the server, i.e.
+// HTTP/2 next to HTTP/1.1.
+var nextProtos = []string{"h2", "http/1.1"}
+
+// TLSConfig configures TLS for the exporter's HTTP server.
+type TLSConfig struct {
+	// CertFile and KeyFile are the server certificate and its private key.
+	CertFile string
+	KeyFile  string
+	// ClientCAFile enables mutual TLS: clients must present a certificate
+	// signed by one of its CAs.
+	ClientCAFile string
+	// MinVersion is the minimum TLS version, e.g. "1.3". Defaults to
+	// DefaultMinTLSVersion.
+	MinVersion string
+	// CipherSuites restricts the cipher suites of TLS 1.2 and lower to the
+	// named ones, e.g. "TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256". The cipher
+	// suites of TLS 1.3 are not configurable.
+	CipherSuites []string
+}
+
+// Validate checks the settings and loads the certificates once, so
+// misconfiguration is reported at startup.
+func (c TLSConfig) Validate() error {
+	_, err := NewTLSConfig(c)
+	return err
+}
+
+// NewTLSConfig creates the TLS configuration of the server. The certificate,
+// key and client CA files are read again once they change on disk, so rotated
+// certificates are picked up by new connections without a restart.
+func NewTLSConfig(c TLSConfig) (*tls.Config, error) {
+	if c.CertFile == "" || c.KeyFile == "" {
+		return nil, errors.New("cert file and key file are required")
+	}
+
+	minVersion := c.MinVersion
+	if minVersion == "" {
+		minVersion = DefaultMinTLSVersion
+	}
+
+	version, ok := tlsVersions[minVersion]
+	if !ok {
+		return nil, fmt.Errorf("unknown minimum TLS version %q (valid: %s)",
+			minVersion, strings.Join(slices.Sorted(maps.Keys(tlsVersions)), ", "))
+	}
+
+	// The config returned for a client replaces the one of the server, so it
+	// must offer the same protocols.
+	base := &tls.Config{MinVersion: version, NextProtos: nextProtos}
+
+	for _, name := range c.CipherSuites {
+		id, err := cipherSuite(name)
+		if err != nil {
+			return nil, err
+		}
+		base.CipherSuites = append(base.CipherSuites, id)
+	}
+
+	if c.ClientCAFile != "" {
+		base.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	files := &certFiles{config: c}
+	if err := files.reload(); err != nil {
+		return nil, err
+	}
+
+	return &tls.Config{
+		MinVersion: base.MinVersion,
+		NextProtos: nextProtos,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			if err := files.reload(); err != nil {
+				log.WithError(err).
+					Warn("failed to reload TLS certificates - keeping the previous ones")
+			}
+
+			cfg := base.Clone()
+			cfg.Certificates, cfg.ClientCAs = files.current()
+
+			return cfg, nil
+		},
+	}, nil
+}
+
+// cipherSuite returns the ID of a secure cipher suite.
+func cipherSuite(name string) (uint16, error) {
+	names := make([]string, 0, len(tls.CipherSuites()))
+	for _, suite := range tls.CipherSuites() {
+		if suite.Name == name {
+			return suite.ID, nil
+		}
+		names = append(names, suite.Name)
+	}
+
+	return 0, fmt.Errorf("unknown cipher suite %q (valid: %s)", name, strings.Join(names, ", "))
+}
+
+// certFiles holds the certificate and client CAs read from the configured
+// files, together with the state of the files when they were read.
+type certFiles struct {
+	config TLSConfig
+
+	mu        sync.Mutex
+	checked   time.Time
+	state     filestate.State
+	cert      tls.Certificate
+	clientCAs *x509.CertPool
+}
+
+// reload reads the files again when any of them changed. They are checked at
+// most once per certCheckInterval once they were read. On error the
+// previously read files stay in use.
+func (f *certFiles) reload() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	now := time.Now()
+	if f.state != nil && now.Sub(f.checked) < certCheckInterval {
+		return nil
+	}
+	f.checked = now
+
+	paths := []string{f.config.CertFile, f.config.KeyFile}
+	if f.config.ClientCAFile != "" {
+		paths = append(paths, f.config.ClientCAFile)
+	}
+
+	state, err := filestate.Of(paths...)
+	if err != nil {
+		return err
+	}
+
+	if f.state != nil && state.Equal(f.state) {
+		return nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(f.config.CertFile, f.config.KeyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load certificate: %w", err)
+	}
+
+	var clientCAs *x509.CertPool
+	if f.config.ClientCAFile != "" {
+		pem, err := os.ReadFile(f.config.ClientCAFile)
+		if err != nil {
+			return fmt.Errorf("failed to read client CA file: %w", err)
+		}
+
+		clientCAs = x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("no certificates found in client CA file %q", f.config.ClientCAFile)
+		}
+	}
+
+	if f.state != nil {
+		log.Info("TLS certificates reloaded")
+	}
+
+	f.state, f.cert, f.clientCAs = state, cert, clientCAs
+
+	return nil
+}
+
+func (f *certFiles) current() ([]tls.Certificate, *x509.CertPool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return []tls.Certificate{f.cert}, f.clientCAs
+}
@@ -0,0 +1,307 @@
+package server_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/davidborzek/docker-exporter/internal/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testCA is a throwaway CA writing the certificates it issues to a
+// temporary directory.
+type testCA struct {
+	t    *testing.T
+	dir  string
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	file string
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	ca := &testCA{t: t, dir: t.TempDir(), cert: cert, key: key}
+	ca.file = ca.write("ca.pem", pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+
+	return ca
+}
+
+// issue writes a certificate with the serial number and its key to
+// <name>.pem and <name>-key.pem.
+func (ca *testCA) issue(name string, serial int64, usage x509.ExtKeyUsage) (certFile, keyFile string) {
+	ca.t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(ca.t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "127.0.0.1"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	require.NoError(ca.t, err)
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(ca.t, err)
+
+	return ca.write(name+".pem", pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})),
+		ca.write(name+"-key.pem", pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}))
+}
+
+func (ca *testCA) write(name string, content []byte) string {
+	ca.t.Helper()
+
+	path := filepath.Join(ca.dir, name)
+	require.NoError(ca.t, os.WriteFile(path, content, 0o600))
+
+	return path
+}
+
+// newTLSServer starts a server using the TLS settings.
+func newTLSServer(t *testing.T, cfg server.TLSConfig) *httptest.Server {
+	t.Helper()
+
+	tlsConfig, err := server.NewTLSConfig(cfg)
+	require.NoError(t, err)
+
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	srv.TLS = tlsConfig
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+// newClient returns a client trusting the CA which opens a new connection
+// for every request.
+func newClient(ca *testCA, tlsConfig *tls.Config) *http.Client {
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+	tlsConfig.RootCAs = pool
+
+	return &http.Client{Transport: &http.Transport{
+		TLSClientConfig:   tlsConfig,
+		DisableKeepAlives: true,
+	}}
+}
+
+func TestTLSServesCertificate(t *testing.T) {
+	ca := newTestCA(t)
+	certFile, keyFile := ca.issue("server", 2, x509.ExtKeyUsageServerAuth)
+
+	srv := newTLSServer(t, server.TLSConfig{CertFile: certFile, KeyFile: keyFile})
+
+	resp, err := newClient(ca, &tls.Config{}).Get(srv.URL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, big.NewInt(2), resp.TLS.PeerCertificates[0].SerialNumber)
+}
+
+func TestMutualTLSRequiresClientCertificate(t *testing.T) {
+	ca := newTestCA(t)
+	certFile, keyFile := ca.issue("server", 2, x509.ExtKeyUsageServerAuth)
+	clientCertFile, clientKeyFile := ca.issue("client", 3, x509.ExtKeyUsageClientAuth)
+
+	srv := newTLSServer(t, server.TLSConfig{
+		CertFile:     certFile,
+		KeyFile:      keyFile,
+		ClientCAFile: ca.file,
+	})
+
+	_, err := newClient(ca, &tls.Config{}).Get(srv.URL)
+	assert.Error(t, err)
+
+	clientCert, err := tls.LoadX509KeyPair(clientCertFile, clientKeyFile)
+	require.NoError(t, err)
+
+	resp, err := newClient(ca, &tls.Config{Certificates: []tls.Certificate{clientCert}}).Get(srv.URL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestTLSReloadsRotatedCertificate(t *testing.T) {
+	ca := newTestCA(t)
+	certFile, keyFile := ca.issue("server", 2, x509.ExtKeyUsageServerAuth)
+
+	srv := newTLSServer(t, server.TLSConfig{CertFile: certFile, KeyFile: keyFile})
+	client := newClient(ca, &tls.Config{})
+
+	resp, err := client.Get(srv.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, big.NewInt(2), resp.TLS.PeerCertificates[0].SerialNumber)
+
+	ca.issue("server", 4, x509.ExtKeyUsageServerAuth)
+
+	// Make sure the rotation is noticed on file systems with a coarse
+	// modification time.
+	future := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(certFile, future, future))
+	require.NoError(t, os.Chtimes(keyFile, future, future))
+
+	// The files are checked for changes at most once a second.
+	assert.Eventually(t, func() bool {
+		resp, err := client.Get(srv.URL)
+		require.NoError(t, err)
+		resp.Body.Close()
+
+		return resp.TLS.PeerCertificates[0].SerialNumber.Cmp(big.NewInt(4)) == 0
+	}, 5*time.Second, 100*time.Millisecond)
+}
+
+func TestTLSNegotiatesHTTP2(t *testing.T) {
+	ca := newTestCA(t)
+	certFile, keyFile := ca.issue("server", 2, x509.ExtKeyUsageServerAuth)
+
+	tlsConfig, err := server.NewTLSConfig(server.TLSConfig{CertFile: certFile, KeyFile: keyFile})
+	require.NoError(t, err)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	srv := server.New(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}), server.Timeouts{})
+	srv.TLSConfig = tlsConfig
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan error, 1)
+	go func() {
+		stopped <- server.Run(ctx, srv, []net.Listener{ln}, time.Second)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		require.NoError(t, <-stopped)
+	})
+
+	client := newClient(ca, &tls.Config{})
+	client.Transport.(*http.Transport).ForceAttemptHTTP2 = true
+
+	resp, err := client.Get("https://" + ln.Addr().String())
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, 2, resp.ProtoMajor)
+}
+
+func TestTLSKeepsCertificateWhenRotationIsBroken(t *testing.T) {
+	ca := newTestCA(t)
+	certFile, keyFile := ca.issue("server", 2, x509.ExtKeyUsageServerAuth)
+
+	srv := newTLSServer(t, server.TLSConfig{CertFile: certFile, KeyFile: keyFile})
+
+	require.NoError(t, os.WriteFile(keyFile, []byte("garbage"), 0o600))
+
+	resp, err := newClient(ca, &tls.Config{}).Get(srv.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, big.NewInt(2), resp.TLS.PeerCertificates[0].SerialNumber)
+}
+
+func TestTLSRejectsVersionsBelowMinimum(t *testing.T) {
+	ca := newTestCA(t)
+	certFile, keyFile := ca.issue("server", 2, x509.ExtKeyUsageServerAuth)
+
+	srv := newTLSServer(t, server.TLSConfig{CertFile: certFile, KeyFile: keyFile, MinVersion: "1.3"})
+
+	_, err := newClient(ca, &tls.Config{MaxVersion: tls.VersionTLS12}).Get(srv.URL)
+	assert.Error(t, err)
+
+	resp, err := newClient(ca, &tls.Config{}).Get(srv.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, uint16(tls.VersionTLS13), resp.TLS.Version)
+}
+
+func TestNewTLSConfigErrors(t *testing.T) {
+	ca := newTestCA(t)
+	certFile, keyFile := ca.issue("server", 2, x509.ExtKeyUsageServerAuth)
+
+	tests := []struct {
+		name    string
+		config  server.TLSConfig
+		wantErr string
+	}{
+		{
+			name:    "missing key",
+			config:  server.TLSConfig{CertFile: certFile},
+			wantErr: "cert file and key file are required",
+		},
+		{
+			name:    "unknown version",
+			config:  server.TLSConfig{CertFile: certFile, KeyFile: keyFile, MinVersion: "1.4"},
+			wantErr: `unknown minimum TLS version "1.4" (valid: 1.0, 1.1, 1.2, 1.3)`,
+		},
+		{
+			name:    "unknown cipher suite",
+			config:  server.TLSConfig{CertFile: certFile, KeyFile: keyFile, CipherSuites: []string{"TLS_RSA_WITH_RC4_128_SHA"}},
+			wantErr: `unknown cipher suite "TLS_RSA_WITH_RC4_128_SHA"`,
+		},
+		{
+			name:    "missing file",
+			config:  server.TLSConfig{CertFile: certFile, KeyFile: filepath.Join(ca.dir, "missing.pem")},
+			wantErr: "missing.pem: no such file or directory",
+		},
+		{
+			name:    "mismatched key",
+			config:  server.TLSConfig{CertFile: certFile, KeyFile: ca.file},
+			wantErr: "failed to load certificate",
+		},
+		{
+			name:    "client CA without certificates",
+			config:  server.TLSConfig{CertFile: certFile, KeyFile: keyFile, ClientCAFile: keyFile},
+			wantErr: "no certificates found in client CA file",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.ErrorContains(t, tt.config.Validate(), tt.wantErr)
+		})
+	}
+}